@@ -0,0 +1,139 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"unsafe"
+)
+
+// Codec converts values of type T to and from bytes.
+type Codec[T any] interface {
+	// Append appends the encoding of |v| to |b|.
+	Append(b []byte, v T) ([]byte, error)
+	// Decode decodes a value from the entirety of |b|.
+	// It must not retain |b|.
+	Decode(b []byte) (T, error)
+}
+
+// fixedCodec is implemented by Codecs whose encodings all have the same
+// size, which the binary format then stores without a length prefix.
+type fixedCodec interface {
+	fixedSize() int
+}
+
+// RawCodec encodes values as a copy of their memory. T must not contain
+// pointers. Encodings are in native byte order, and include the padding
+// of struct types.
+type RawCodec[T any] struct{}
+
+// Append implements Codec.
+func (RawCodec[T]) Append(b []byte, v T) ([]byte, error) {
+	if hasPointers[T]() {
+		return b, rawCodecError[T]()
+	}
+	return append(b, rawBytes(&v)...), nil
+}
+
+// Decode implements Codec.
+func (RawCodec[T]) Decode(b []byte) (v T, err error) {
+	if hasPointers[T]() {
+		return v, rawCodecError[T]()
+	}
+	if len(b) != int(unsafe.Sizeof(v)) {
+		return v, fmt.Errorf("swiss: raw %T of %d bytes", v, len(b))
+	}
+	copy(rawBytes(&v), b)
+	return
+}
+
+func (RawCodec[T]) fixedSize() int {
+	var v T
+	return int(unsafe.Sizeof(v))
+}
+
+func rawCodecError[T any]() error {
+	return fmt.Errorf("swiss: RawCodec of %v, which contains pointers", reflect.TypeOf((*T)(nil)).Elem())
+}
+
+// rawBytes returns the memory of |*p|.
+func rawBytes[T any](p *T) []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(p)), unsafe.Sizeof(*p))
+}
+
+// StringCodec encodes strings as their bytes.
+type StringCodec[T ~string] struct{}
+
+// Append implements Codec.
+func (StringCodec[T]) Append(b []byte, v T) ([]byte, error) {
+	return append(b, v...), nil
+}
+
+// Decode implements Codec.
+func (StringCodec[T]) Decode(b []byte) (T, error) {
+	return T(b), nil
+}
+
+// anyStringCodec is StringCodec for a type T of kind string.
+type anyStringCodec[T any] struct{}
+
+func (anyStringCodec[T]) Append(b []byte, v T) ([]byte, error) {
+	return append(b, *(*string)(unsafe.Pointer(&v))...), nil
+}
+
+func (anyStringCodec[T]) Decode(b []byte) (v T, err error) {
+	*(*string)(unsafe.Pointer(&v)) = string(b)
+	return
+}
+
+// binaryCodec encodes a type T whose values
+// implement encoding.BinaryMarshaler, and whose
+// pointers implement encoding.BinaryUnmarshaler.
+type binaryCodec[T any] struct{}
+
+func (binaryCodec[T]) Append(b []byte, v T) ([]byte, error) {
+	buf, err := any(v).(encoding.BinaryMarshaler).MarshalBinary()
+	return append(b, buf...), err
+}
+
+func (binaryCodec[T]) Decode(b []byte) (v T, err error) {
+	err = any(&v).(encoding.BinaryUnmarshaler).UnmarshalBinary(b)
+	return
+}
+
+// DefaultCodec returns the Codec a Format uses for T when none is given:
+// RawCodec if T contains no pointers, a string codec if T is of kind
+// string, or the methods of encoding.BinaryMarshaler and
+// encoding.BinaryUnmarshaler if T implements them.
+func DefaultCodec[T any]() (Codec[T], error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	switch {
+	case !typeHasPointers(t):
+		return RawCodec[T]{}, nil
+	case t.Kind() == reflect.String:
+		return anyStringCodec[T]{}, nil
+	case t.Implements(binaryMarshalerType) && reflect.PointerTo(t).Implements(binaryUnmarshalerType):
+		return binaryCodec[T]{}, nil
+	default:
+		return nil, fmt.Errorf("swiss: no default Codec for %v", t)
+	}
+}
+
+var (
+	binaryMarshalerType   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
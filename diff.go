@@ -0,0 +1,198 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+// DiffKind is the kind of a difference between two Maps.
+type DiffKind uint8
+
+const (
+	// Added is a key present only in the second Map.
+	Added DiffKind = iota + 1
+	// Removed is a key present only in the first Map.
+	Removed
+	// Changed is a key present in both Maps with unequal values.
+	Changed
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	default:
+		return "unknown"
+	}
+}
+
+// Patch is the set of differences that brings one Map to the state of
+// another. It holds the key of each difference and, unless the key was
+// removed, the value it maps to in the second Map.
+type Patch[K comparable, V any] struct {
+	// keys holds added, then changed, then removed keys,
+	// and values holds the values of added and changed keys
+	keys           []K
+	values         []V
+	added, changed int
+}
+
+// Diff returns the Patch from |a| to |b|, comparing values using |eq|.
+// Applying the Patch to |a| makes it equal to |b|.
+func Diff[K comparable, V any](a, b *Map[K, V], eq func(V, V) bool) *Patch[K, V] {
+	var (
+		addedKeys, changedKeys, removed []K
+		addedValues, changedValues      []V
+	)
+	DiffIter(a, b, eq, func(kind DiffKind, k K, _, v V) (stop bool) {
+		switch kind {
+		case Added:
+			addedKeys, addedValues = append(addedKeys, k), append(addedValues, v)
+		case Changed:
+			changedKeys, changedValues = append(changedKeys, k), append(changedValues, v)
+		case Removed:
+			removed = append(removed, k)
+		}
+		return
+	})
+	p := &Patch[K, V]{
+		keys:    make([]K, 0, len(addedKeys)+len(changedKeys)+len(removed)),
+		values:  make([]V, 0, len(addedValues)+len(changedValues)),
+		added:   len(addedKeys),
+		changed: len(changedKeys),
+	}
+	p.keys = append(append(append(p.keys, addedKeys...), changedKeys...), removed...)
+	p.values = append(append(p.values, addedValues...), changedValues...)
+	return p
+}
+
+// DiffIter passes each difference from |a| to |b| to the callback,
+// comparing values using |eq|. The callback receives the key and its
+// values in |a| and |b|, the value being zero if the key is absent.
+//
+// If |a| and |b| share a hasher, as a Map and its Clone do until either
+// is rehashed, the tables are compared group by group and only keys that
+// moved between groups, were added or were removed are rehashed.
+// Otherwise every key is hashed once for each Map.
+func DiffIter[K comparable, V any](a, b *Map[K, V], eq func(V, V) bool, cb func(kind DiffKind, k K, old, new V) (stop bool)) {
+	a.settle()
+	b.settle()
+	aligned := len(a.groups) == len(b.groups) && a.hash.same(b.hash)
+	var zero V
+	for g := range a.ctrl {
+		for s, c := range a.ctrl[g] {
+			if c == empty || c == tombstone {
+				continue
+			}
+			k, v := &a.groups[g].keys[s], &a.groups[g].values[s]
+			w, ok := diffLookup(b, aligned, uint32(g), h2(c), k)
+			if !ok {
+				if cb(Removed, *k, *v, zero) {
+					return
+				}
+			} else if !eq(*v, *w) {
+				if cb(Changed, *k, *v, *w) {
+					return
+				}
+			}
+		}
+	}
+	for g := range b.ctrl {
+		for s, c := range b.ctrl[g] {
+			if c == empty || c == tombstone {
+				continue
+			}
+			k := &b.groups[g].keys[s]
+			if _, ok := diffLookup(a, aligned, uint32(g), h2(c), k); !ok {
+				if cb(Added, *k, zero, b.groups[g].values[s]) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// diffLookup returns a pointer to the value of |key| in |m|. If |aligned|,
+// |key| was found in group |g| with metadata |lo| of a table sharing the
+// hasher and size of |m|, so it is most likely in the same group of |m|
+// and is only hashed if it's not.
+func diffLookup[K comparable, V any](m *Map[K, V], aligned bool, g uint32, lo h2, key *K) (*V, bool) {
+	if aligned {
+		matches := metaMatchH2(&m.ctrl[g], lo)
+		for matches != 0 {
+			s := nextMatch(&matches)
+			if *key == m.groups[g].keys[s] {
+				return &m.groups[g].values[s], true
+			}
+		}
+	}
+	hi, lo := splitHash(m.hash.Hash(*key))
+	if g, s, ok := m.find(*key, hi, lo); ok {
+		return &m.groups[g].values[s], true
+	}
+	return nil, false
+}
+
+// Apply brings |m| to the state of the second Map of the Patch |p|,
+// provided |m| is equal to the first. Otherwise it stores the values of
+// the added and changed keys of |p| and deletes its removed keys.
+func (m *Map[K, V]) Apply(p *Patch[K, V]) {
+	m.Grow(p.added)
+	for i, v := range p.values {
+		m.Put(p.keys[i], v)
+	}
+	for _, k := range p.keys[len(p.values):] {
+		m.Delete(k)
+	}
+}
+
+// Iter passes each difference of |p| to the callback: added keys, then
+// changed keys, then removed keys. The value of a removed key is zero.
+func (p *Patch[K, V]) Iter(cb func(kind DiffKind, k K, v V) (stop bool)) {
+	var zero V
+	for i, k := range p.keys {
+		kind, v := Removed, zero
+		switch {
+		case i < p.added:
+			kind, v = Added, p.values[i]
+		case i < len(p.values):
+			kind, v = Changed, p.values[i]
+		}
+		if cb(kind, k, v) {
+			return
+		}
+	}
+}
+
+// Len returns the number of differences in |p|.
+func (p *Patch[K, V]) Len() int {
+	return len(p.keys)
+}
+
+// Added returns the number of keys added by |p|.
+func (p *Patch[K, V]) Added() int {
+	return p.added
+}
+
+// Changed returns the number of keys whose values |p| changes.
+func (p *Patch[K, V]) Changed() int {
+	return p.changed
+}
+
+// Removed returns the number of keys removed by |p|.
+func (p *Patch[K, V]) Removed() int {
+	return len(p.keys) - len(p.values)
+}
@@ -0,0 +1,151 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	eq := func(a, b int) bool { return a == b }
+	keys := uniq(genUint32Data(2000))
+	base := NewMap[uint32, int](uint32(len(keys)))
+	for i, k := range keys[:1000] {
+		base.Put(k, i)
+	}
+
+	h, err := NewStableHasher[uint32](1)
+	require.NoError(t, err)
+	assert.True(t, NewStableMap[uint32, int](0, h).hash.same(NewStableMap[uint32, int](0, h).hash))
+	assert.False(t, NewMap[uint32, int](0).hash.same(NewMap[uint32, int](0).hash))
+
+	// modify a Clone, so that the Maps share a hasher, or
+	// a Map of the same elements that does not
+	for name, a := range map[string]*Map[uint32, int]{
+		"clone":    base,
+		"unshared": FromMap(ToMap(base)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			b := Clone(base)
+			assert.Equal(t, name == "clone", a.hash.same(b.hash))
+			exp := map[uint32]DiffKind{}
+			r := rand.New(rand.NewSource(1))
+			for i := 0; i < 300; i++ {
+				k := keys[r.Intn(len(keys))]
+				_, inA := a.Get(k)
+				switch r.Intn(3) {
+				case 0:
+					b.Delete(k)
+					if inA {
+						exp[k] = Removed
+					} else {
+						delete(exp, k)
+					}
+				case 1:
+					b.Put(k, -i)
+					if inA {
+						exp[k] = Changed
+					} else {
+						exp[k] = Added
+					}
+				default:
+					if v, ok := a.Get(k); ok {
+						// restore the original value
+						b.Put(k, v)
+						delete(exp, k)
+					}
+				}
+			}
+
+			act := map[uint32]DiffKind{}
+			DiffIter(a, b, eq, func(kind DiffKind, k uint32, old, new int) (stop bool) {
+				act[k] = kind
+				switch kind {
+				case Added:
+					assert.Equal(t, 0, old)
+					assert.Equal(t, must(b.Get(k)), new)
+				case Removed:
+					assert.Equal(t, must(a.Get(k)), old)
+					assert.Equal(t, 0, new)
+				case Changed:
+					assert.Equal(t, must(a.Get(k)), old)
+					assert.Equal(t, must(b.Get(k)), new)
+				}
+				return
+			})
+			assert.Equal(t, exp, act)
+
+			p := Diff(a, b, eq)
+			assert.Equal(t, len(exp), p.Len())
+			assert.Equal(t, p.Len(), p.Added()+p.Changed()+p.Removed())
+			kinds := map[uint32]DiffKind{}
+			rank := map[DiffKind]int{Added: 0, Changed: 1, Removed: 2}
+			last := Added
+			p.Iter(func(kind DiffKind, k uint32, v int) (stop bool) {
+				assert.GreaterOrEqual(t, rank[kind], rank[last], "added, then changed, then removed")
+				if kind == Removed {
+					assert.Equal(t, 0, v)
+				} else {
+					assert.Equal(t, must(b.Get(k)), v)
+				}
+				last, kinds[k] = kind, kind
+				return
+			})
+			assert.Equal(t, exp, kinds)
+
+			c := Clone(a)
+			c.Apply(p)
+			assert.True(t, Equal(b, c))
+			assert.Equal(t, 0, Diff(b, c, eq).Len())
+			assert.Equal(t, 0, Diff(a, a, eq).Len())
+		})
+	}
+}
+
+func TestDiffStop(t *testing.T) {
+	a, b := NewMap[int, int](0), NewMap[int, int](0)
+	for i := 0; i < 100; i++ {
+		a.Put(i, i)
+		b.Put(i+50, i)
+	}
+	n := 0
+	DiffIter(a, b, func(x, y int) bool { return x == y }, func(DiffKind, int, int, int) (stop bool) {
+		n++
+		return n == 10
+	})
+	assert.Equal(t, 10, n)
+
+	p := Diff(a, b, func(x, y int) bool { return x == y })
+	require.Equal(t, 150, p.Len())
+	assert.Equal(t, 50, p.Added())
+	assert.Equal(t, 50, p.Changed())
+	assert.Equal(t, 50, p.Removed())
+	a.Apply(p)
+	assert.True(t, Equal(a, b))
+
+	// a Patch that only changes values does not grow the Map
+	c := Clone(b)
+	for i := 50; i < 150; i++ {
+		c.Put(i, -i)
+	}
+	groups := &b.groups[0]
+	b.Apply(Diff(b, c, func(x, y int) bool { return x == y }))
+	assert.True(t, Equal(b, c))
+	assert.True(t, groups == &b.groups[0])
+}
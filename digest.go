@@ -0,0 +1,110 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"encoding/binary"
+	"reflect"
+	"unsafe"
+)
+
+// digestSeed seeds the hashes of a digest. It is fixed,
+// so that digests can be compared between processes.
+const digestSeed = 0x5d2a_6f3c_91b4_e807
+
+// digest is the Digest of a Map, maintained by its writers.
+type digest[K comparable, V any] struct {
+	sum uint64
+	// stale is set once a value may have been modified
+	// through a pointer, and |sum| must be recomputed
+	stale      bool
+	key, value func(seed uint64, p unsafe.Pointer) uint64
+}
+
+// EnableDigest makes |m| maintain a digest of its contents, so that
+// Digest returns it in O(1). Enabling the digest takes O(n), and adds the
+// cost of hashing each written element to Put and Delete. Keys and values
+// must be types that a StableHasher can hash. Clone and Copy do not copy
+// the digest to other Maps.
+func (m *Map[K, V]) EnableDigest() error {
+	if m.ext != nil && m.ext.digest != nil {
+		return nil
+	}
+	key, err := stableHashFunc(reflect.TypeOf((*K)(nil)).Elem())
+	if err != nil {
+		return err
+	}
+	value, err := stableHashFunc(reflect.TypeOf((*V)(nil)).Elem())
+	if err != nil {
+		return err
+	}
+	d := &digest[K, V]{key: key, value: value}
+	d.sum = d.compute(m)
+	if m.ext == nil {
+		m.ext = &mapExt[K, V]{}
+	}
+	m.ext.digest = d
+	return nil
+}
+
+// DisableDigest stops maintaining the digest of |m|.
+func (m *Map[K, V]) DisableDigest() {
+	if m.ext != nil {
+		m.ext.digest = nil
+		m.releaseExt()
+	}
+}
+
+// Digest returns a hash of the contents of |m|, which is the same for any
+// two Maps with equal keys and values, independent of their order, hash
+// seeds and table sizes, and in any process. It panics unless the digest
+// was enabled by EnableDigest.
+//
+// Values modified through pointers returned by GetPtr, PutPtr and Ref.Ptr
+// make Digest recompute the digest in O(n), and must not be modified
+// through pointers obtained before the latest call to Digest.
+func (m *Map[K, V]) Digest() uint64 {
+	if m.ext == nil || m.ext.digest == nil {
+		panic("swiss: Digest of a Map without EnableDigest")
+	}
+	d := m.ext.digest
+	if d.stale {
+		d.sum, d.stale = d.compute(m), false
+	}
+	return d.sum
+}
+
+// entry returns the hash of the element |k|, |v|. The digest is the sum of
+// the hashes of the elements, so it can be updated as elements are written.
+func (d *digest[K, V]) entry(k *K, v *V) uint64 {
+	var b [16]byte
+	binary.LittleEndian.PutUint64(b[:8], d.key(digestSeed, unsafe.Pointer(k)))
+	binary.LittleEndian.PutUint64(b[8:], d.value(digestSeed, unsafe.Pointer(v)))
+	return wyhash(digestSeed, b[:])
+}
+
+// compute returns the digest of |m| in O(n).
+func (d *digest[K, V]) compute(m *Map[K, V]) (sum uint64) {
+	m.settle()
+	for g := range m.ctrl {
+		for s, c := range m.ctrl[g] {
+			if c == empty || c == tombstone {
+				continue
+			}
+			sum += d.entry(&m.groups[g].keys[s], &m.groups[g].values[s])
+		}
+	}
+	return
+}
@@ -0,0 +1,113 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigest(t *testing.T) {
+	keys := uniq(genStringData(8, 1000))
+	a := NewMap[string, int](0)
+	b := NewMap[string, int](uint32(len(keys)))
+	require.NoError(t, a.EnableDigest())
+	for i, k := range keys {
+		a.Put(k, i)
+	}
+	for i := len(keys) - 1; i >= 0; i-- {
+		b.Put(keys[i], i)
+	}
+	// enabled before or after writing, and
+	// independent of order and table size
+	require.NoError(t, b.EnableDigest())
+	assert.Equal(t, a.Digest(), b.Digest())
+	assert.NotEqual(t, uint64(0), a.Digest())
+
+	b.Put(keys[0], -1)
+	assert.NotEqual(t, a.Digest(), b.Digest())
+	b.Put(keys[0], 0)
+	assert.Equal(t, a.Digest(), b.Digest())
+	b.Delete(keys[1])
+	assert.NotEqual(t, a.Digest(), b.Digest())
+	b.Put(keys[1], 1)
+	assert.Equal(t, a.Digest(), b.Digest())
+
+	b.Clear()
+	assert.Equal(t, uint64(0), b.Digest())
+	Copy(b, a)
+	assert.Equal(t, a.Digest(), b.Digest())
+
+	b.DisableDigest()
+	assert.Nil(t, b.ext)
+	assert.Panics(t, func() { b.Digest() })
+
+	assert.Error(t, NewMap[int, []int](0).EnableDigest())
+}
+
+func TestDigestWriters(t *testing.T) {
+	m := NewMap[uint32, uint32](0)
+	require.NoError(t, m.EnableDigest())
+	snap := m.Snapshot()
+	defer snap.Close()
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 10_000; i++ {
+		k, v := uint32(r.Intn(500)), r.Uint32()
+		switch r.Intn(12) {
+		case 0:
+			m.Delete(k)
+		case 1:
+			m.Compute(k, func(old uint32, ok bool) (uint32, bool) {
+				return old + v, v%2 == 0
+			})
+		case 2:
+			m.Entry(k).AndModify(func(old uint32) uint32 { return old ^ v }).OrInsert(v)
+		case 3:
+			m.Entry(k).Put(v)
+		case 4:
+			m.GetOrPut(k, v)
+		case 5:
+			if p := m.GetPtr(k); p != nil {
+				*p = v
+			}
+		case 6:
+			*m.PutPtr(k) = v
+		case 7:
+			DeleteFunc(m, func(k, _ uint32) bool { return k%97 == 0 })
+		case 8:
+			if i%1000 == 0 {
+				m.Reset(100)
+			}
+		default:
+			m.Put(k, v)
+		}
+		if i%100 == 0 {
+			d := m.ext.digest
+			assert.Equal(t, d.compute(m), m.Digest())
+		}
+	}
+}
+
+func TestDigestStable(t *testing.T) {
+	// digests are compared between processes, and must never change
+	m := NewMap[string, int64](0)
+	require.NoError(t, m.EnableDigest())
+	m.Put("a", 1)
+	m.Put("b", -2)
+	assert.Equal(t, uint64(0x80980a1a6fc2e746), m.Digest())
+}
@@ -0,0 +1,465 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package durable provides a swiss.Map persisted to a directory by a
+// write-ahead log and checkpoints.
+//
+// Every Put and Delete is appended to the log before it is applied to the
+// map. A checkpoint writes the whole map in the binary format of
+// swiss.Format and starts a new, empty log. Open recovers a map by loading
+// the last checkpoint and replaying its log. A log whose tail was torn by
+// a crash is truncated to its last complete record, but a log corrupted
+// before its last record fails to open.
+package durable
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dolthub/swiss"
+)
+
+const (
+	checkpointPrefix = "checkpoint-"
+	logPrefix        = "log-"
+	tmpSuffix        = ".tmp"
+
+	// recordHeaderSize is the size of the header that precedes each
+	// log record: the CRC-32C of the record, its length, and the
+	// CRC-32C of both, so that a corrupt length is detected
+	recordHeaderSize = 12
+	// maxRecordSize bounds the length of a record, so
+	// that a corrupt length is not taken for a record
+	maxRecordSize = 1 << 30
+)
+
+const (
+	opPut byte = iota + 1
+	opDelete
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrCheckpoint is wrapped by the error of a Put or Delete that was
+// applied, but whose checkpoint failed. The write need not be retried,
+// and the next write that fills the log retries the checkpoint.
+var ErrCheckpoint = errors.New("durable: checkpoint failed")
+
+// Options configure a Map.
+type Options[K comparable, V any] struct {
+	// Format encodes keys and values in the log and in checkpoints.
+	Format swiss.Format[K, V]
+	// Sync makes every Put and Delete sync the log to stable storage
+	// before returning. Otherwise writes survive a crash of the process,
+	// but not of the operating system.
+	Sync bool
+	// CheckpointSize is the size in bytes of the log at which a Put or a
+	// Delete writes a checkpoint. If zero, only Checkpoint does.
+	CheckpointSize int64
+}
+
+// Map is a swiss.Map persisted to a directory. A Map is not safe for
+// concurrent use, and a directory must only be opened by one Map at a time.
+type Map[K comparable, V any] struct {
+	m    *swiss.Map[K, V]
+	dir  string
+	opts Options[K, V]
+	kc   swiss.Codec[K]
+	vc   swiss.Codec[V]
+	// gen is the generation of the current checkpoint and log
+	gen uint64
+	log *os.File
+	// size is the size of the log
+	size int64
+	// buf and kbuf are reused to encode records
+	buf, kbuf []byte
+}
+
+// Open opens the Map persisted in |dir|, creating the directory if needed.
+func Open[K comparable, V any](dir string, opts Options[K, V]) (*Map[K, V], error) {
+	d := &Map[K, V]{
+		m:    swiss.NewMap[K, V](0),
+		dir:  dir,
+		opts: opts,
+		kc:   opts.Format.Key,
+		vc:   opts.Format.Value,
+	}
+	var err error
+	if d.kc == nil {
+		if d.kc, err = swiss.DefaultCodec[K](); err != nil {
+			return nil, err
+		}
+	}
+	if d.vc == nil {
+		if d.vc, err = swiss.DefaultCodec[V](); err != nil {
+			return nil, err
+		}
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if err = d.recover(); err != nil {
+		if d.log != nil {
+			_ = d.log.Close()
+		}
+		return nil, err
+	}
+	return d, nil
+}
+
+// Has returns true if |key| is present in |d|.
+func (d *Map[K, V]) Has(key K) bool {
+	return d.m.Has(key)
+}
+
+// Get returns the |value| mapped by |key| if one exists.
+func (d *Map[K, V]) Get(key K) (value V, ok bool) {
+	return d.m.Get(key)
+}
+
+// Iter iterates the elements of the Map, passing them to the callback.
+// The Map must not be modified during iteration.
+func (d *Map[K, V]) Iter(cb func(k K, v V) (stop bool)) {
+	d.m.Iter(cb)
+}
+
+// Count returns the number of elements in the Map.
+func (d *Map[K, V]) Count() int {
+	return d.m.Count()
+}
+
+// Put logs and then attempts to insert |key| and |value|. If logging
+// fails, the Map is unchanged. An error wrapping ErrCheckpoint means
+// that the write was logged and applied, but that the checkpoint it
+// triggered failed.
+func (d *Map[K, V]) Put(key K, value V) (err error) {
+	b := append(d.buf[:0], make([]byte, recordHeaderSize)...)
+	b = append(b, opPut)
+	if b, err = d.appendKey(b, key); err != nil {
+		return err
+	}
+	if b, err = d.vc.Append(b, value); err != nil {
+		return err
+	}
+	if err = d.append(b); err != nil {
+		return err
+	}
+	d.m.Put(key, value)
+	return d.maybeCheckpoint()
+}
+
+// Delete logs and then attempts to remove |key|, returning true if it
+// was present. If logging fails, the Map is unchanged, and an error
+// wrapping ErrCheckpoint is reported as by Put. Keys that are not
+// present are not logged.
+func (d *Map[K, V]) Delete(key K) (ok bool, err error) {
+	if !d.m.Has(key) {
+		return false, nil
+	}
+	b := append(d.buf[:0], make([]byte, recordHeaderSize)...)
+	b = append(b, opDelete)
+	if b, err = d.appendKey(b, key); err != nil {
+		return false, err
+	}
+	if err = d.append(b); err != nil {
+		return false, err
+	}
+	d.m.Delete(key)
+	return true, d.maybeCheckpoint()
+}
+
+// Checkpoint writes the Map to a new checkpoint and starts a new log,
+// removing the previous checkpoint and log.
+func (d *Map[K, V]) Checkpoint() error {
+	gen := d.gen + 1
+	path := d.path(checkpointPrefix, gen)
+	if err := writeFileSync(path, func(w io.Writer) error {
+		_, err := d.opts.Format.WriteMap(w, d.m)
+		return err
+	}); err != nil {
+		return err
+	}
+	log, err := createLog(d.path(logPrefix, gen))
+	if err != nil {
+		return err
+	}
+	if err = syncDir(d.dir); err != nil {
+		_ = log.Close()
+		return err
+	}
+	_ = d.log.Close()
+	_ = os.Remove(d.path(logPrefix, d.gen))
+	_ = os.Remove(d.path(checkpointPrefix, d.gen))
+	d.gen, d.log, d.size = gen, log, 0
+	return nil
+}
+
+// Sync syncs the log to stable storage.
+func (d *Map[K, V]) Sync() error {
+	return d.log.Sync()
+}
+
+// Close syncs and closes the log. The Map must not be used after it
+// is closed.
+func (d *Map[K, V]) Close() error {
+	err := d.log.Sync()
+	if cerr := d.log.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// recover loads the last checkpoint in the directory and replays its log.
+func (d *Map[K, V]) recover() error {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return err
+	}
+	var gens []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasSuffix(name, tmpSuffix) {
+			// an interrupted checkpoint
+			_ = os.Remove(filepath.Join(d.dir, name))
+			continue
+		}
+		var gen uint64
+		if n, _ := fmt.Sscanf(name, checkpointPrefix+"%016x", &gen); n == 1 {
+			gens = append(gens, gen)
+		}
+	}
+	sort.Slice(gens, func(i, j int) bool { return gens[i] < gens[j] })
+	if len(gens) > 0 {
+		d.gen = gens[len(gens)-1]
+		f, err := os.Open(d.path(checkpointPrefix, d.gen))
+		if err != nil {
+			return err
+		}
+		_, err = d.opts.Format.ReadMap(f, d.m)
+		_ = f.Close()
+		if err != nil {
+			return fmt.Errorf("durable: checkpoint %d: %w", d.gen, err)
+		}
+	}
+	if err = d.replay(); err != nil {
+		return err
+	}
+	// remove what a checkpoint left behind
+	for _, e := range entries {
+		var gen uint64
+		name := e.Name()
+		n, _ := fmt.Sscanf(name, checkpointPrefix+"%016x", &gen)
+		if n == 0 {
+			n, _ = fmt.Sscanf(name, logPrefix+"%016x", &gen)
+		}
+		if n == 1 && gen < d.gen {
+			_ = os.Remove(filepath.Join(d.dir, name))
+		}
+	}
+	return nil
+}
+
+// replay applies the log of the current generation, truncating a final
+// record torn by a crash, and opens it for appending. A corrupt record
+// followed by others is an error, as they may have been committed.
+func (d *Map[K, V]) replay() (err error) {
+	path := d.path(logPrefix, d.gen)
+	if d.log, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(d.log)
+	if err != nil {
+		return err
+	}
+	var off int
+	for off < len(data) {
+		rec, torn, ok := nextRecord(data[off:])
+		if torn {
+			break
+		}
+		if !ok {
+			return fmt.Errorf("durable: log %d at offset %d: %w", d.gen, off, swiss.ErrCorrupt)
+		}
+		if err = d.apply(rec); err != nil {
+			return fmt.Errorf("durable: log %d at offset %d: %w", d.gen, off, err)
+		}
+		off += recordHeaderSize + len(rec)
+	}
+	if off < len(data) {
+		// a record torn by a crash
+		if err = d.log.Truncate(int64(off)); err != nil {
+			return err
+		}
+		if err = d.log.Sync(); err != nil {
+			return err
+		}
+	}
+	d.size = int64(off)
+	_, err = d.log.Seek(d.size, io.SeekStart)
+	return err
+}
+
+// apply applies the log record |rec| to the Map.
+func (d *Map[K, V]) apply(rec []byte) error {
+	if len(rec) == 0 {
+		return errors.New("empty record")
+	}
+	n, w := binary.Uvarint(rec[1:])
+	if w <= 0 || n > uint64(len(rec)-1-w) {
+		return errors.New("bad key length")
+	}
+	kb := rec[1+w : 1+w+int(n)]
+	key, err := d.kc.Decode(kb)
+	if err != nil {
+		return err
+	}
+	switch rec[0] {
+	case opPut:
+		value, err := d.vc.Decode(rec[1+w+int(n):])
+		if err != nil {
+			return err
+		}
+		d.m.Put(key, value)
+	case opDelete:
+		d.m.Delete(key)
+	default:
+		return fmt.Errorf("unknown operation %d", rec[0])
+	}
+	return nil
+}
+
+// appendKey appends the encoding of |key| prefixed by its length.
+func (d *Map[K, V]) appendKey(b []byte, key K) ([]byte, error) {
+	k, err := d.kc.Append(d.kbuf[:0], key)
+	if err != nil {
+		return b, err
+	}
+	d.kbuf = k
+	var p [binary.MaxVarintLen64]byte
+	b = append(b, p[:binary.PutUvarint(p[:], uint64(len(k)))]...)
+	return append(b, k...), nil
+}
+
+// append writes the record |b|, whose first recordHeaderSize bytes are
+// reserved for its header, to the log.
+func (d *Map[K, V]) append(b []byte) error {
+	d.buf = b
+	rec := b[recordHeaderSize:]
+	if len(rec) > maxRecordSize {
+		return fmt.Errorf("durable: record of %d bytes is too large", len(rec))
+	}
+	binary.LittleEndian.PutUint32(b[0:], crc32.Checksum(rec, crcTable))
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(rec)))
+	binary.LittleEndian.PutUint32(b[8:], crc32.Checksum(b[:8], crcTable))
+	n, err := d.log.Write(b)
+	if err == nil && d.opts.Sync {
+		err = d.log.Sync()
+	}
+	if err != nil {
+		// drop what was written of the record
+		if terr := d.log.Truncate(d.size); terr == nil {
+			_, _ = d.log.Seek(d.size, io.SeekStart)
+		}
+		return err
+	}
+	d.size += int64(n)
+	return nil
+}
+
+// maybeCheckpoint writes a checkpoint if the log has reached
+// CheckpointSize, wrapping its error in ErrCheckpoint.
+func (d *Map[K, V]) maybeCheckpoint() error {
+	if d.opts.CheckpointSize > 0 && d.size >= d.opts.CheckpointSize {
+		if err := d.Checkpoint(); err != nil {
+			return fmt.Errorf("%w: %v", ErrCheckpoint, err)
+		}
+	}
+	return nil
+}
+
+func (d *Map[K, V]) path(prefix string, gen uint64) string {
+	return filepath.Join(d.dir, fmt.Sprintf("%s%016x", prefix, gen))
+}
+
+// nextRecord returns the record at the start of |data|, and true if it
+// is intact. |torn| is true if the record is the last write to |data| and
+// is incomplete or fails its checksum, as a crash while appending leaves
+// it: either its header is intact and its length reaches the end of
+// |data|, or its header is not and only zeros follow it, as when a crash
+// extends a file without writing its data. Any other bad record is corrupt.
+func nextRecord(data []byte) (rec []byte, torn, ok bool) {
+	if len(data) < recordHeaderSize {
+		return nil, true, false
+	}
+	if crc32.Checksum(data[:8], crcTable) != binary.LittleEndian.Uint32(data[8:]) {
+		return nil, zeros(data[recordHeaderSize:]), false
+	}
+	n := binary.LittleEndian.Uint32(data[4:])
+	if n > maxRecordSize {
+		return nil, false, false
+	}
+	if int64(n) > int64(len(data)-recordHeaderSize) {
+		return nil, true, false
+	}
+	rec = data[recordHeaderSize : recordHeaderSize+int(n)]
+	if crc32.Checksum(rec, crcTable) != binary.LittleEndian.Uint32(data) {
+		return nil, len(data) == recordHeaderSize+int(n), false
+	}
+	return rec, false, true
+}
+
+// zeros returns true if every byte of |b| is zero.
+func zeros(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// writeFileSync atomically writes the file at |path| using |write|,
+// syncing it to stable storage before it is renamed into place.
+func writeFileSync(path string, write func(w io.Writer) error) error {
+	tmp := path + tmpSuffix
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+	}
+	return err
+}
+
+func createLog(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+}
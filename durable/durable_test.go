@@ -0,0 +1,271 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package durable
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/swiss"
+)
+
+func TestMap(t *testing.T) {
+	dir := t.TempDir()
+	d, err := Open[string, int64](dir, Options[string, int64]{Sync: true})
+	require.NoError(t, err)
+	exp := make(map[string]int64)
+	for i := 0; i < 100; i++ {
+		k := fmt.Sprint("key-", i)
+		require.NoError(t, d.Put(k, int64(i)))
+		exp[k] = int64(i)
+	}
+	for i := 0; i < 100; i += 3 {
+		k := fmt.Sprint("key-", i)
+		ok, err := d.Delete(k)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		delete(exp, k)
+	}
+	ok, err := d.Delete("missing")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assertMap(t, exp, d)
+	require.NoError(t, d.Close())
+
+	// recover from the log
+	d, err = Open[string, int64](dir, Options[string, int64]{})
+	require.NoError(t, err)
+	assertMap(t, exp, d)
+
+	// recover from a checkpoint and its log
+	require.NoError(t, d.Checkpoint())
+	require.NoError(t, d.Put("after", -1))
+	exp["after"] = -1
+	require.NoError(t, d.Close())
+	d, err = Open[string, int64](dir, Options[string, int64]{})
+	require.NoError(t, err)
+	assertMap(t, exp, d)
+	require.NoError(t, d.Close())
+	assert.Equal(t, []string{
+		"checkpoint-0000000000000001",
+		"log-0000000000000001",
+	}, listDir(t, dir))
+}
+
+func TestMapTornLog(t *testing.T) {
+	dir := t.TempDir()
+	d, err := Open[uint64, string](dir, Options[uint64, string]{})
+	require.NoError(t, err)
+	for i := uint64(0); i < 10; i++ {
+		require.NoError(t, d.Put(i, fmt.Sprint(i)))
+	}
+	require.NoError(t, d.Close())
+	log := filepath.Join(dir, "log-0000000000000000")
+	info, err := os.Stat(log)
+	require.NoError(t, err)
+	// each record is a header, an op, a
+	// length-prefixed key and a value
+	size := int64(recordHeaderSize + 1 + 1 + 8 + 1)
+	assert.Equal(t, 10*size, info.Size())
+
+	for cut := int64(1); cut < size; cut++ {
+		// simulate a crash during the last write
+		require.NoError(t, os.Truncate(log, 10*size-cut))
+		d, err = Open[uint64, string](dir, Options[uint64, string]{})
+		require.NoError(t, err)
+		assert.Equal(t, 9, d.Count())
+		assert.False(t, d.Has(9))
+		// the torn record was truncated away
+		require.NoError(t, d.Put(9, "9"))
+		require.NoError(t, d.Close())
+
+		d, err = Open[uint64, string](dir, Options[uint64, string]{})
+		require.NoError(t, err)
+		assert.Equal(t, 10, d.Count())
+		assert.Equal(t, "9", must(d.Get(9)))
+		require.NoError(t, d.Close())
+	}
+
+	// a corrupt record ends the log
+	data, err := os.ReadFile(log)
+	require.NoError(t, err)
+	data[len(data)-1] ^= 1
+	require.NoError(t, os.WriteFile(log, data, 0644))
+	d, err = Open[uint64, string](dir, Options[uint64, string]{})
+	require.NoError(t, err)
+	assert.Equal(t, 9, d.Count())
+	require.NoError(t, d.Close())
+
+	// a corrupt record followed by others fails to open
+	data, err = os.ReadFile(log)
+	require.NoError(t, err)
+	require.Equal(t, 9*size, int64(len(data)))
+	data[4*size+recordHeaderSize] ^= 1
+	require.NoError(t, os.WriteFile(log, data, 0644))
+	_, err = Open[uint64, string](dir, Options[uint64, string]{})
+	assert.ErrorIs(t, err, swiss.ErrCorrupt)
+	info, err = os.Stat(log)
+	require.NoError(t, err)
+	assert.Equal(t, 9*size, info.Size())
+
+	// as does a corrupt length followed by others,
+	// even if it runs past the end of the log
+	data[4*size+recordHeaderSize] ^= 1
+	data[4*size+7] = 0x7f
+	require.NoError(t, os.WriteFile(log, data, 0644))
+	_, err = Open[uint64, string](dir, Options[uint64, string]{})
+	assert.ErrorIs(t, err, swiss.ErrCorrupt)
+	info, err = os.Stat(log)
+	require.NoError(t, err)
+	assert.Equal(t, 9*size, info.Size())
+
+	// zeros left by a crash extending the log are torn
+	data[4*size+7] = 0
+	data = append(data, make([]byte, 2*size)...)
+	require.NoError(t, os.WriteFile(log, data, 0644))
+	d, err = Open[uint64, string](dir, Options[uint64, string]{})
+	require.NoError(t, err)
+	assert.Equal(t, 9, d.Count())
+	require.NoError(t, d.Close())
+	info, err = os.Stat(log)
+	require.NoError(t, err)
+	assert.Equal(t, 9*size, info.Size())
+}
+
+func TestMapCheckpoints(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options[int, []string]{
+		Format:         swiss.Format[int, []string]{Value: stringsCodec{}},
+		CheckpointSize: 1024,
+	}
+	d, err := Open(dir, opts)
+	require.NoError(t, err)
+	exp := make(map[int][]string)
+	for i := 0; i < 1000; i++ {
+		v := []string{fmt.Sprint(i), "x"}
+		require.NoError(t, d.Put(i%300, v))
+		exp[i%300] = v
+	}
+	assert.Greater(t, d.gen, uint64(10))
+	require.NoError(t, d.Close())
+
+	// an interrupted checkpoint is ignored
+	tmp := filepath.Join(dir, fmt.Sprintf("checkpoint-%016x.tmp", d.gen+1))
+	require.NoError(t, os.WriteFile(tmp, []byte("partial"), 0644))
+
+	d, err = Open(dir, opts)
+	require.NoError(t, err)
+	assertMap(t, exp, d)
+	require.NoError(t, d.Close())
+	assert.Len(t, listDir(t, dir), 2)
+
+	// a checkpoint whose log was never created
+	d, err = Open(dir, opts)
+	require.NoError(t, err)
+	require.NoError(t, d.Checkpoint())
+	require.NoError(t, d.Close())
+	require.NoError(t, os.Remove(filepath.Join(dir, fmt.Sprintf("log-%016x", d.gen))))
+	d, err = Open(dir, opts)
+	require.NoError(t, err)
+	assertMap(t, exp, d)
+
+	// a failed checkpoint does not undo the write
+	tmp = filepath.Join(dir, fmt.Sprintf("checkpoint-%016x.tmp", d.gen+1))
+	require.NoError(t, os.Mkdir(tmp, 0755))
+	for i := 0; err == nil; i++ {
+		v := []string{fmt.Sprint(i), "y"}
+		err = d.Put(i%300, v)
+		exp[i%300] = v
+	}
+	assert.ErrorIs(t, err, ErrCheckpoint)
+	assertMap(t, exp, d)
+	require.NoError(t, os.Remove(tmp))
+	require.NoError(t, d.Put(0, nil))
+	exp[0] = nil
+	require.NoError(t, d.Close())
+	d, err = Open(dir, opts)
+	require.NoError(t, err)
+	assertMap(t, exp, d)
+	require.NoError(t, d.Close())
+}
+
+func TestMapCorruptCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	d, err := Open[int, int](dir, Options[int, int]{})
+	require.NoError(t, err)
+	require.NoError(t, d.Put(1, 1))
+	require.NoError(t, d.Checkpoint())
+	require.NoError(t, d.Close())
+
+	path := filepath.Join(dir, "checkpoint-0000000000000001")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	data[len(data)-5] ^= 1
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	_, err = Open[int, int](dir, Options[int, int]{})
+	assert.ErrorIs(t, err, swiss.ErrCorrupt)
+}
+
+func assertMap[K comparable, V any](t *testing.T, exp map[K]V, d *Map[K, V]) {
+	assert.Equal(t, len(exp), d.Count())
+	for k, v := range exp {
+		a, ok := d.Get(k)
+		assert.True(t, ok)
+		assert.Equal(t, v, a)
+	}
+}
+
+func listDir(t *testing.T, dir string) (names []string) {
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return
+}
+
+func must[V any](v V, ok bool) V {
+	if !ok {
+		panic("missing value")
+	}
+	return v
+}
+
+// stringsCodec encodes a []string as its NUL-terminated elements.
+type stringsCodec struct{}
+
+func (stringsCodec) Append(b []byte, v []string) ([]byte, error) {
+	for _, s := range v {
+		b = append(append(b, s...), 0)
+	}
+	return b, nil
+}
+
+func (stringsCodec) Decode(b []byte) (v []string, err error) {
+	for len(b) > 0 {
+		i := 0
+		for b[i] != 0 {
+			i++
+		}
+		v = append(v, string(b[:i]))
+		b = b[i+1:]
+	}
+	return
+}
@@ -0,0 +1,595 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"unsafe"
+)
+
+// The binary format of a Map is a header, followed by its elements and a
+// trailer. Integers are little-endian. The header is:
+//
+//	magic      [4]byte  "SWSM"
+//	version    uint16   formatVersion
+//	width      uint8    group width of the encoded table
+//	flags      uint8    see flag* below
+//	keySize    uint32   size of raw keys, or 0
+//	valueSize  uint32   size of raw values, or 0
+//	count      uint64   number of elements
+//	checksum   uint32   CRC-32C of the preceding fields
+//
+// Each element is its key followed by its value. Raw keys and values take
+// keySize and valueSize bytes, others are prefixed by their length as a
+// uvarint. The trailer is the CRC-32C of the elements as a uint32.
+//
+// Elements are rehashed on load, since hash seeds are chosen per process,
+// so an encoding may be loaded into a table of any group width.
+
+const (
+	formatVersion = 1
+	headerSize    = 28
+	trailerSize   = 4
+
+	// maxPresize is the most elements a table is sized for before they
+	// are read from an input of unknown length, beyond which it grows
+	maxPresize = 1 << 16
+	// readChunk is the most bytes read at once, so that a corrupt
+	// length does not allocate more than the input holds
+	readChunk = 1 << 16
+
+	flagRawKeys   = 1 << 0
+	flagRawValues = 1 << 1
+	// flagBigEndian is set if raw
+	// keys and values are big-endian
+	flagBigEndian = 1 << 2
+)
+
+var (
+	formatMagic = [4]byte{'S', 'W', 'S', 'M'}
+	crcTable    = crc32.MakeTable(crc32.Castagnoli)
+
+	// ErrCorrupt is returned when decoding malformed or corrupted data.
+	ErrCorrupt = errors.New("swiss: corrupt encoding")
+)
+
+// Format encodes and decodes Maps using the Codecs |Key| and |Value|.
+// A nil Codec is replaced by the default Codec of its type: RawCodec if
+// the type contains no pointers, a StringCodec if it is a string type,
+// or its MarshalBinary and UnmarshalBinary methods if it has them.
+type Format[K comparable, V any] struct {
+	Key   Codec[K]
+	Value Codec[V]
+}
+
+// WriteMap writes the binary encoding of |m| to |w|.
+func (f Format[K, V]) WriteMap(w io.Writer, m *Map[K, V]) (int64, error) {
+	enc, err := newMapEncoder(f, w)
+	if err != nil {
+		return 0, err
+	}
+	m.settle()
+	enc.header(groupSize, m.Count())
+	for g := range m.ctrl {
+		for s, c := range m.ctrl[g] {
+			if c == empty || c == tombstone {
+				continue
+			}
+			enc.element(&m.groups[g].keys[s], &m.groups[g].values[s])
+		}
+	}
+	return enc.finish()
+}
+
+// ReadMap replaces the contents of |m| with the binary encoding read from
+// |r|. |m| is sized to hold every element before they are inserted, so
+// it is not rehashed during the load, as far as the length of |r| is
+// known to hold them. |m| may be a zero Map.
+func (f Format[K, V]) ReadMap(r io.Reader, m *Map[K, V]) (int64, error) {
+	dec, err := newMapDecoder(f, r)
+	if err != nil {
+		return 0, err
+	}
+	count, err := dec.header()
+	if err != nil {
+		return dec.n, err
+	}
+	n := dec.presize(count)
+	if m.ctrl == nil {
+		*m = *NewMapWith[K, V](WithCapacity(n))
+	} else {
+		m.Reset(n)
+	}
+	var (
+		k K
+		v V
+	)
+	for i := uint64(0); i < count; i++ {
+		if err = dec.element(&k, &v); err != nil {
+			m.Clear()
+			return dec.n, err
+		}
+		m.Put(k, v)
+	}
+	if err = dec.finish(); err != nil {
+		m.Clear()
+	}
+	return dec.n, err
+}
+
+// WriteMap8 writes the binary encoding of |m| to |w|.
+func (f Format[K, V]) WriteMap8(w io.Writer, m *Map8[K, V]) (int64, error) {
+	enc, err := newMapEncoder(f, w)
+	if err != nil {
+		return 0, err
+	}
+	enc.header(groupSize8, m.Count())
+	for g := range m.ctrl {
+		for s, c := range m.ctrl[g] {
+			if c == empty8 || c == tombstone8 {
+				continue
+			}
+			enc.element(&m.groups[g].keys[s], &m.groups[g].values[s])
+		}
+	}
+	return enc.finish()
+}
+
+// ReadMap8 replaces the contents of |m| with the binary encoding read
+// from |r|. See ReadMap.
+func (f Format[K, V]) ReadMap8(r io.Reader, m *Map8[K, V]) (int64, error) {
+	dec, err := newMapDecoder(f, r)
+	if err != nil {
+		return 0, err
+	}
+	count, err := dec.header()
+	if err != nil {
+		return dec.n, err
+	}
+	if count > math.MaxUint32-maxAvgGroupLoad8 {
+		// Map8 is sized in 32 bits
+		return dec.n, fmt.Errorf("%w: count %d out of range", ErrCorrupt, count)
+	}
+	n := dec.presize(count)
+	if m.ctrl == nil {
+		*m = *NewMap8[K, V](uint32(n))
+	} else {
+		m.Reset(n)
+	}
+	var (
+		k K
+		v V
+	)
+	for i := uint64(0); i < count; i++ {
+		if err = dec.element(&k, &v); err != nil {
+			m.Clear()
+			return dec.n, err
+		}
+		m.Put(k, v)
+	}
+	if err = dec.finish(); err != nil {
+		m.Clear()
+	}
+	return dec.n, err
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler using the default Codecs.
+func (m *Map[K, V]) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := Format[K, V]{}.WriteMap(&buf, m)
+	return buf.Bytes(), err
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler using the default Codecs.
+func (m *Map[K, V]) UnmarshalBinary(data []byte) error {
+	n, err := Format[K, V]{}.ReadMap(bytes.NewReader(data), m)
+	if err == nil && n != int64(len(data)) {
+		err = fmt.Errorf("%w: %d trailing bytes", ErrCorrupt, int64(len(data))-n)
+		m.Clear()
+	}
+	return err
+}
+
+// WriteTo implements io.WriterTo using the default Codecs.
+func (m *Map[K, V]) WriteTo(w io.Writer) (int64, error) {
+	return Format[K, V]{}.WriteMap(w, m)
+}
+
+// ReadFrom implements io.ReaderFrom using the default Codecs. It reads
+// a single encoded Map, and may buffer data beyond its end.
+func (m *Map[K, V]) ReadFrom(r io.Reader) (int64, error) {
+	return Format[K, V]{}.ReadMap(r, m)
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler using the default Codecs.
+func (m *Map8[K, V]) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := Format[K, V]{}.WriteMap8(&buf, m)
+	return buf.Bytes(), err
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler using the default Codecs.
+func (m *Map8[K, V]) UnmarshalBinary(data []byte) error {
+	n, err := Format[K, V]{}.ReadMap8(bytes.NewReader(data), m)
+	if err == nil && n != int64(len(data)) {
+		err = fmt.Errorf("%w: %d trailing bytes", ErrCorrupt, int64(len(data))-n)
+		m.Clear()
+	}
+	return err
+}
+
+// WriteTo implements io.WriterTo using the default Codecs.
+func (m *Map8[K, V]) WriteTo(w io.Writer) (int64, error) {
+	return Format[K, V]{}.WriteMap8(w, m)
+}
+
+// ReadFrom implements io.ReaderFrom using the default Codecs. It reads
+// a single encoded Map8, and may buffer data beyond its end.
+func (m *Map8[K, V]) ReadFrom(r io.Reader) (int64, error) {
+	return Format[K, V]{}.ReadMap8(r, m)
+}
+
+// codecs resolves the Codecs of |f|, returning the
+// sizes of raw keys and values, or -1 if not raw.
+func (f Format[K, V]) codecs() (kc Codec[K], vc Codec[V], ksz, vsz int, err error) {
+	if kc = f.Key; kc == nil {
+		if kc, err = DefaultCodec[K](); err != nil {
+			return
+		}
+	}
+	if vc = f.Value; vc == nil {
+		if vc, err = DefaultCodec[V](); err != nil {
+			return
+		}
+	}
+	ksz, vsz = -1, -1
+	if fc, ok := kc.(fixedCodec); ok {
+		if hasPointers[K]() {
+			return nil, nil, 0, 0, rawCodecError[K]()
+		}
+		ksz = fc.fixedSize()
+	}
+	if fc, ok := vc.(fixedCodec); ok {
+		if hasPointers[V]() {
+			return nil, nil, 0, 0, rawCodecError[V]()
+		}
+		vsz = fc.fixedSize()
+	}
+	return
+}
+
+type mapEncoder[K comparable, V any] struct {
+	kc       Codec[K]
+	vc       Codec[V]
+	ksz, vsz int
+	w        *bufio.Writer
+	buf      []byte
+	crc      uint32
+	n        int64
+	err      error
+}
+
+func newMapEncoder[K comparable, V any](f Format[K, V], w io.Writer) (*mapEncoder[K, V], error) {
+	kc, vc, ksz, vsz, err := f.codecs()
+	if err != nil {
+		return nil, err
+	}
+	return &mapEncoder[K, V]{
+		kc: kc, vc: vc,
+		ksz: ksz, vsz: vsz,
+		w: bufio.NewWriter(w),
+	}, nil
+}
+
+func (e *mapEncoder[K, V]) header(width, count int) {
+	var flags uint8
+	var ksz, vsz uint32
+	if e.ksz >= 0 {
+		flags |= flagRawKeys
+		ksz = uint32(e.ksz)
+	}
+	if e.vsz >= 0 {
+		flags |= flagRawValues
+		vsz = uint32(e.vsz)
+	}
+	if nativeBigEndian() {
+		flags |= flagBigEndian
+	}
+	var h [headerSize]byte
+	copy(h[:4], formatMagic[:])
+	binary.LittleEndian.PutUint16(h[4:], formatVersion)
+	h[6], h[7] = uint8(width), flags
+	binary.LittleEndian.PutUint32(h[8:], ksz)
+	binary.LittleEndian.PutUint32(h[12:], vsz)
+	binary.LittleEndian.PutUint64(h[16:], uint64(count))
+	binary.LittleEndian.PutUint32(h[24:], crc32.Checksum(h[:24], crcTable))
+	e.write(h[:])
+	// the payload checksum starts after the header
+	e.crc = 0
+}
+
+func (e *mapEncoder[K, V]) element(k *K, v *V) {
+	if e.err != nil {
+		return
+	}
+	if e.ksz >= 0 {
+		e.write(rawBytes(k))
+	} else {
+		e.buf, e.err = e.kc.Append(e.buf[:0], *k)
+		e.writePrefixed()
+	}
+	if e.vsz >= 0 {
+		e.write(rawBytes(v))
+	} else if e.err == nil {
+		e.buf, e.err = e.vc.Append(e.buf[:0], *v)
+		e.writePrefixed()
+	}
+}
+
+func (e *mapEncoder[K, V]) writePrefixed() {
+	if e.err != nil {
+		return
+	}
+	var p [binary.MaxVarintLen64]byte
+	e.write(p[:binary.PutUvarint(p[:], uint64(len(e.buf)))])
+	e.write(e.buf)
+}
+
+func (e *mapEncoder[K, V]) write(p []byte) {
+	if e.err != nil {
+		return
+	}
+	var n int
+	n, e.err = e.w.Write(p)
+	e.n += int64(n)
+	e.crc = crc32.Update(e.crc, crcTable, p)
+}
+
+func (e *mapEncoder[K, V]) finish() (int64, error) {
+	var t [4]byte
+	binary.LittleEndian.PutUint32(t[:], e.crc)
+	e.write(t[:])
+	if e.err == nil {
+		e.err = e.w.Flush()
+	}
+	return e.n, e.err
+}
+
+type mapDecoder[K comparable, V any] struct {
+	kc       Codec[K]
+	vc       Codec[V]
+	ksz, vsz int
+	r        *bufio.Reader
+	buf      []byte
+	crc      uint32
+	n        int64
+	// size is the length of the input, or -1 if unknown
+	size int64
+}
+
+func newMapDecoder[K comparable, V any](f Format[K, V], r io.Reader) (*mapDecoder[K, V], error) {
+	kc, vc, ksz, vsz, err := f.codecs()
+	if err != nil {
+		return nil, err
+	}
+	size := int64(-1)
+	if l, ok := r.(interface{ Len() int }); ok {
+		// a bytes.Reader, bytes.Buffer or strings.Reader
+		size = int64(l.Len())
+	}
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &mapDecoder[K, V]{
+		kc: kc, vc: vc,
+		ksz: ksz, vsz: vsz,
+		r:    br,
+		size: size,
+	}, nil
+}
+
+// left returns the number of bytes left in the input before
+// the trailer, or -1 if the length of the input is unknown.
+func (d *mapDecoder[K, V]) left() int64 {
+	if d.size < 0 {
+		return -1
+	}
+	if left := d.size - d.n - trailerSize; left > 0 {
+		return left
+	}
+	return 0
+}
+
+// maxCount returns the most elements the rest of the input can hold,
+// if the length of the input is known and elements take any bytes.
+func (d *mapDecoder[K, V]) maxCount() (uint64, bool) {
+	// each element takes at least its raw sizes
+	// and a byte for each length prefix
+	var size uint64
+	for _, sz := range []int{d.ksz, d.vsz} {
+		if sz < 0 {
+			size++
+		} else {
+			size += uint64(sz)
+		}
+	}
+	left := d.left()
+	if left < 0 || size == 0 {
+		return 0, false
+	}
+	return uint64(left) / size, true
+}
+
+// presize returns the number of elements to size a table for before
+// reading |count| of them, which header has checked against maxCount if
+// it is known, and is otherwise at most maxPresize.
+func (d *mapDecoder[K, V]) presize(count uint64) int {
+	if _, ok := d.maxCount(); !ok && count > maxPresize {
+		return maxPresize
+	}
+	return int(count)
+}
+
+// header reads and validates the header, returning the element count.
+func (d *mapDecoder[K, V]) header() (uint64, error) {
+	h, err := d.read(headerSize)
+	if err != nil {
+		return 0, err
+	}
+	if !bytes.Equal(h[:4], formatMagic[:]) {
+		return 0, fmt.Errorf("%w: bad magic %q", ErrCorrupt, h[:4])
+	}
+	if crc32.Checksum(h[:24], crcTable) != binary.LittleEndian.Uint32(h[24:]) {
+		return 0, fmt.Errorf("%w: header checksum mismatch", ErrCorrupt)
+	}
+	if v := binary.LittleEndian.Uint16(h[4:]); v != formatVersion {
+		return 0, fmt.Errorf("swiss: unsupported format version %d", v)
+	}
+	flags := h[7]
+	ksz, vsz := binary.LittleEndian.Uint32(h[8:]), binary.LittleEndian.Uint32(h[12:])
+	count := binary.LittleEndian.Uint64(h[16:])
+	if raw := flags&flagRawKeys != 0; raw != (d.ksz >= 0) || (raw && int(ksz) != d.ksz) {
+		return 0, fmt.Errorf("swiss: key Codec does not match encoding")
+	}
+	if raw := flags&flagRawValues != 0; raw != (d.vsz >= 0) || (raw && int(vsz) != d.vsz) {
+		return 0, fmt.Errorf("swiss: value Codec does not match encoding")
+	}
+	if flags&(flagRawKeys|flagRawValues) != 0 && (flags&flagBigEndian != 0) != nativeBigEndian() {
+		return 0, fmt.Errorf("swiss: raw encoding has foreign byte order")
+	}
+	if count > maxElems || count > math.MaxInt {
+		return 0, fmt.Errorf("%w: count %d out of range", ErrCorrupt, count)
+	}
+	if max, ok := d.maxCount(); ok && count > max {
+		return 0, fmt.Errorf("%w: %d bytes do not hold %d elements", ErrCorrupt, d.left(), count)
+	}
+	// the payload checksum starts after the header
+	d.crc = 0
+	return count, nil
+}
+
+func (d *mapDecoder[K, V]) element(k *K, v *V) (err error) {
+	if d.ksz >= 0 {
+		var b []byte
+		if b, err = d.read(d.ksz); err != nil {
+			return err
+		}
+		copy(rawBytes(k), b)
+	} else {
+		var b []byte
+		if b, err = d.readPrefixed(); err != nil {
+			return err
+		}
+		if *k, err = d.kc.Decode(b); err != nil {
+			return err
+		}
+	}
+	if d.vsz >= 0 {
+		var b []byte
+		if b, err = d.read(d.vsz); err != nil {
+			return err
+		}
+		copy(rawBytes(v), b)
+	} else {
+		var b []byte
+		if b, err = d.readPrefixed(); err != nil {
+			return err
+		}
+		if *v, err = d.vc.Decode(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *mapDecoder[K, V]) readPrefixed() ([]byte, error) {
+	var (
+		x     uint64
+		shift uint
+	)
+	for i := 0; ; i++ {
+		b, err := d.read(1)
+		if err != nil {
+			return nil, err
+		}
+		if i == binary.MaxVarintLen64 {
+			return nil, fmt.Errorf("%w: length overflow", ErrCorrupt)
+		}
+		if b[0] < 0x80 {
+			x |= uint64(b[0]) << shift
+			break
+		}
+		x |= uint64(b[0]&0x7f) << shift
+		shift += 7
+	}
+	if left := d.left(); x > math.MaxInt32 || (left >= 0 && x > uint64(left)) {
+		return nil, fmt.Errorf("%w: length %d out of range", ErrCorrupt, x)
+	}
+	return d.read(int(x))
+}
+
+// read returns the next |n| bytes, which are valid until the next call.
+// They are read in chunks of at most readChunk bytes, so that the buffer
+// only grows as far as the input holds.
+func (d *mapDecoder[K, V]) read(n int) ([]byte, error) {
+	b := d.buf[:0]
+	for len(b) < n {
+		c := n - len(b)
+		if c > readChunk {
+			c = readChunk
+		}
+		if cap(b)-len(b) < c {
+			b = append(b, make([]byte, c)...)[:len(b)]
+		}
+		m, err := io.ReadFull(d.r, b[len(b):len(b)+c])
+		d.n += int64(m)
+		d.crc = crc32.Update(d.crc, crcTable, b[len(b):len(b)+m])
+		b = b[:len(b)+m]
+		if err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				err = fmt.Errorf("%w: %v", ErrCorrupt, io.ErrUnexpectedEOF)
+			}
+			d.buf = b
+			return b, err
+		}
+	}
+	d.buf = b
+	return b, nil
+}
+
+func (d *mapDecoder[K, V]) finish() error {
+	crc := d.crc
+	t, err := d.read(trailerSize)
+	if err != nil {
+		return err
+	}
+	if binary.LittleEndian.Uint32(t) != crc {
+		return fmt.Errorf("%w: checksum mismatch", ErrCorrupt)
+	}
+	return nil
+}
+
+func nativeBigEndian() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 0
+}
@@ -0,0 +1,221 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBinaryEncoding(t *testing.T) {
+	t.Run("raw", func(t *testing.T) {
+		type point struct {
+			x, y int16
+			z    int64
+		}
+		m := NewMap[uint32, point](0)
+		for i, k := range genUint32Data(1000) {
+			m.Put(k, point{x: int16(i), y: -int16(i), z: int64(k)})
+		}
+		data, err := m.MarshalBinary()
+		require.NoError(t, err)
+		// header, elements and trailer
+		assert.Equal(t, headerSize+1000*(4+16)+4, len(data))
+
+		var act Map[uint32, point]
+		require.NoError(t, act.UnmarshalBinary(data))
+		assert.True(t, Equal(m, &act))
+		// the table was sized up front
+		assert.Equal(t, int(numGroups(1000)), len(act.groups))
+	})
+	t.Run("strings", func(t *testing.T) {
+		type name string
+		m := NewMap8[name, string](0)
+		for i, k := range genStringData(12, 1000) {
+			m.Put(name(k), k[:i%12])
+		}
+		var buf bytes.Buffer
+		n, err := m.WriteTo(&buf)
+		require.NoError(t, err)
+		assert.Equal(t, int64(buf.Len()), n)
+
+		act := NewMap8[name, string](0)
+		act.Put("stale", "value")
+		n2, err := act.ReadFrom(&buf)
+		require.NoError(t, err)
+		assert.Equal(t, n, n2)
+		assert.Equal(t, m.Count(), act.Count())
+		m.Iter(func(k name, v string) (stop bool) {
+			a, ok := act.Get(k)
+			assert.True(t, ok)
+			assert.Equal(t, v, a)
+			return
+		})
+		assert.False(t, act.Has("stale"))
+	})
+	t.Run("codecs", func(t *testing.T) {
+		f := Format[string, []int]{
+			Key:   StringCodec[string]{},
+			Value: jsonCodec[[]int]{},
+		}
+		m := NewMap[string, []int](0)
+		for i, k := range genStringData(8, 100) {
+			m.Put(k, []int{i, i * i})
+		}
+		var buf bytes.Buffer
+		_, err := f.WriteMap(&buf, m)
+		require.NoError(t, err)
+		// an encoding can be loaded by any table width
+		m8 := NewMap8[string, []int](0)
+		_, err = f.ReadMap8(bytes.NewReader(buf.Bytes()), m8)
+		require.NoError(t, err)
+		assert.Equal(t, m.Count(), m8.Count())
+		m.Iter(func(k string, v []int) (stop bool) {
+			a, _ := m8.Get(k)
+			assert.Equal(t, v, a)
+			return
+		})
+
+		_, err = m.MarshalBinary()
+		assert.Error(t, err, "no default Codec for []int")
+		_, err = Format[*int, int]{Key: RawCodec[*int]{}}.WriteMap(&buf, NewMap[*int, int](0))
+		assert.Error(t, err, "RawCodec of a pointer type")
+	})
+	t.Run("empty", func(t *testing.T) {
+		data, err := NewMap[int, int](0).MarshalBinary()
+		require.NoError(t, err)
+		m := NewMap[int, int](0)
+		m.Put(1, 1)
+		require.NoError(t, m.UnmarshalBinary(data))
+		assert.Equal(t, 0, m.Count())
+	})
+}
+
+func TestBinaryEncodingCorrupt(t *testing.T) {
+	m := NewMap[string, int64](0)
+	for i, k := range genStringData(8, 100) {
+		m.Put(k, int64(i))
+	}
+	data, err := m.MarshalBinary()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		data func() []byte
+	}{
+		{"truncated header", func() []byte {
+			return data[:headerSize-1]
+		}},
+		{"truncated payload", func() []byte {
+			return data[:len(data)-10]
+		}},
+		{"header bit flip", func() []byte {
+			c := append([]byte(nil), data...)
+			c[17] ^= 1
+			return c
+		}},
+		{"payload bit flip", func() []byte {
+			c := append([]byte(nil), data...)
+			c[headerSize+20] ^= 1
+			return c
+		}},
+		{"trailing bytes", func() []byte {
+			return append(append([]byte(nil), data...), 0)
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var act Map[string, int64]
+			err := act.UnmarshalBinary(test.data())
+			assert.True(t, errors.Is(err, ErrCorrupt), err)
+			assert.Equal(t, 0, act.Count())
+		})
+	}
+	t.Run("codec mismatch", func(t *testing.T) {
+		var act Map[string, int32]
+		assert.Error(t, act.UnmarshalBinary(data))
+	})
+	t.Run("huge count", func(t *testing.T) {
+		h := append([]byte(nil), data[:headerSize]...)
+		binary.LittleEndian.PutUint64(h[16:], math.MaxUint32-maxAvgGroupLoad)
+		binary.LittleEndian.PutUint32(h[24:], crc32.Checksum(h[:24], crcTable))
+
+		var act Map[string, int64]
+		err := act.UnmarshalBinary(h)
+		assert.True(t, errors.Is(err, ErrCorrupt), err)
+		assert.Nil(t, act.ctrl)
+
+		// the length of a plain io.Reader is unknown
+		_, err = Format[string, int64]{}.ReadMap(io.MultiReader(bytes.NewReader(h)), &act)
+		assert.True(t, errors.Is(err, ErrCorrupt), err)
+		assert.LessOrEqual(t, len(act.groups), int(numGroups(maxPresize)))
+	})
+	t.Run("count past 32 bits", func(t *testing.T) {
+		if uint64(math.MaxInt) < maxElems {
+			t.Skip("counts are bounded by int")
+		}
+		header := func(count uint64) *mapDecoder[string, int64] {
+			h := append([]byte(nil), data[:headerSize]...)
+			binary.LittleEndian.PutUint64(h[16:], count)
+			binary.LittleEndian.PutUint32(h[24:], crc32.Checksum(h[:24], crcTable))
+			dec, err := newMapDecoder(Format[string, int64]{}, io.MultiReader(bytes.NewReader(h)))
+			require.NoError(t, err)
+			return dec
+		}
+		dec := header(maxElems)
+		count, err := dec.header()
+		require.NoError(t, err)
+		assert.Equal(t, uint64(maxElems), count)
+		assert.Equal(t, maxPresize, dec.presize(count))
+		// pretend the input is long enough to hold them all
+		dec.size = math.MaxInt64
+		assert.Equal(t, count, uint64(dec.presize(count)))
+
+		_, err = header(maxElems + 1).header()
+		assert.True(t, errors.Is(err, ErrCorrupt), err)
+	})
+	t.Run("huge length", func(t *testing.T) {
+		h := append([]byte(nil), data[:headerSize]...)
+		var l [binary.MaxVarintLen64]byte
+		p := append(h, l[:binary.PutUvarint(l[:], math.MaxInt32)]...)
+		for _, r := range []io.Reader{bytes.NewReader(p), io.MultiReader(bytes.NewReader(p))} {
+			var act Map[string, int64]
+			_, err := Format[string, int64]{}.ReadMap(r, &act)
+			assert.True(t, errors.Is(err, ErrCorrupt), err)
+			assert.Equal(t, 0, act.Count())
+		}
+	})
+}
+
+type jsonCodec[T any] struct{}
+
+func (jsonCodec[T]) Append(b []byte, v T) ([]byte, error) {
+	buf, err := json.Marshal(v)
+	return append(b, buf...), err
+}
+
+func (jsonCodec[T]) Decode(b []byte) (v T, err error) {
+	err = json.Unmarshal(b, &v)
+	return
+}
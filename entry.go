@@ -0,0 +1,289 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+// GetOrPut returns the value mapped by |key| if one exists. Otherwise,
+// it inserts |value| and returns it. |loaded| is true if |key| was present.
+// |key| is hashed once and the table is probed once.
+func (m *Map[K, V]) GetOrPut(key K, value V) (actual V, loaded bool) {
+	hi, lo := splitHash(m.hash.Hash(key))
+	if m.old != nil {
+		m.migrate(key, hi, lo)
+	}
+	g, s, ok := m.find(key, hi, lo)
+	if ok {
+		return m.groups[g].values[s], true
+	}
+	m.insert(key, value, g, s, hi, lo)
+	return value, false
+}
+
+// Compute calls |fn| with the value mapped by |key|, and whether |key| is
+// present. If |fn| returns keep = true, |key| is mapped to the returned
+// value, otherwise |key| is removed. Compute returns the resulting value
+// and whether |key| is present after the call. |fn| must not modify |m|.
+func (m *Map[K, V]) Compute(key K, fn func(old V, ok bool) (v V, keep bool)) (V, bool) {
+	hi, lo := splitHash(m.hash.Hash(key))
+	if m.old != nil {
+		m.migrate(key, hi, lo)
+	}
+	g, s, ok := m.find(key, hi, lo)
+	var old V
+	if ok {
+		old = m.groups[g].values[s]
+	}
+	v, keep := fn(old, ok)
+	switch {
+	case keep && ok:
+		if m.ext != nil {
+			m.beforeUpdate(g, s, v)
+		}
+		m.groups[g].values[s] = v
+	case keep:
+		m.insert(key, v, g, s, hi, lo)
+	case ok:
+		m.deleteAt(g, s)
+		if m.shrink {
+			m.shrinkIfSparse()
+		}
+	}
+	return v, keep
+}
+
+// Entry is a handle to the slot of a key in a Map, obtained with a single
+// hash and probe. The slot is either occupied by the key, or is the location
+// where the key will be inserted. An Entry is invalidated by any modification
+// of the Map not made through the Entry itself.
+type Entry[K comparable, V any] struct {
+	m     *Map[K, V]
+	key   K
+	g, s  uint32
+	hi    h1
+	lo    h2
+	found bool
+}
+
+// Entry returns the Entry for |key| in |m|.
+func (m *Map[K, V]) Entry(key K) Entry[K, V] {
+	hi, lo := splitHash(m.hash.Hash(key))
+	if m.old != nil {
+		m.migrate(key, hi, lo)
+	}
+	g, s, ok := m.find(key, hi, lo)
+	return Entry[K, V]{m: m, key: key, g: g, s: s, hi: hi, lo: lo, found: ok}
+}
+
+// Key returns the key of |e|.
+func (e Entry[K, V]) Key() K {
+	return e.key
+}
+
+// Found returns true if the key of |e| is present in the Map.
+func (e Entry[K, V]) Found() bool {
+	return e.found
+}
+
+// Value returns the value mapped by the key of |e| if one exists.
+func (e Entry[K, V]) Value() (value V, ok bool) {
+	if e.found {
+		value, ok = e.m.groups[e.g].values[e.s], true
+	}
+	return
+}
+
+// Put maps the key of |e| to |value|, returning the updated Entry.
+func (e Entry[K, V]) Put(value V) Entry[K, V] {
+	if e.found {
+		if e.m.ext != nil {
+			e.m.beforeUpdate(e.g, e.s, value)
+		}
+		e.m.groups[e.g].values[e.s] = value
+		return e
+	}
+	e.g, e.s = e.m.insert(e.key, value, e.g, e.s, e.hi, e.lo)
+	e.found = true
+	return e
+}
+
+// AndModify replaces the value mapped by the key of |e| with the
+// result of |fn| if the key is present, returning the Entry.
+func (e Entry[K, V]) AndModify(fn func(v V) V) Entry[K, V] {
+	if e.found {
+		v := fn(e.m.groups[e.g].values[e.s])
+		if e.m.ext != nil {
+			e.m.beforeUpdate(e.g, e.s, v)
+		}
+		e.m.groups[e.g].values[e.s] = v
+	}
+	return e
+}
+
+// OrInsert inserts |value| if the key of |e| is absent.
+// It returns the value mapped by the key after the call.
+func (e Entry[K, V]) OrInsert(value V) V {
+	if e.found {
+		return e.m.groups[e.g].values[e.s]
+	}
+	e.m.insert(e.key, value, e.g, e.s, e.hi, e.lo)
+	return value
+}
+
+// OrInsertWith inserts the result of |fn| if the key of |e| is absent.
+// It returns the value mapped by the key after the call.
+func (e Entry[K, V]) OrInsertWith(fn func() V) V {
+	if e.found {
+		return e.m.groups[e.g].values[e.s]
+	}
+	value := fn()
+	e.m.insert(e.key, value, e.g, e.s, e.hi, e.lo)
+	return value
+}
+
+// Delete removes the key of |e| from the Map, returning true if it was present.
+func (e Entry[K, V]) Delete() bool {
+	if e.found {
+		e.m.deleteAt(e.g, e.s)
+		if e.m.shrink {
+			e.m.shrinkIfSparse()
+		}
+	}
+	return e.found
+}
+
+// GetOrPut returns the value mapped by |key| if one exists. Otherwise,
+// it inserts |value| and returns it. |loaded| is true if |key| was present.
+// |key| is hashed once and the table is probed once.
+func (m *Map8[K, V]) GetOrPut(key K, value V) (actual V, loaded bool) {
+	hi, lo := splitHash8(m.hash.Hash(key))
+	g, s, ok := m.find(key, hi, lo)
+	if ok {
+		return m.groups[g].values[s], true
+	}
+	m.insert(key, value, g, s, lo)
+	return value, false
+}
+
+// Compute calls |fn| with the value mapped by |key|, and whether |key| is
+// present. If |fn| returns keep = true, |key| is mapped to the returned
+// value, otherwise |key| is removed. Compute returns the resulting value
+// and whether |key| is present after the call. |fn| must not modify |m|.
+func (m *Map8[K, V]) Compute(key K, fn func(old V, ok bool) (v V, keep bool)) (V, bool) {
+	hi, lo := splitHash8(m.hash.Hash(key))
+	g, s, ok := m.find(key, hi, lo)
+	var old V
+	if ok {
+		old = m.groups[g].values[s]
+	}
+	v, keep := fn(old, ok)
+	switch {
+	case keep && ok:
+		m.groups[g].values[s] = v
+	case keep:
+		m.insert(key, v, g, s, lo)
+	case ok:
+		m.deleteAt(g, s)
+		if m.shrink {
+			m.shrinkIfSparse()
+		}
+	}
+	return v, keep
+}
+
+// Entry8 is a handle to the slot of a key in a Map8. See Entry.
+type Entry8[K comparable, V any] struct {
+	m     *Map8[K, V]
+	key   K
+	g, s  uint32
+	lo    h2E8
+	found bool
+}
+
+// Entry returns the Entry8 for |key| in |m|.
+func (m *Map8[K, V]) Entry(key K) Entry8[K, V] {
+	hi, lo := splitHash8(m.hash.Hash(key))
+	g, s, ok := m.find(key, hi, lo)
+	return Entry8[K, V]{m: m, key: key, g: g, s: s, lo: lo, found: ok}
+}
+
+// Key returns the key of |e|.
+func (e Entry8[K, V]) Key() K {
+	return e.key
+}
+
+// Found returns true if the key of |e| is present in the Map8.
+func (e Entry8[K, V]) Found() bool {
+	return e.found
+}
+
+// Value returns the value mapped by the key of |e| if one exists.
+func (e Entry8[K, V]) Value() (value V, ok bool) {
+	if e.found {
+		value, ok = e.m.groups[e.g].values[e.s], true
+	}
+	return
+}
+
+// Put maps the key of |e| to |value|, returning the updated Entry8.
+func (e Entry8[K, V]) Put(value V) Entry8[K, V] {
+	if e.found {
+		e.m.groups[e.g].values[e.s] = value
+		return e
+	}
+	e.g, e.s = e.m.insert(e.key, value, e.g, e.s, e.lo)
+	e.found = true
+	return e
+}
+
+// AndModify replaces the value mapped by the key of |e| with the
+// result of |fn| if the key is present, returning the Entry8.
+func (e Entry8[K, V]) AndModify(fn func(v V) V) Entry8[K, V] {
+	if e.found {
+		v := &e.m.groups[e.g].values[e.s]
+		*v = fn(*v)
+	}
+	return e
+}
+
+// OrInsert inserts |value| if the key of |e| is absent.
+// It returns the value mapped by the key after the call.
+func (e Entry8[K, V]) OrInsert(value V) V {
+	if e.found {
+		return e.m.groups[e.g].values[e.s]
+	}
+	e.m.insert(e.key, value, e.g, e.s, e.lo)
+	return value
+}
+
+// OrInsertWith inserts the result of |fn| if the key of |e| is absent.
+// It returns the value mapped by the key after the call.
+func (e Entry8[K, V]) OrInsertWith(fn func() V) V {
+	if e.found {
+		return e.m.groups[e.g].values[e.s]
+	}
+	value := fn()
+	e.m.insert(e.key, value, e.g, e.s, e.lo)
+	return value
+}
+
+// Delete removes the key of |e| from the Map8, returning true if it was present.
+func (e Entry8[K, V]) Delete() bool {
+	if e.found {
+		e.m.deleteAt(e.g, e.s)
+		if e.m.shrink {
+			e.m.shrinkIfSparse()
+		}
+	}
+	return e.found
+}
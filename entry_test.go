@@ -0,0 +1,163 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// genCounts returns |n| keys drawn from |keys| with repetition.
+func genCounts[K comparable](keys []K, n int) (stream []K, golden map[K]int) {
+	stream = make([]K, n)
+	golden = make(map[K]int, len(keys))
+	for i := range stream {
+		stream[i] = keys[rand.Intn(len(keys))]
+		golden[stream[i]]++
+	}
+	return
+}
+
+func TestMapEntry(t *testing.T) {
+	keys := genStringData(8, 1000)
+	stream, golden := genCounts(keys, 10_000)
+
+	t.Run("get or put", func(t *testing.T) {
+		m := NewMap[string, int](0)
+		for i, k := range keys {
+			act, loaded := m.GetOrPut(k, i)
+			assert.False(t, loaded)
+			assert.Equal(t, i, act)
+		}
+		for i, k := range keys {
+			act, loaded := m.GetOrPut(k, -i)
+			assert.True(t, loaded)
+			assert.Equal(t, i, act)
+		}
+		assert.Equal(t, len(keys), m.Count())
+	})
+	t.Run("compute", func(t *testing.T) {
+		m := NewMap[string, int](0)
+		for _, k := range stream {
+			m.Compute(k, func(old int, ok bool) (int, bool) {
+				return old + 1, true
+			})
+		}
+		assertCounts(t, golden, m.Get, m.Count())
+		// remove every key with an odd count
+		for _, k := range keys {
+			v, ok := m.Compute(k, func(old int, ok bool) (int, bool) {
+				return old, old%2 == 0
+			})
+			assert.Equal(t, ok, m.Has(k))
+			if ok {
+				assert.Equal(t, golden[k], v)
+			}
+		}
+		m.Iter(func(k string, v int) (stop bool) {
+			assert.Equal(t, 0, v%2)
+			return
+		})
+	})
+	t.Run("entry", func(t *testing.T) {
+		m := NewMap[string, int](0)
+		for _, k := range stream {
+			m.Entry(k).AndModify(func(v int) int {
+				return v + 1
+			}).OrInsert(1)
+		}
+		assertCounts(t, golden, m.Get, m.Count())
+		for _, k := range keys {
+			e := m.Entry(k)
+			assert.Equal(t, k, e.Key())
+			v, ok := e.Value()
+			assert.Equal(t, golden[k] > 0, e.Found())
+			assert.Equal(t, golden[k] > 0, ok)
+			assert.Equal(t, golden[k], v)
+			assert.Equal(t, e.Found(), e.Delete())
+			assert.False(t, m.Has(k))
+			e = m.Entry(k).Put(-1)
+			assert.True(t, e.Found())
+			assert.Equal(t, -1, m.Entry(k).OrInsertWith(func() int {
+				panic("unexpected call")
+			}))
+		}
+		assert.Equal(t, len(keys), m.Count())
+	})
+}
+
+func TestMap8Entry(t *testing.T) {
+	keys := genUint32Data8(1000)
+	stream, golden := genCounts(keys, 10_000)
+
+	t.Run("get or put", func(t *testing.T) {
+		m := NewMap8[uint32, int](0)
+		for i, k := range keys {
+			act, loaded := m.GetOrPut(k, i)
+			assert.False(t, loaded)
+			assert.Equal(t, i, act)
+		}
+		for i, k := range keys {
+			act, loaded := m.GetOrPut(k, -i)
+			assert.True(t, loaded)
+			assert.Equal(t, i, act)
+		}
+		assert.Equal(t, len(keys), m.Count())
+	})
+	t.Run("compute", func(t *testing.T) {
+		m := NewMap8[uint32, int](0)
+		for _, k := range stream {
+			m.Compute(k, func(old int, ok bool) (int, bool) {
+				return old + 1, true
+			})
+		}
+		assertCounts(t, golden, m.Get, m.Count())
+		for _, k := range keys {
+			m.Compute(k, func(old int, ok bool) (int, bool) {
+				return 0, false
+			})
+		}
+		assert.Equal(t, 0, m.Count())
+	})
+	t.Run("entry", func(t *testing.T) {
+		m := NewMap8[uint32, int](0)
+		for _, k := range stream {
+			m.Entry(k).AndModify(func(v int) int {
+				return v + 1
+			}).OrInsertWith(func() int {
+				return 1
+			})
+		}
+		assertCounts(t, golden, m.Get, m.Count())
+		for _, k := range keys {
+			e := m.Entry(k)
+			assert.Equal(t, golden[k] > 0, e.Found())
+			assert.Equal(t, e.Found(), e.Delete())
+			assert.Equal(t, 7, m.Entry(k).Put(7).OrInsert(8))
+		}
+		assert.Equal(t, len(keys), m.Count())
+	})
+}
+
+func assertCounts[K comparable](t *testing.T, golden map[K]int, get func(K) (int, bool), count int) {
+	assert.Equal(t, len(golden), count)
+	for k, exp := range golden {
+		act, ok := get(k)
+		assert.True(t, ok)
+		assert.Equal(t, exp, act)
+	}
+}
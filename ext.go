@@ -0,0 +1,115 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+// mapExt holds the optional state of a Map. Writers
+// check a single pointer to see if any of it is in use.
+type mapExt[K comparable, V any] struct {
+	// snaps are the Snapshots sharing the table of the Map
+	snaps []*Snapshot[K, V]
+	// digest is maintained once EnableDigest is called
+	digest *digest[K, V]
+	// observers receive the Events of the Map
+	observers []*observer[K, V]
+}
+
+// releaseExt drops |m.ext| once none of its state is in use.
+func (m *Map[K, V]) releaseExt() {
+	if len(m.ext.snaps) == 0 && m.ext.digest == nil && len(m.ext.observers) == 0 {
+		m.ext = nil
+	}
+}
+
+// observed returns true if |m| has observers.
+func (m *Map[K, V]) observed() bool {
+	return m.ext != nil && len(m.ext.observers) > 0
+}
+
+// The hooks below are called by writers while |m.ext| is non-nil.
+
+// beforeUpdate is called before the value in slot |s| of group |g| is replaced by |value|.
+func (m *Map[K, V]) beforeUpdate(g, s uint32, value V) {
+	if d := m.ext.digest; d != nil {
+		k := &m.groups[g].keys[s]
+		d.sum += d.entry(k, &value) - d.entry(k, &m.groups[g].values[s])
+	}
+	m.preserve(g)
+	if m.observed() {
+		m.notify(Event[K, V]{Op: OpPut, Key: m.groups[g].keys[s], Value: value, Old: m.groups[g].values[s], HasOld: true})
+	}
+}
+
+// beforeInsert is called before |key| and |value| are inserted into group |g|.
+func (m *Map[K, V]) beforeInsert(g uint32, key K, value V) {
+	if d := m.ext.digest; d != nil {
+		d.sum += d.entry(&key, &value)
+	}
+	m.preserve(g)
+	if m.observed() {
+		m.notify(Event[K, V]{Op: OpPut, Key: key, Value: value})
+	}
+}
+
+// beforeDelete is called before the element in slot |s| of group |g| is deleted.
+func (m *Map[K, V]) beforeDelete(g, s uint32) {
+	if d := m.ext.digest; d != nil {
+		d.sum -= d.entry(&m.groups[g].keys[s], &m.groups[g].values[s])
+	}
+	m.preserve(g)
+	if m.observed() {
+		m.notify(Event[K, V]{Op: OpDelete, Key: m.groups[g].keys[s], Old: m.groups[g].values[s], HasOld: true})
+	}
+}
+
+// beforeWriteThrough is called before a pointer to a value in group |g|
+// is returned, through which the value may be modified at any time.
+func (m *Map[K, V]) beforeWriteThrough(g uint32) {
+	if d := m.ext.digest; d != nil {
+		d.stale = true
+	}
+	m.preserve(g)
+}
+
+// afterClear is called after every element is removed.
+func (m *Map[K, V]) afterClear() {
+	if d := m.ext.digest; d != nil {
+		d.sum, d.stale = 0, false
+	}
+	if m.observed() {
+		m.notify(Event[K, V]{Op: OpClear})
+	}
+}
+
+// afterReplace is called after the table of an empty Map is replaced
+// wholesale.
+func (m *Map[K, V]) afterReplace() {
+	if d := m.ext.digest; d != nil {
+		d.stale = true
+	}
+	if m.observed() {
+		for g := range m.ctrl {
+			for s, c := range m.ctrl[g] {
+				if c == empty || c == tombstone {
+					continue
+				}
+				if !m.observed() {
+					// an observer canceled itself
+					return
+				}
+				m.notify(Event[K, V]{Op: OpPut, Key: m.groups[g].keys[s], Value: m.groups[g].values[s]})
+			}
+		}
+	}
+}
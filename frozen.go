@@ -0,0 +1,308 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"unsafe"
+)
+
+// The encoding of a Frozen map is its table, so that it can be queried in
+// place. It is a header of mappedHeaderSize bytes, followed by the control
+// bytes of every group and then, at the next multiple of mappedAlign, the
+// groups themselves as laid out in memory. Integers in the header are
+// little-endian:
+//
+//	magic       [4]byte  "SWFZ"
+//	version     uint16   frozenVersion
+//	width       uint8    group width
+//	flags       uint8    flagBigEndian if keys and values are big-endian
+//	keySize     uint32   size of K
+//	valueSize   uint32   size of V
+//	groupSize   uint32   size of a group
+//	reserved    uint32
+//	groups      uint64   number of groups
+//	count       uint64   number of elements
+//	seed        uint64   seed of the hash of keys
+//	tableCRC    uint32   CRC-32C of the control bytes and groups
+//	headerCRC   uint32   CRC-32C of the preceding fields
+//
+// An encoding can only be opened on platforms with the same group width
+// and byte order as the one it was built on.
+
+const (
+	frozenVersion    = 1
+	mappedHeaderSize = 64
+	mappedAlign      = 64
+)
+
+var frozenMagic = [4]byte{'S', 'W', 'F', 'Z'}
+
+// Frozen is a read-only hash map whose encoding is its table. An encoded
+// Frozen map can be opened over a byte slice or a memory-mapped file and
+// queried without decoding it. K and V must not contain pointers, and K
+// must not contain floats or padding, as keys are hashed by their memory.
+type Frozen[K comparable, V any] struct {
+	ctrl   []metadata
+	groups []group[K, V]
+	seed   uint64
+	count  int
+	// data is the encoding the Frozen map was opened
+	// from, which is released by unmap if it's mapped
+	data  []byte
+	unmap func(data []byte) error
+}
+
+// Freeze builds a Frozen map holding the elements of |m|.
+func Freeze[K comparable, V any](m *Map[K, V]) (*Frozen[K, V], error) {
+	if err := checkFrozenTypes[K, V](); err != nil {
+		return nil, err
+	}
+	n := numGroups64(uint64(m.Count()))
+	if n > maxGroups {
+		return nil, fmt.Errorf("swiss: %d elements are too many to freeze", m.Count())
+	}
+	f := &Frozen[K, V]{
+		ctrl:   make([]metadata, n),
+		groups: make([]group[K, V], n),
+		seed:   uint64(fastrand())<<32 | uint64(fastrand()),
+		count:  m.Count(),
+	}
+	for i := range f.ctrl {
+		f.ctrl[i] = newEmptyMetadata()
+	}
+	m.Iter(func(k K, v V) (stop bool) {
+		hi, lo := splitHash(f.hash(&k))
+		g := probeStart32(hi, len(f.groups))
+		for {
+			if matches := metaMatchEmpty(&f.ctrl[g]); matches != 0 {
+				s := nextMatch(&matches)
+				f.ctrl[g][s] = int8(lo)
+				f.groups[g].keys[s], f.groups[g].values[s] = k, v
+				return
+			}
+			g += 1 // linear probing
+			if g >= uint32(len(f.groups)) {
+				g = 0
+			}
+		}
+	})
+	return f, nil
+}
+
+// OpenFrozen opens the Frozen map encoded in |data|, which it references
+// rather than copies. |data| must be aligned to 8 bytes, as memory-mapped
+// files and slices allocated by make are, and must not be modified while
+// the Frozen map is in use. Only the header is validated, see Verify.
+func OpenFrozen[K comparable, V any](data []byte) (*Frozen[K, V], error) {
+	if err := checkFrozenTypes[K, V](); err != nil {
+		return nil, err
+	}
+	if len(data) < mappedHeaderSize {
+		return nil, fmt.Errorf("%w: %d bytes is too short for a Frozen map", ErrCorrupt, len(data))
+	}
+	h := data[:mappedHeaderSize]
+	if !bytes.Equal(h[:4], frozenMagic[:]) {
+		return nil, fmt.Errorf("%w: bad magic %q", ErrCorrupt, h[:4])
+	}
+	if crc32.Checksum(h[:52], crcTable) != binary.LittleEndian.Uint32(h[52:]) {
+		return nil, fmt.Errorf("%w: header checksum mismatch", ErrCorrupt)
+	}
+	if v := binary.LittleEndian.Uint16(h[4:]); v != frozenVersion {
+		return nil, fmt.Errorf("swiss: unsupported Frozen version %d", v)
+	}
+	var (
+		k K
+		v V
+		g group[K, V]
+	)
+	switch {
+	case h[6] != groupSize:
+		return nil, fmt.Errorf("swiss: Frozen map of width %d, not %d", h[6], groupSize)
+	case (h[7]&flagBigEndian != 0) != nativeBigEndian():
+		return nil, fmt.Errorf("swiss: Frozen map has foreign byte order")
+	case binary.LittleEndian.Uint32(h[8:]) != uint32(unsafe.Sizeof(k)),
+		binary.LittleEndian.Uint32(h[12:]) != uint32(unsafe.Sizeof(v)),
+		binary.LittleEndian.Uint32(h[16:]) != uint32(unsafe.Sizeof(g)):
+		return nil, fmt.Errorf("swiss: Frozen map does not hold %T keys and %T values", k, v)
+	case uintptr(unsafe.Pointer(&data[0]))%unsafe.Alignof(g) != 0:
+		return nil, fmt.Errorf("swiss: Frozen map data is not aligned")
+	}
+	n := binary.LittleEndian.Uint64(h[24:])
+	count := binary.LittleEndian.Uint64(h[32:])
+	if n == 0 || n > maxGroups || n > uint64(len(data))/groupSize || count > n*maxAvgGroupLoad {
+		return nil, fmt.Errorf("%w: %d groups of %d elements", ErrCorrupt, n, count)
+	}
+	off := mappedGroupsOffset(int(n))
+	if uint64(len(data)-off)/uint64(unsafe.Sizeof(g)) < n || off+int(n)*int(unsafe.Sizeof(g)) != len(data) {
+		return nil, fmt.Errorf("%w: %d bytes do not hold %d groups", ErrCorrupt, len(data), n)
+	}
+	return &Frozen[K, V]{
+		ctrl:   unsafe.Slice((*metadata)(unsafe.Pointer(&data[mappedHeaderSize])), n),
+		groups: unsafe.Slice((*group[K, V])(unsafe.Pointer(&data[off])), n),
+		seed:   binary.LittleEndian.Uint64(h[40:]),
+		count:  int(count),
+		data:   data,
+	}, nil
+}
+
+// Has returns true if |key| is present in |f|.
+func (f *Frozen[K, V]) Has(key K) (ok bool) {
+	_, ok = f.Get(key)
+	return
+}
+
+// Get returns the |value| mapped by |key| if one exists.
+func (f *Frozen[K, V]) Get(key K) (value V, ok bool) {
+	hi, lo := splitHash(f.hash(&key))
+	g := probeStart32(hi, len(f.groups))
+	// a corrupt table may have no empty slot, so
+	// probe each group at most once
+	for i := 0; i < len(f.groups); i++ {
+		matches := metaMatchH2(&f.ctrl[g], lo)
+		for matches != 0 {
+			s := nextMatch(&matches)
+			if key == f.groups[g].keys[s] {
+				value, ok = f.groups[g].values[s], true
+				return
+			}
+		}
+		// |key| is not in group |g|,
+		// stop probing if we see an empty slot
+		if metaMatchEmpty(&f.ctrl[g]) != 0 {
+			return
+		}
+		g += 1 // linear probing
+		if g >= uint32(len(f.groups)) {
+			g = 0
+		}
+	}
+	return
+}
+
+// Iter iterates the elements of the Frozen map, passing them to the
+// callback. Elements are visited in table order.
+func (f *Frozen[K, V]) Iter(cb func(k K, v V) (stop bool)) {
+	for g := range f.ctrl {
+		for s, c := range f.ctrl[g] {
+			if c == empty {
+				continue
+			}
+			if stop := cb(f.groups[g].keys[s], f.groups[g].values[s]); stop {
+				return
+			}
+		}
+	}
+}
+
+// Count returns the number of elements in the Frozen map.
+func (f *Frozen[K, V]) Count() int {
+	return f.count
+}
+
+// Verify checks the table of |f| against the checksum of its encoding.
+func (f *Frozen[K, V]) Verify() error {
+	if f.data == nil {
+		return nil
+	}
+	if binary.LittleEndian.Uint32(f.data[48:]) != f.tableCRC() {
+		return fmt.Errorf("%w: table checksum mismatch", ErrCorrupt)
+	}
+	return nil
+}
+
+// WriteTo implements io.WriterTo, writing the encoding of |f|.
+func (f *Frozen[K, V]) WriteTo(w io.Writer) (int64, error) {
+	var (
+		k K
+		v V
+		h [mappedHeaderSize]byte
+		n int64
+	)
+	copy(h[:4], frozenMagic[:])
+	binary.LittleEndian.PutUint16(h[4:], frozenVersion)
+	h[6] = groupSize
+	if nativeBigEndian() {
+		h[7] = flagBigEndian
+	}
+	binary.LittleEndian.PutUint32(h[8:], uint32(unsafe.Sizeof(k)))
+	binary.LittleEndian.PutUint32(h[12:], uint32(unsafe.Sizeof(v)))
+	binary.LittleEndian.PutUint32(h[16:], uint32(unsafe.Sizeof(f.groups[0])))
+	binary.LittleEndian.PutUint64(h[24:], uint64(len(f.groups)))
+	binary.LittleEndian.PutUint64(h[32:], uint64(f.count))
+	binary.LittleEndian.PutUint64(h[40:], f.seed)
+	binary.LittleEndian.PutUint32(h[48:], f.tableCRC())
+	binary.LittleEndian.PutUint32(h[52:], crc32.Checksum(h[:52], crcTable))
+
+	ctrl, groups := f.tables()
+	pad := make([]byte, mappedGroupsOffset(len(f.groups))-mappedHeaderSize-len(ctrl))
+	for _, b := range [][]byte{h[:], ctrl, pad, groups} {
+		c, err := w.Write(b)
+		n += int64(c)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Close releases the memory mapping |f| was opened from, if any.
+// The Frozen map must not be used after it is closed.
+func (f *Frozen[K, V]) Close() (err error) {
+	if f.unmap != nil {
+		err = f.unmap(f.data)
+	}
+	*f = Frozen[K, V]{}
+	return
+}
+
+func (f *Frozen[K, V]) hash(key *K) uint64 {
+	return wyhash(f.seed, rawBytes(key))
+}
+
+// tables returns the memory of the control bytes and groups of |f|.
+func (f *Frozen[K, V]) tables() (ctrl, groups []byte) {
+	ctrl = unsafe.Slice((*byte)(unsafe.Pointer(&f.ctrl[0])), len(f.ctrl)*groupSize)
+	groups = unsafe.Slice((*byte)(unsafe.Pointer(&f.groups[0])), uintptr(len(f.groups))*unsafe.Sizeof(f.groups[0]))
+	return
+}
+
+func (f *Frozen[K, V]) tableCRC() uint32 {
+	ctrl, groups := f.tables()
+	return crc32.Update(crc32.Checksum(ctrl, crcTable), crcTable, groups)
+}
+
+// mappedGroupsOffset returns the offset of the groups in
+// the file of a Frozen map or SharedMap of |n| groups.
+func mappedGroupsOffset(n int) int {
+	off := mappedHeaderSize + n*groupSize
+	return (off + mappedAlign - 1) &^ (mappedAlign - 1)
+}
+
+func checkFrozenTypes[K comparable, V any]() error {
+	if !isMemComparable[K]() {
+		var k K
+		return fmt.Errorf("swiss: Frozen map of %T keys, which are not compared by their memory", k)
+	}
+	if hasPointers[V]() {
+		var v V
+		return fmt.Errorf("swiss: Frozen map of %T values, which contain pointers", v)
+	}
+	return nil
+}
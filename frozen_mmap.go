@@ -0,0 +1,51 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// OpenFrozenFile opens the Frozen map encoded in the file at |path|,
+// which it maps into memory read-only. The mapping is released by Close.
+func OpenFrozenFile[K comparable, V any](path string) (*Frozen[K, V], error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() < mappedHeaderSize || int64(int(info.Size())) != info.Size() {
+		return nil, fmt.Errorf("%w: %s is %d bytes", ErrCorrupt, path, info.Size())
+	}
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, &os.PathError{Op: "mmap", Path: path, Err: err}
+	}
+	f, err := OpenFrozen[K, V](data)
+	if err != nil {
+		_ = syscall.Munmap(data)
+		return nil, err
+	}
+	f.unmap = syscall.Munmap
+	return f, nil
+}
@@ -0,0 +1,40 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"os"
+	"unsafe"
+)
+
+// OpenFrozenFile opens the Frozen map encoded in the file at |path|.
+// Memory mapping is not supported on this platform, so the file is read
+// into memory.
+func OpenFrozenFile[K comparable, V any](path string) (*Frozen[K, V], error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < mappedHeaderSize {
+		return OpenFrozen[K, V](b)
+	}
+	// copy |b| to memory aligned for any group
+	words := make([]uint64, (len(b)+7)/8)
+	data := unsafe.Slice((*byte)(unsafe.Pointer(&words[0])), len(b))
+	copy(data, b)
+	return OpenFrozen[K, V](data)
+}
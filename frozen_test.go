@@ -0,0 +1,161 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrozen(t *testing.T) {
+	type sku struct {
+		vendor uint32
+		item   uint32
+	}
+	type entry struct {
+		price int64
+		stock int32
+		flags uint8
+	}
+	m := NewMap[sku, entry](0)
+	for i, k := range genUint32Data(5000) {
+		m.Put(sku{vendor: k % 97, item: k}, entry{price: int64(i), stock: int32(-i)})
+	}
+	f, err := Freeze(m)
+	require.NoError(t, err)
+	assertFrozen(t, m, f)
+
+	var buf bytes.Buffer
+	n, err := f.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+
+	t.Run("bytes", func(t *testing.T) {
+		act, err := OpenFrozen[sku, entry](buf.Bytes())
+		require.NoError(t, err)
+		require.NoError(t, act.Verify())
+		assertFrozen(t, m, act)
+		require.NoError(t, act.Close())
+	})
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "skus.swfz")
+		require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+		act, err := OpenFrozenFile[sku, entry](path)
+		require.NoError(t, err)
+		require.NoError(t, act.Verify())
+		assertFrozen(t, m, act)
+		require.NoError(t, act.Close())
+
+		_, err = OpenFrozenFile[sku, entry](filepath.Join(t.TempDir(), "missing"))
+		assert.True(t, errors.Is(err, os.ErrNotExist))
+	})
+	t.Run("empty", func(t *testing.T) {
+		f, err := Freeze(NewMap[uint64, uint64](0))
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		_, err = f.WriteTo(&buf)
+		require.NoError(t, err)
+		act, err := OpenFrozen[uint64, uint64](buf.Bytes())
+		require.NoError(t, err)
+		assert.Equal(t, 0, act.Count())
+		assert.False(t, act.Has(0))
+	})
+	t.Run("mismatch", func(t *testing.T) {
+		data := buf.Bytes()
+		_, err := OpenFrozen[sku, int64](data)
+		assert.Error(t, err)
+		_, err = OpenFrozen[sku, entry](data[:len(data)-1])
+		assert.True(t, errors.Is(err, ErrCorrupt), err)
+		_, err = OpenFrozen[sku, entry](data[1 : len(data)-7])
+		assert.Error(t, err)
+
+		c := append([]byte(nil), data...)
+		c[30] ^= 1
+		_, err = OpenFrozen[sku, entry](c)
+		assert.True(t, errors.Is(err, ErrCorrupt), err)
+
+		c = append([]byte(nil), data...)
+		c[len(c)-1] ^= 1
+		act, err := OpenFrozen[sku, entry](c)
+		require.NoError(t, err)
+		assert.True(t, errors.Is(act.Verify(), ErrCorrupt))
+
+		// control bytes without an empty slot
+		c = append([]byte(nil), data...)
+		ctrl := c[mappedHeaderSize : mappedHeaderSize+len(act.ctrl)*groupSize]
+		for i := range ctrl {
+			ctrl[i] = 0
+		}
+		act, err = OpenFrozen[sku, entry](c)
+		require.NoError(t, err)
+		assert.True(t, errors.Is(act.Verify(), ErrCorrupt))
+		// vendors are less than 97, so this key is missing
+		assert.False(t, act.Has(sku{vendor: 97}))
+	})
+	t.Run("key types", func(t *testing.T) {
+		_, err := Freeze(NewMap[float64, int](0))
+		assert.Error(t, err)
+		_, err = Freeze(NewMap[string, int](0))
+		assert.Error(t, err)
+		_, err = Freeze(NewMap[int, *int](0))
+		assert.Error(t, err)
+	})
+}
+
+func TestWyhash(t *testing.T) {
+	// the test vectors of the reference implementation,
+	// hashes are persisted and must never change
+	tests := []struct {
+		data string
+		hash uint64
+	}{
+		{"", 0x0409638ee2bde459},
+		{"a", 0xa8412d091b5fe0a9},
+		{"abc", 0x32dd92e4b2915153},
+		{"message digest", 0x8619124089a3a16b},
+		{"abcdefghijklmnopqrstuvwxyz", 0x7a43afb61d7f5f40},
+		{"ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789", 0xff42329b90e50d58},
+		{strings.Repeat("1234567890", 8), 0xc39cab13b115aad3},
+	}
+	for seed, test := range tests {
+		assert.Equal(t, test.hash, wyhash(uint64(seed), []byte(test.data)), test.data)
+	}
+}
+
+func assertFrozen[K comparable, V any](t *testing.T, exp *Map[K, V], f *Frozen[K, V]) {
+	assert.Equal(t, exp.Count(), f.Count())
+	exp.Iter(func(k K, v V) (stop bool) {
+		a, ok := f.Get(k)
+		assert.True(t, ok)
+		assert.Equal(t, v, a)
+		return
+	})
+	n := 0
+	f.Iter(func(k K, v V) (stop bool) {
+		assert.Equal(t, must(exp.Get(k)), v)
+		n++
+		return
+	})
+	assert.Equal(t, exp.Count(), n)
+	var missing K
+	assert.Equal(t, exp.Has(missing), f.Has(missing))
+}
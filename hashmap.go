@@ -0,0 +1,256 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+// HashMap is a Map with user-supplied hash and equality functions.
+// Keys need not be comparable, so HashMap can be keyed by []byte,
+// by case-insensitive strings, or by a subset of a struct's fields.
+type HashMap[K any, V any] struct {
+	ctrl     []metadata
+	groups   []group[K, V]
+	hash     func(key K) uint64
+	equal    func(a, b K) bool
+	resident uint64
+	dead     uint64
+	limit    uint64
+	// ptrs is true if K or V contain pointers,
+	// which removed slots must release
+	ptrs bool
+}
+
+// NewHashMap constructs a HashMap. |hash| and |equal| must agree:
+// keys that are equal must have the same hash. All 64 bits of the
+// hash are used, so |hash| should distribute its output across them.
+// HashMap cannot reseed |hash|, so it should be seeded by the caller
+// if keys are untrusted.
+func NewHashMap[K any, V any](sz uint32, hash func(key K) uint64, equal func(a, b K) bool) (m *HashMap[K, V]) {
+	groups := numGroups(sz)
+	m = &HashMap[K, V]{
+		ctrl:   make([]metadata, groups),
+		groups: make([]group[K, V], groups),
+		hash:   hash,
+		equal:  equal,
+		limit:  defaultLimit(groups),
+		ptrs:   hasPointers[K]() || hasPointers[V](),
+	}
+	for i := range m.ctrl {
+		m.ctrl[i] = newEmptyMetadata()
+	}
+	return
+}
+
+// Has returns true if |key| is present in |m|.
+func (m *HashMap[K, V]) Has(key K) (ok bool) {
+	hi, lo := splitHash(m.hash(key))
+	_, _, ok = m.find(key, hi, lo)
+	return
+}
+
+// Get returns the |value| mapped by |key| if one exists.
+func (m *HashMap[K, V]) Get(key K) (value V, ok bool) {
+	hi, lo := splitHash(m.hash(key))
+	g, s, ok := m.find(key, hi, lo)
+	if ok {
+		value = m.groups[g].values[s]
+	}
+	return
+}
+
+// Put attempts to insert |key| and |value|
+func (m *HashMap[K, V]) Put(key K, value V) {
+	hi, lo := splitHash(m.hash(key))
+	g, s, ok := m.find(key, hi, lo)
+	if ok { // update
+		m.groups[g].keys[s] = key
+		m.groups[g].values[s] = value
+		return
+	}
+	if m.resident >= m.limit {
+		m.rehash(m.nextSize())
+		g, s, _ = m.find(key, hi, lo)
+	}
+	m.groups[g].keys[s] = key
+	m.groups[g].values[s] = value
+	m.ctrl[g][s] = int8(lo)
+	m.resident++
+}
+
+// Delete attempts to remove |key|, returns true successful.
+func (m *HashMap[K, V]) Delete(key K) (ok bool) {
+	hi, lo := splitHash(m.hash(key))
+	g, s, ok := m.find(key, hi, lo)
+	if !ok {
+		return false
+	}
+	// see Map.deleteAt
+	if metaMatchEmpty(&m.ctrl[g]) != 0 {
+		m.ctrl[g][s] = empty
+		m.resident--
+	} else {
+		m.ctrl[g][s] = tombstone
+		m.dead++
+	}
+	if m.ptrs {
+		var k K
+		var v V
+		m.groups[g].keys[s], m.groups[g].values[s] = k, v
+	}
+	return true
+}
+
+// Iter iterates the elements of the HashMap, passing them to the
+// callback. It provides the same guarantees as Map.Iter.
+func (m *HashMap[K, V]) Iter(cb func(k K, v V) (stop bool)) {
+	// take a consistent view of the table in case
+	// we rehash during iteration
+	ctrl, groups := m.ctrl, m.groups
+	// pick a random starting group
+	g := randIntN(len(groups))
+	for n := 0; n < len(groups); n++ {
+		for s, c := range ctrl[g] {
+			if c == empty || c == tombstone {
+				continue
+			}
+			k, v := groups[g].keys[s], groups[g].values[s]
+			if stop := cb(k, v); stop {
+				return
+			}
+		}
+		g++
+		if g >= uint32(len(groups)) {
+			g = 0
+		}
+	}
+}
+
+// Clear removes all elements from the HashMap.
+func (m *HashMap[K, V]) Clear() {
+	for i, c := range m.ctrl {
+		for j := range c {
+			m.ctrl[i][j] = empty
+		}
+	}
+	if m.ptrs {
+		for i := range m.groups {
+			m.groups[i] = group[K, V]{}
+		}
+	}
+	m.resident, m.dead = 0, 0
+}
+
+// Count returns the number of elements in the HashMap.
+func (m *HashMap[K, V]) Count() int {
+	return int(m.resident - m.dead)
+}
+
+// Capacity returns the number of additional elements
+// the can be added to the HashMap before resizing.
+func (m *HashMap[K, V]) Capacity() int {
+	return int(m.limit - m.resident)
+}
+
+// Grow ensures that |n| more elements can be added
+// to the HashMap without rehashing.
+func (m *HashMap[K, V]) Grow(n int) {
+	if n <= m.Capacity() {
+		return
+	}
+	m.rehash(defaultGroups(tableSize64(m.Count() + n)))
+}
+
+// find returns the location of |key| if present, or its insertion location if absent.
+func (m *HashMap[K, V]) find(key K, hi h1, lo h2) (g, s uint32, ok bool) {
+	g = probeStart(hi, len(m.groups))
+	for {
+		matches := metaMatchH2(&m.ctrl[g], lo)
+		for matches != 0 {
+			s = nextMatch(&matches)
+			if m.equal(key, m.groups[g].keys[s]) {
+				return g, s, true
+			}
+		}
+		// |key| is not in group |g|,
+		// stop probing if we see an empty slot
+		matches = metaMatchEmpty(&m.ctrl[g])
+		if matches != 0 {
+			s = nextMatch(&matches)
+			return g, s, false
+		}
+		g += 1 // linear probing
+		if g >= uint32(len(m.groups)) {
+			g = 0
+		}
+	}
+}
+
+func (m *HashMap[K, V]) nextSize() uint32 {
+	n := uint64(len(m.groups))
+	if m.dead >= (m.resident / 2) {
+		return uint32(n)
+	}
+	if n == maxGroups {
+		panic("swiss: table size out of range")
+	}
+	if n *= 2; n > maxGroups {
+		n = maxGroups
+	}
+	return uint32(n)
+}
+
+// rehash moves the elements of |m| into a table of |n| groups.
+// Unlike Map, the hash function is kept as is. Keys are known to
+// be distinct, so they are placed without comparing them.
+func (m *HashMap[K, V]) rehash(n uint32) {
+	groups, ctrl := m.groups, m.ctrl
+	m.groups = make([]group[K, V], n)
+	m.ctrl = make([]metadata, n)
+	for i := range m.ctrl {
+		m.ctrl[i] = newEmptyMetadata()
+	}
+	m.limit = defaultLimit(n)
+	m.resident, m.dead = 0, 0
+	for g := range ctrl {
+		for s := range ctrl[g] {
+			c := ctrl[g][s]
+			if c == empty || c == tombstone {
+				continue
+			}
+			hi, lo := splitHash(m.hash(groups[g].keys[s]))
+			m.insertUnique(groups[g].keys[s], groups[g].values[s], hi, lo)
+		}
+	}
+}
+
+// insertUnique stores |key| and |value|, where |key| must be absent from
+// |m|, in the first empty slot of its probe sequence. The HashMap must have
+// room for |key|.
+func (m *HashMap[K, V]) insertUnique(key K, value V, hi h1, lo h2) {
+	g := probeStart(hi, len(m.groups))
+	for {
+		matches := metaMatchEmpty(&m.ctrl[g])
+		if matches != 0 {
+			s := nextMatch(&matches)
+			m.groups[g].keys[s] = key
+			m.groups[g].values[s] = value
+			m.ctrl[g][s] = int8(lo)
+			m.resident++
+			return
+		}
+		g += 1 // linear probing
+		if g >= uint32(len(m.groups)) {
+			g = 0
+		}
+	}
+}
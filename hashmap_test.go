@@ -0,0 +1,119 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"bytes"
+	"hash/maphash"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashMap(t *testing.T) {
+	t.Run("bytes keys", func(t *testing.T) {
+		seed := maphash.MakeSeed()
+		m := NewHashMap[[]byte, int](0, func(key []byte) uint64 {
+			var h maphash.Hash
+			h.SetSeed(seed)
+			_, _ = h.Write(key)
+			return h.Sum64()
+		}, bytes.Equal)
+		keys := genStringData(16, 1000)
+		for i, k := range keys {
+			m.Put([]byte(k), i)
+		}
+		assert.Equal(t, len(keys), m.Count())
+		for i, k := range keys {
+			v, ok := m.Get([]byte(k))
+			assert.True(t, ok)
+			assert.Equal(t, i, v)
+		}
+		for i, k := range keys {
+			if i%2 == 0 {
+				assert.True(t, m.Delete([]byte(k)))
+			}
+		}
+		assert.Equal(t, len(keys)/2, m.Count())
+		for i, k := range keys {
+			assert.Equal(t, i%2 == 1, m.Has([]byte(k)))
+		}
+		n := 0
+		m.Iter(func(k []byte, v int) (stop bool) {
+			assert.Equal(t, keys[v], string(k))
+			n++
+			return
+		})
+		assert.Equal(t, m.Count(), n)
+		m.Clear()
+		assert.Equal(t, 0, m.Count())
+		assert.False(t, m.Has([]byte(keys[1])))
+	})
+	t.Run("case insensitive keys", func(t *testing.T) {
+		seed := maphash.MakeSeed()
+		m := NewHashMap[string, int](0, func(key string) uint64 {
+			var h maphash.Hash
+			h.SetSeed(seed)
+			_, _ = h.WriteString(strings.ToLower(key))
+			return h.Sum64()
+		}, strings.EqualFold)
+		m.Put("Hello", 1)
+		m.Put("HELLO", 2)
+		m.Put("world", 3)
+		assert.Equal(t, 2, m.Count())
+		v, ok := m.Get("hello")
+		assert.True(t, ok)
+		assert.Equal(t, 2, v)
+		assert.True(t, m.Delete("WORLD"))
+		assert.False(t, m.Has("world"))
+	})
+	t.Run("colliding hashes", func(t *testing.T) {
+		type key struct {
+			id   int
+			name []string
+		}
+		// every key has the same hash, so each
+		// lookup probes the entire table
+		equals := 0
+		m := NewHashMap[key, int](0, func(key) uint64 {
+			return 42
+		}, func(a, b key) bool {
+			equals++
+			return a.id == b.id
+		})
+		const n = 200
+		for i := 0; i < n; i++ {
+			m.Put(key{id: i}, i)
+		}
+		// rehashing does not compare keys
+		equals = 0
+		m.Grow(n)
+		assert.Zero(t, equals)
+		assert.GreaterOrEqual(t, m.Capacity(), n)
+		for i := 0; i < n; i++ {
+			v, ok := m.Get(key{id: i, name: []string{"ignored"}})
+			assert.True(t, ok)
+			assert.Equal(t, i, v)
+		}
+		for i := 0; i < n; i += 3 {
+			assert.True(t, m.Delete(key{id: i}))
+			assert.False(t, m.Delete(key{id: i}))
+		}
+		for i := 0; i < n; i++ {
+			assert.Equal(t, i%3 != 0, m.Has(key{id: i}))
+		}
+	})
+}
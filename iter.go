@@ -0,0 +1,85 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.23
+
+package swiss
+
+import "iter"
+
+// All returns an iterator over the key-value pairs of |m|.
+// It provides the same guarantees as Iter.
+func (m *Map[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		m.Iter(func(k K, v V) (stop bool) {
+			return !yield(k, v)
+		})
+	}
+}
+
+// Keys returns an iterator over the keys of |m|.
+func (m *Map[K, V]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		m.Iter(func(k K, _ V) (stop bool) {
+			return !yield(k)
+		})
+	}
+}
+
+// Values returns an iterator over the values of |m|.
+func (m *Map[K, V]) Values() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		m.Iter(func(_ K, v V) (stop bool) {
+			return !yield(v)
+		})
+	}
+}
+
+// All returns an iterator over the key-value pairs of |m|.
+// It provides the same guarantees as Iter.
+func (m *Map8[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		m.Iter(func(k K, v V) (stop bool) {
+			return !yield(k, v)
+		})
+	}
+}
+
+// Keys returns an iterator over the keys of |m|.
+func (m *Map8[K, V]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		m.Iter(func(k K, _ V) (stop bool) {
+			return !yield(k)
+		})
+	}
+}
+
+// Values returns an iterator over the values of |m|.
+func (m *Map8[K, V]) Values() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		m.Iter(func(_ K, v V) (stop bool) {
+			return !yield(v)
+		})
+	}
+}
+
+// All returns an iterator over the keys of |s|.
+// It provides the same guarantees as Iter.
+func (s *Set[K]) All() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		s.Iter(func(k K) (stop bool) {
+			return !yield(k)
+		})
+	}
+}
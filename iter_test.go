@@ -0,0 +1,77 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.23
+
+package swiss
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeqIterators(t *testing.T) {
+	keys := genStringData(16, 1000)
+	golden := make(map[string]int, len(keys))
+	m := NewMap[string, int](0)
+	m8 := NewMap8[string, int](0)
+	for i, k := range keys {
+		golden[k] = i
+		m.Put(k, i)
+		m8.Put(k, i)
+	}
+
+	t.Run("all", func(t *testing.T) {
+		assert.Equal(t, golden, maps.Collect(m.All()))
+		assert.Equal(t, golden, maps.Collect(m8.All()))
+	})
+	t.Run("keys", func(t *testing.T) {
+		exp := slices.Sorted(maps.Keys(golden))
+		assert.Equal(t, exp, slices.Sorted(m.Keys()))
+		assert.Equal(t, exp, slices.Sorted(m8.Keys()))
+
+		set := NewSet[string](0)
+		for _, k := range keys {
+			set.Add(k)
+		}
+		assert.Equal(t, exp, slices.Sorted(set.All()))
+	})
+	t.Run("values", func(t *testing.T) {
+		exp := slices.Sorted(maps.Values(golden))
+		assert.Equal(t, exp, slices.Sorted(m.Values()))
+		assert.Equal(t, exp, slices.Sorted(m8.Values()))
+	})
+	t.Run("insert", func(t *testing.T) {
+		c := make(map[string]int, len(golden))
+		maps.Insert(c, m.All())
+		assert.Equal(t, golden, c)
+	})
+	t.Run("break", func(t *testing.T) {
+		var calls int
+		m.All()(func(k string, v int) bool {
+			calls++
+			return calls < 10
+		})
+		assert.Equal(t, 10, calls)
+		calls = 0
+		m8.Keys()(func(k string) bool {
+			calls++
+			return false
+		})
+		assert.Equal(t, 1, calls)
+	})
+}
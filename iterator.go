@@ -0,0 +1,168 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+// Iterator is a pull-style iterator over the elements of a Map.
+// It provides the same guarantees as Map.Iter: it takes a consistent
+// view of the table when created, so any key will be visited at most
+// once even if the Map is rehashed during iteration.
+//
+// While an Iterator is in progress, the Map cannot rearrange the table it
+// views: Compact rehashes the Map into a new table instead, and growth is
+// not incremental. An Iterator is done once Next returns false, so a loop
+// that runs to the end needs no cleanup. A loop that breaks out early must
+// call Stop, or the Map keeps rehashing as if it were still iterated.
+type Iterator[K comparable, V any] struct {
+	ctrl   []metadata
+	groups []group[K, V]
+	g      uint32 // current group
+	s      uint32 // next slot in group |g|
+	n      int    // groups left to visit, including |g|
+	key    K
+	value  V
+	// hold is shared by the copies of the Iterator,
+	// so that only one of them releases it
+	hold *iterHold[K, V]
+}
+
+// iterHold counts an Iterator in the iters of |m|
+// until it is released.
+type iterHold[K comparable, V any] struct {
+	m *Map[K, V]
+}
+
+// Iterator returns an Iterator positioned before the first element of |m|.
+// Call Next to advance it, and Stop if breaking out of the loop:
+//
+//	it := m.Iterator()
+//	for it.Next() {
+//		if it.Key() == k {
+//			it.Stop()
+//			break
+//		}
+//	}
+func (m *Map[K, V]) Iterator() Iterator[K, V] {
+	m.settle()
+	m.iters++
+	return Iterator[K, V]{
+		ctrl:   m.ctrl,
+		groups: m.groups,
+		g:      randIntN(len(m.groups)),
+		n:      len(m.groups),
+		hold:   &iterHold[K, V]{m: m},
+	}
+}
+
+// Next advances the Iterator to the next element, returning false
+// once all elements have been visited, at which point it is stopped.
+func (it *Iterator[K, V]) Next() bool {
+	for it.n > 0 {
+		for it.s < groupSize {
+			s := it.s
+			it.s++
+			c := it.ctrl[it.g][s]
+			if c == empty || c == tombstone {
+				continue
+			}
+			it.key, it.value = it.groups[it.g].keys[s], it.groups[it.g].values[s]
+			return true
+		}
+		it.s = 0
+		it.n--
+		it.g++
+		if it.g >= uint32(len(it.groups)) {
+			it.g = 0
+		}
+	}
+	it.Stop()
+	return false
+}
+
+// Stop ends the iteration, after which Next returns false. It is only
+// needed if the iteration ends before Next returns false. Stopping
+// an Iterator, or any of its copies, more than once has no effect.
+func (it *Iterator[K, V]) Stop() {
+	it.n = 0
+	if h := it.hold; h != nil && h.m != nil {
+		h.m.iters--
+		h.m = nil
+	}
+}
+
+// Key returns the key of the current element.
+func (it *Iterator[K, V]) Key() K {
+	return it.key
+}
+
+// Value returns the value of the current element.
+func (it *Iterator[K, V]) Value() V {
+	return it.value
+}
+
+// Iterator8 is a pull-style iterator over the elements of a Map8.
+// It provides the same guarantees as Map8.Iter.
+type Iterator8[K comparable, V any] struct {
+	ctrl   []metadata8
+	groups []group8[K, V]
+	g      uint32 // current group8
+	s      uint32 // next slot in group8 |g|
+	n      int    // groups left to visit, including |g|
+	key    K
+	value  V
+}
+
+// Iterator returns an Iterator8 positioned before the first element of |m|.
+func (m *Map8[K, V]) Iterator() Iterator8[K, V] {
+	return Iterator8[K, V]{
+		ctrl:   m.ctrl,
+		groups: m.groups,
+		g:      randIntN8(len(m.groups)),
+		n:      len(m.groups),
+	}
+}
+
+// Next advances the Iterator8 to the next element,
+// returning false once all elements have been visited.
+func (it *Iterator8[K, V]) Next() bool {
+	for it.n > 0 {
+		for it.s < groupSize8 {
+			s := it.s
+			it.s++
+			c := it.ctrl[it.g][s]
+			if c == empty8 || c == tombstone8 {
+				continue
+			}
+			it.key, it.value = it.groups[it.g].keys[s], it.groups[it.g].values[s]
+			return true
+		}
+		it.s = 0
+		it.n--
+		it.g++
+		if it.g >= uint32(len(it.groups)) {
+			it.g = 0
+		}
+	}
+	return false
+}
+
+// Key returns the key of the current element.
+func (it *Iterator8[K, V]) Key() K {
+	return it.key
+}
+
+// Value returns the value of the current element.
+func (it *Iterator8[K, V]) Value() V {
+	return it.value
+}
@@ -0,0 +1,96 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIterator(t *testing.T) {
+	t.Run("strings=0", func(t *testing.T) {
+		testIterator(t, genStringData(16, 0))
+	})
+	t.Run("strings=100", func(t *testing.T) {
+		testIterator(t, genStringData(16, 100))
+	})
+	t.Run("strings=10_000", func(t *testing.T) {
+		testIterator(t, genStringData(16, 10_000))
+	})
+	t.Run("uint32=0", func(t *testing.T) {
+		testIterator(t, genUint32Data(0))
+	})
+	t.Run("uint32=100", func(t *testing.T) {
+		testIterator(t, genUint32Data(100))
+	})
+	t.Run("uint32=10_000", func(t *testing.T) {
+		testIterator(t, genUint32Data(10_000))
+	})
+}
+
+func testIterator[K comparable](t *testing.T, keys []K) {
+	t.Run("map", func(t *testing.T) {
+		m := NewMap[K, int](uint32(len(keys)))
+		for i, key := range keys {
+			m.Put(key, i)
+		}
+		visited := make(map[K]int, len(keys))
+		it := m.Iterator()
+		for it.Next() {
+			visited[it.Key()]++
+			exp, ok := m.Get(it.Key())
+			assert.True(t, ok)
+			assert.Equal(t, exp, it.Value())
+		}
+		assert.Equal(t, len(keys), len(visited))
+		for _, c := range visited {
+			assert.Equal(t, 1, c)
+		}
+		assert.False(t, it.Next())
+
+		// grow the map during iteration
+		visited = make(map[K]int, len(keys))
+		it = m.Iterator()
+		for it.Next() {
+			visited[it.Key()]++
+			if len(visited) == 1 {
+				m.rehash(uint32(len(m.groups)) * 2)
+			}
+		}
+		for _, c := range visited {
+			assert.Equal(t, 1, c)
+		}
+	})
+	t.Run("map8", func(t *testing.T) {
+		m := NewMap8[K, int](uint32(len(keys)))
+		for i, key := range keys {
+			m.Put(key, i)
+		}
+		visited := make(map[K]int, len(keys))
+		it := m.Iterator()
+		for it.Next() {
+			visited[it.Key()]++
+			exp, ok := m.Get(it.Key())
+			assert.True(t, ok)
+			assert.Equal(t, exp, it.Value())
+		}
+		assert.Equal(t, len(keys), len(visited))
+		for _, c := range visited {
+			assert.Equal(t, 1, c)
+		}
+		assert.False(t, it.Next())
+	})
+}
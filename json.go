@@ -0,0 +1,311 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"unsafe"
+)
+
+// MarshalJSON implements json.Marshaler. The Map is encoded as a JSON
+// object with sorted member names, exactly as a builtin map[K]V is.
+func (m *Map[K, V]) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+	return marshalJSONObject(m.Count(), m.Iter)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It accepts the JSON objects
+// accepted by a builtin map[K]V and, like one, adds their members to the
+// existing elements of the Map. JSON null clears the Map.
+func (m *Map[K, V]) UnmarshalJSON(data []byte) error {
+	if m.ctrl == nil {
+		*m = *NewMap[K, V](0)
+	}
+	return unmarshalJSONObject(data, m.Clear, m.Grow, m.Put)
+}
+
+// MarshalJSON implements json.Marshaler. The Map8 is encoded as a JSON
+// object with sorted member names, exactly as a builtin map[K]V is.
+func (m *Map8[K, V]) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+	return marshalJSONObject(m.Count(), m.Iter)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It accepts the JSON objects
+// accepted by a builtin map[K]V and, like one, adds their members to the
+// existing elements of the Map8. JSON null clears the Map8.
+func (m *Map8[K, V]) UnmarshalJSON(data []byte) error {
+	if m.ctrl == nil {
+		*m = *NewMap8[K, V](0)
+	}
+	return unmarshalJSONObject(data, m.Clear, m.Grow, m.Put)
+}
+
+// MarshalJSON implements json.Marshaler. The HashMap is encoded as a JSON
+// object with sorted member names, exactly as a builtin map[K]V is.
+func (m *HashMap[K, V]) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+	return marshalJSONObject(m.Count(), m.Iter)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It accepts the JSON objects
+// accepted by a builtin map[K]V and, like one, adds their members to the
+// existing elements of the HashMap. JSON null clears the HashMap. The
+// HashMap must have been created by NewHashMap.
+func (m *HashMap[K, V]) UnmarshalJSON(data []byte) error {
+	if m.hash == nil {
+		return errors.New("swiss: UnmarshalJSON into a HashMap without hash and equality functions")
+	}
+	return unmarshalJSONObject(data, m.Clear, m.Grow, m.Put)
+}
+
+// marshalJSONObject encodes the |n| elements visited by |iter|.
+func marshalJSONObject[K any, V any](n int, iter func(cb func(k K, v V) (stop bool))) ([]byte, error) {
+	name, _, err := jsonKeyFuncs[K]()
+	if err != nil {
+		return nil, err
+	}
+	type member struct {
+		name  string
+		value []byte
+	}
+	members := make([]member, 0, n)
+	iter(func(k K, v V) (stop bool) {
+		var mb member
+		if mb.name, err = name(k); err != nil {
+			return true
+		}
+		if mb.value, err = json.Marshal(v); err != nil {
+			return true
+		}
+		members = append(members, mb)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].name < members[j].name
+	})
+
+	buf := make([]byte, 0, 2+len(members)*16)
+	buf = append(buf, '{')
+	for i, mb := range members {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		// a string always marshals
+		q, _ := json.Marshal(mb.name)
+		buf = append(buf, q...)
+		buf = append(buf, ':')
+		buf = append(buf, mb.value...)
+	}
+	return append(buf, '}'), nil
+}
+
+// unmarshalJSONObject decodes the members of the JSON object |data| one
+// at a time, passing each to |put| after calling |grow| with their number.
+// If |data| is null, it calls |clear| instead.
+func unmarshalJSONObject[K any, V any](data []byte, clear func(), grow func(n int), put func(k K, v V)) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		clear()
+		return nil
+	}
+	_, key, err := jsonKeyFuncs[K]()
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != json.Delim('{') {
+		return &json.UnmarshalTypeError{
+			Value: jsonTokenKind(tok),
+			Type:  reflect.TypeOf((*map[string]V)(nil)).Elem(),
+		}
+	}
+	grow(jsonObjectLen(data))
+	for dec.More() {
+		if tok, err = dec.Token(); err != nil {
+			return err
+		}
+		k, err := key(tok.(string))
+		if err != nil {
+			return err
+		}
+		var v V
+		if err = dec.Decode(&v); err != nil {
+			return err
+		}
+		put(k, v)
+	}
+	_, err = dec.Token()
+	return err
+}
+
+// jsonKeyFuncs returns functions that convert keys of type K to and from
+// JSON member names, following the rules encoding/json applies to the keys
+// of a builtin map: keys of kind string are used as is, keys implementing
+// encoding.TextMarshaler and encoding.TextUnmarshaler are converted by
+// them, and integer keys are formatted in decimal.
+func jsonKeyFuncs[K any]() (name func(k K) (string, error), key func(s string) (K, error), err error) {
+	t := reflect.TypeOf((*K)(nil)).Elem()
+
+	switch {
+	case t.Kind() == reflect.String:
+		name = func(k K) (string, error) {
+			return *(*string)(unsafe.Pointer(&k)), nil
+		}
+	case t.Implements(textMarshalerType):
+		name = func(k K) (string, error) {
+			if t.Kind() == reflect.Pointer && reflect.ValueOf(&k).Elem().IsNil() {
+				return "", nil
+			}
+			b, err := any(k).(encoding.TextMarshaler).MarshalText()
+			return string(b), err
+		}
+	case isIntKind(t.Kind()):
+		name = func(k K) (string, error) {
+			return strconv.FormatInt(reflect.ValueOf(k).Int(), 10), nil
+		}
+	case isUintKind(t.Kind()):
+		name = func(k K) (string, error) {
+			return strconv.FormatUint(reflect.ValueOf(k).Uint(), 10), nil
+		}
+	default:
+		return nil, nil, &json.UnsupportedTypeError{Type: t}
+	}
+
+	switch {
+	case reflect.PointerTo(t).Implements(textUnmarshalerType):
+		key = func(s string) (k K, err error) {
+			err = any(&k).(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+			return
+		}
+	case t.Kind() == reflect.String:
+		key = func(s string) (k K, err error) {
+			*(*string)(unsafe.Pointer(&k)) = s
+			return
+		}
+	case isIntKind(t.Kind()):
+		key = func(s string) (k K, err error) {
+			v := reflect.ValueOf(&k).Elem()
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || v.OverflowInt(n) {
+				return k, &json.UnmarshalTypeError{Value: "number " + s, Type: t}
+			}
+			v.SetInt(n)
+			return k, nil
+		}
+	case isUintKind(t.Kind()):
+		key = func(s string) (k K, err error) {
+			v := reflect.ValueOf(&k).Elem()
+			n, err := strconv.ParseUint(s, 10, 64)
+			if err != nil || v.OverflowUint(n) {
+				return k, &json.UnmarshalTypeError{Value: "number " + s, Type: t}
+			}
+			v.SetUint(n)
+			return k, nil
+		}
+	default:
+		// a TextMarshaler without a TextUnmarshaler can only be encoded
+		key = func(s string) (k K, err error) {
+			return k, &json.UnmarshalTypeError{Value: "string", Type: t}
+		}
+	}
+	return
+}
+
+// jsonObjectLen returns the number of members of the JSON object |data|,
+// which must be valid JSON.
+func jsonObjectLen(data []byte) (n int) {
+	var (
+		depth           int
+		str, esc, named bool
+	)
+	for _, c := range data {
+		switch {
+		case esc:
+			esc = false
+		case str:
+			esc = c == '\\'
+			str = c != '"'
+		case c == '"':
+			named = named || depth == 1
+			str = true
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+		case c == ',' && depth == 1:
+			n++
+		}
+	}
+	if named {
+		// members are separated by commas
+		n++
+	}
+	return
+}
+
+func jsonTokenKind(tok json.Token) string {
+	switch tok.(type) {
+	case json.Delim:
+		return "array"
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	default:
+		return "null"
+	}
+}
+
+func isIntKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isUintKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
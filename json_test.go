@@ -0,0 +1,183 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONEncoding(t *testing.T) {
+	t.Run("strings", func(t *testing.T) {
+		exp := make(map[string][]int)
+		m := NewMap[string, []int](0)
+		for i, k := range genStringData(8, 100) {
+			exp[k] = []int{i}
+			m.Put(k, []int{i})
+		}
+		exp["<\"quoted\">"] = nil
+		m.Put("<\"quoted\">", nil)
+		testJSONRoundTrip(t, exp, m, func() *Map[string, []int] {
+			return NewMap[string, []int](0)
+		})
+	})
+	t.Run("int keys", func(t *testing.T) {
+		exp := make(map[int8]string)
+		m := NewMap8[int8, string](0)
+		for i := -128; i < 128; i++ {
+			exp[int8(i)] = fmt.Sprint(i)
+			m.Put(int8(i), fmt.Sprint(i))
+		}
+		testJSONRoundTrip(t, exp, m, func() *Map8[int8, string] {
+			return NewMap8[int8, string](0)
+		})
+
+		var act Map[int8, string]
+		assert.Error(t, json.Unmarshal([]byte(`{"128":"overflow"}`), &act))
+		var u Map[uint16, bool]
+		assert.Error(t, json.Unmarshal([]byte(`{"-1":true}`), &u))
+	})
+	t.Run("text keys", func(t *testing.T) {
+		exp := map[textKey]float64{{1, 2}: 0.5, {-3, 4}: 1e9}
+		m := NewHashMap[textKey, float64](0, func(k textKey) uint64 {
+			return uint64(k.a)<<32 | uint64(uint32(k.b))
+		}, func(a, b textKey) bool {
+			return a == b
+		})
+		for k, v := range exp {
+			m.Put(k, v)
+		}
+		data, err := json.Marshal(m)
+		require.NoError(t, err)
+		expData, err := json.Marshal(exp)
+		require.NoError(t, err)
+		assert.Equal(t, string(expData), string(data))
+
+		m.Clear()
+		require.NoError(t, json.Unmarshal(data, m))
+		assert.Equal(t, len(exp), m.Count())
+		for k, v := range exp {
+			a, _ := m.Get(k)
+			assert.Equal(t, v, a)
+		}
+		var zero HashMap[textKey, float64]
+		assert.Error(t, json.Unmarshal(data, &zero))
+	})
+	t.Run("struct field", func(t *testing.T) {
+		type response struct {
+			Counts *Map[string, int] `json:"counts"`
+			Empty  *Map[string, int] `json:"empty"`
+		}
+		r := response{Counts: NewMap[string, int](0)}
+		r.Counts.Put("b", 2)
+		r.Counts.Put("a", 1)
+		data, err := json.Marshal(r)
+		require.NoError(t, err)
+		assert.Equal(t, `{"counts":{"a":1,"b":2},"empty":null}`, string(data))
+
+		var act response
+		require.NoError(t, json.Unmarshal(data, &act))
+		assert.Equal(t, 2, act.Counts.Count())
+		assert.Nil(t, act.Empty)
+	})
+	t.Run("merge and null", func(t *testing.T) {
+		m := NewMap[string, int](0)
+		m.Put("a", 1)
+		require.NoError(t, json.Unmarshal([]byte(` {"b": 2, "a": 3} `), m))
+		assert.Equal(t, 2, m.Count())
+		assert.Equal(t, 3, must(m.Get("a")))
+		require.NoError(t, json.Unmarshal([]byte(`null`), m))
+		assert.Equal(t, 0, m.Count())
+	})
+	t.Run("errors", func(t *testing.T) {
+		m := NewMap[string, int](0)
+		assert.Error(t, m.UnmarshalJSON([]byte(`[1, 2]`)))
+		assert.Error(t, m.UnmarshalJSON([]byte(`{"a": "b"}`)))
+		assert.Error(t, m.UnmarshalJSON([]byte(`{"a": 1`)))
+		_, err := json.Marshal(NewMap[float64, int](0))
+		assert.Error(t, err)
+	})
+	t.Run("presized", func(t *testing.T) {
+		exp := make(map[string]int)
+		for i, k := range genStringData(16, 1000) {
+			exp[k] = i
+		}
+		data, err := json.Marshal(exp)
+		require.NoError(t, err)
+		var m Map[string, int]
+		require.NoError(t, json.Unmarshal(data, &m))
+		assert.Equal(t, 1000, m.Count())
+		assert.Equal(t, int(numGroups(1000)), len(m.groups))
+	})
+}
+
+func TestJSONObjectLen(t *testing.T) {
+	tests := []struct {
+		data string
+		n    int
+	}{
+		{`{}`, 0},
+		{` { } `, 0},
+		{`{"a":1}`, 1},
+		{`{"a":"b","c":"d"}`, 2},
+		{`{"a,b":[1,2,3],"c":{"d":1,"e":2}}`, 2},
+		{`{"\"":"\\","{":"}"}`, 2},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.n, jsonObjectLen([]byte(test.data)), test.data)
+	}
+}
+
+func testJSONRoundTrip[K comparable, V any, M interface {
+	Count() int
+	Get(K) (V, bool)
+	json.Marshaler
+	json.Unmarshaler
+}](t *testing.T, exp map[K]V, m M, empty func() M) {
+	data, err := json.Marshal(m)
+	require.NoError(t, err)
+	expData, err := json.Marshal(exp)
+	require.NoError(t, err)
+	assert.Equal(t, string(expData), string(data))
+
+	act := empty()
+	require.NoError(t, json.Unmarshal(data, act))
+	assert.Equal(t, len(exp), act.Count())
+	for k, v := range exp {
+		a, ok := act.Get(k)
+		assert.True(t, ok)
+		assert.Equal(t, v, a)
+	}
+}
+
+type textKey struct{ a, b int32 }
+
+func (k textKey) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d/%d", k.a, k.b)), nil
+}
+
+func (k *textKey) UnmarshalText(b []byte) error {
+	a, b2, ok := strings.Cut(string(b), "/")
+	if !ok {
+		return fmt.Errorf("bad textKey %q", b)
+	}
+	_, err := fmt.Sscan(a+" "+b2, &k.a, &k.b)
+	return err
+}
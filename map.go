@@ -15,11 +15,28 @@
 package swiss
 
 import (
-	"github.com/dolthub/maphash"
+	"math"
+	"math/bits"
 )
 
 const (
 	maxLoadFactor = float32(maxAvgGroupLoad) / float32(groupSize)
+
+	// shrinkRatio is the ratio of capacity to elements
+	// below which an auto-shrinking Map is shrunk
+	shrinkRatio = 8
+
+	// maxGroups is the maximum number of groups of a Map, as groups
+	// are indexed by uint32, which is 2^36 slots with 16-slot groups
+	maxGroups = math.MaxUint32
+
+	// maxElems is the most elements a table of the
+	// default maximum load factor can hold
+	maxElems = maxGroups * maxAvgGroupLoad
+
+	// defaultCompactRatio is the default ratio of tombstones to
+	// resident slots at which a full Map is compacted, not grown
+	defaultCompactRatio = 0.5
 )
 
 // Map is an open-addressing hash map
@@ -27,10 +44,43 @@ const (
 type Map[K comparable, V any] struct {
 	ctrl     []metadata
 	groups   []group[K, V]
-	hash     maphash.Hasher[K]
-	resident uint32
-	dead     uint32
-	limit    uint32
+	hash     hasher[K]
+	resident uint64
+	dead     uint64
+	limit    uint64
+	// epoch is incremented whenever slots are
+	// relocated or released in bulk (see Ref)
+	epoch uint32
+	// shrink enables automatic shrinking on Delete
+	shrink bool
+	// incremental enables incremental rehashing
+	incremental bool
+	// keepSeed disables reseeding |hash| on rehash
+	keepSeed bool
+	// compactRatio is the ratio of tombstones to resident
+	// slots at which a full Map is compacted, not grown
+	compactRatio float32
+	// maxLoad is the maximum load factor set by
+	// WithMaxLoadFactor, or zero for maxLoadFactor
+	maxLoad float32
+	// growth is the growth factor set by
+	// WithGrowthFactor, or zero for doubling
+	growth float32
+	// iters is the number of Iters and Iterators in progress,
+	// during which the table must not be compacted in place
+	iters uint32
+	// ptrs is true if K or V contain pointers,
+	// which removed slots must release
+	ptrs bool
+	// hashes caches the hash of each slot of |groups|,
+	// if enabled by SetCacheHashes
+	hashes []hashGroup
+	// old is the table being migrated
+	// while |m| is rehashed incrementally
+	old *migration[K, V]
+	// ext is non-nil while optional state,
+	// such as a Snapshot, is in use
+	ext *mapExt[K, V]
 }
 
 // metadata is the h2 metadata array for a group.
@@ -39,11 +89,14 @@ type Map[K comparable, V any] struct {
 type metadata [groupSize]int8
 
 // group is a group of 16 key-value pairs
-type group[K comparable, V any] struct {
+type group[K any, V any] struct {
 	keys   [groupSize]K
 	values [groupSize]V
 }
 
+// hashGroup holds the hashes of the keys of a group
+type hashGroup [groupSize]uint64
+
 const (
 	h1Mask    uint64 = 0xffff_ffff_ffff_ff80
 	h2Mask    uint64 = 0x0000_0000_0000_007f
@@ -57,14 +110,18 @@ type h1 uint64
 // h2 is a 7 bit hash suffix
 type h2 int8
 
-// NewMap constructs a Map.
+// NewMap constructs a Map. To size a Map for more
+// elements than a uint32 holds, see WithCapacity.
 func NewMap[K comparable, V any](sz uint32) (m *Map[K, V]) {
 	groups := numGroups(sz)
 	m = &Map[K, V]{
 		ctrl:   make([]metadata, groups),
 		groups: make([]group[K, V], groups),
-		hash:   maphash.NewHasher[K](),
-		limit:  groups * maxAvgGroupLoad,
+		hash:   newHasher[K](),
+		limit:  defaultLimit(groups),
+		ptrs:   hasPointers[K]() || hasPointers[V](),
+
+		compactRatio: defaultCompactRatio,
 	}
 	for i := range m.ctrl {
 		m.ctrl[i] = newEmptyMetadata()
@@ -89,6 +146,10 @@ func (m *Map[K, V]) Has(key K) (ok bool) {
 		// stop probing if we see an empty slot
 		matches = metaMatchEmpty(&m.ctrl[g])
 		if matches != 0 {
+			if m.old != nil {
+				_, _, ok = m.old.find(key, hi, lo)
+				return
+			}
 			ok = false
 			return
 		}
@@ -116,6 +177,13 @@ func (m *Map[K, V]) Get(key K) (value V, ok bool) {
 		// stop probing if we see an empty slot
 		matches = metaMatchEmpty(&m.ctrl[g])
 		if matches != 0 {
+			if m.old != nil {
+				var s uint32
+				if g, s, ok = m.old.find(key, hi, lo); ok {
+					value = m.old.groups[g].values[s]
+				}
+				return
+			}
 			ok = false
 			return
 		}
@@ -129,15 +197,21 @@ func (m *Map[K, V]) Get(key K) (value V, ok bool) {
 // Put attempts to insert |key| and |value|
 func (m *Map[K, V]) Put(key K, value V) {
 	if m.resident >= m.limit {
-		m.rehash(m.nextSize())
+		m.makeRoom()
 	}
 	hi, lo := splitHash(m.hash.Hash(key))
+	if m.old != nil {
+		m.migrate(key, hi, lo)
+	}
 	g := probeStart(hi, len(m.groups))
 	for { // inlined find loop
 		matches := metaMatchH2(&m.ctrl[g], lo)
 		for matches != 0 {
 			s := nextMatch(&matches)
 			if key == m.groups[g].keys[s] { // update
+				if m.ext != nil {
+					m.beforeUpdate(g, s, value)
+				}
 				m.groups[g].keys[s] = key
 				m.groups[g].values[s] = value
 				return
@@ -148,9 +222,15 @@ func (m *Map[K, V]) Put(key K, value V) {
 		matches = metaMatchEmpty(&m.ctrl[g])
 		if matches != 0 { // insert
 			s := nextMatch(&matches)
+			if m.ext != nil {
+				m.beforeInsert(g, key, value)
+			}
 			m.groups[g].keys[s] = key
 			m.groups[g].values[s] = value
 			m.ctrl[g][s] = int8(lo)
+			if m.hashes != nil {
+				m.hashes[g][s] = joinHash(hi, lo)
+			}
 			m.resident++
 			return
 		}
@@ -164,6 +244,9 @@ func (m *Map[K, V]) Put(key K, value V) {
 // Delete attempts to remove |key|, returns true successful.
 func (m *Map[K, V]) Delete(key K) (ok bool) {
 	hi, lo := splitHash(m.hash.Hash(key))
+	if m.old != nil {
+		m.migrate(key, hi, lo)
+	}
 	g := probeStart(hi, len(m.groups))
 	for {
 		matches := metaMatchH2(&m.ctrl[g], lo)
@@ -171,19 +254,9 @@ func (m *Map[K, V]) Delete(key K) (ok bool) {
 			s := nextMatch(&matches)
 			if key == m.groups[g].keys[s] {
 				ok = true
-				// optimization: if |m.ctrl[g]| contains any empty
-				// metadata bytes, we can physically delete |key|
-				// rather than placing a tombstone.
-				// The observation is that any probes into group |g|
-				// would already be terminated by the existing empty
-				// slot, and therefore reclaiming slot |s| will not
-				// cause premature termination of probes into |g|.
-				if metaMatchEmpty(&m.ctrl[g]) != 0 {
-					m.ctrl[g][s] = empty
-					m.resident--
-				} else {
-					m.ctrl[g][s] = tombstone
-					m.dead++
+				m.deleteAt(g, s)
+				if m.shrink {
+					m.shrinkIfSparse()
 				}
 				return
 			}
@@ -210,7 +283,10 @@ func (m *Map[K, V]) Delete(key K) (ok bool) {
 func (m *Map[K, V]) Iter(cb func(k K, v V) (stop bool)) {
 	// take a consistent view of the table in case
 	// we rehash during iteration
+	m.settle()
 	ctrl, groups := m.ctrl, m.groups
+	m.iters++
+	defer func() { m.iters-- }()
 	// pick a random starting group
 	g := randIntN(len(groups))
 	for n := 0; n < len(groups); n++ {
@@ -232,23 +308,157 @@ func (m *Map[K, V]) Iter(cb func(k K, v V) (stop bool)) {
 
 // Clear removes all elements from the Map.
 func (m *Map[K, V]) Clear() {
+	m.old = nil
+	if m.hasSnapshots() {
+		// leave the table to the Snapshots
+		m.Reset(int(m.limit))
+		return
+	}
 	for i, c := range m.ctrl {
 		for j := range c {
 			m.ctrl[i][j] = empty
 		}
 	}
+	if m.ptrs {
+		// release all keys and values for the garbage
+		// collector, compiled to a bulk memory clear
+		for i := range m.groups {
+			m.groups[i] = group[K, V]{}
+		}
+	}
 	m.resident, m.dead = 0, 0
+	m.epoch++
+	if m.ext != nil {
+		m.afterClear()
+	}
 }
 
 // Count returns the number of elements in the Map.
 func (m *Map[K, V]) Count() int {
-	return int(m.resident - m.dead)
+	n := int(m.resident - m.dead)
+	if m.old != nil {
+		n += int(m.old.live)
+	}
+	return n
 }
 
 // Capacity returns the number of additional elements
 // the can be added to the Map before resizing.
 func (m *Map[K, V]) Capacity() int {
-	return int(m.limit - m.resident)
+	n := int(m.limit - m.resident)
+	if m.old != nil {
+		n -= int(m.old.live)
+	}
+	return n
+}
+
+// Grow ensures that |n| more elements can be added
+// to the Map without rehashing.
+func (m *Map[K, V]) Grow(n int) {
+	if n <= m.Capacity() {
+		return
+	}
+	m.rehash(m.groupsFor(tableSize64(m.Count() + n)))
+}
+
+// Shrink rehashes the Map into the smallest table that can hold its
+// elements, releasing the memory held by unused groups and tombstones.
+func (m *Map[K, V]) Shrink() {
+	n := m.groupsFor(uint64(m.Count()))
+	if n < uint32(len(m.groups)) || m.dead > 0 {
+		m.rehash(n)
+	}
+}
+
+// Reset removes all elements from the Map and reallocates it
+// with room for |capacity| elements, releasing its previous table.
+func (m *Map[K, V]) Reset(capacity int) {
+	groups := m.groupsFor(tableSize64(capacity))
+	m.old = nil
+	m.ctrl = make([]metadata, groups)
+	m.groups = make([]group[K, V], groups)
+	for i := range m.ctrl {
+		m.ctrl[i] = newEmptyMetadata()
+	}
+	if m.hashes != nil {
+		m.hashes = make([]hashGroup, groups)
+	}
+	m.limit = m.limitFor(groups)
+	m.resident, m.dead = 0, 0
+	m.epoch++
+	if m.ext != nil {
+		m.afterClear()
+	}
+	m.detach()
+}
+
+// SetAutoShrink enables or disables automatic shrinking. When enabled,
+// Delete rehashes the Map into a smaller table once fewer than 1/8
+// of its capacity is in use, so that a drained Map releases its memory.
+func (m *Map[K, V]) SetAutoShrink(enabled bool) {
+	m.shrink = enabled
+}
+
+// SetCompactRatio sets the ratio of tombstones to resident slots at which
+// a full Map is compacted by Compact rather than grown. The default is 0.5.
+// Lower ratios keep the Map smaller, at the cost of compacting more often.
+// |ratio| must be in (0, 1], and 1 compacts only when every slot is dead.
+func (m *Map[K, V]) SetCompactRatio(ratio float32) {
+	if !(ratio > 0 && ratio <= 1) {
+		panic("swiss: compact ratio out of range")
+	}
+	m.compactRatio = ratio
+}
+
+// SetKeepSeed sets whether rehashing keeps the hash seed of the Map. By
+// default, each rehash renews the seed and so hashes every key again, so
+// that keys colliding under one seed are unlikely to collide under the
+// next. Keeping the seed lets a rehash reuse the hashes cached by
+// SetCacheHashes. A Map hashing keys by a StableHasher always keeps
+// its seed, so that its layout stays reproducible.
+func (m *Map[K, V]) SetKeepSeed(keep bool) {
+	m.keepSeed = keep || m.hash.stable != nil
+}
+
+// SetCacheHashes enables or disables caching the hash of each key in the
+// table, at a cost of 8 bytes per slot, so that keys are not hashed again
+// when the Map is compacted, or rehashed with its seed kept. This pays off
+// for keys that are expensive to hash, such as long strings.
+func (m *Map[K, V]) SetCacheHashes(enabled bool) {
+	if !enabled {
+		m.hashes = nil
+		return
+	}
+	if m.hashes != nil {
+		return
+	}
+	m.settle()
+	m.hashes = make([]hashGroup, len(m.groups))
+	for g := range m.ctrl {
+		for s, c := range m.ctrl[g] {
+			if c == empty || c == tombstone {
+				continue
+			}
+			m.hashes[g][s] = m.hash.Hash(m.groups[g].keys[s])
+		}
+	}
+}
+
+// Compact removes the tombstones left by deleted elements, so that their
+// slots can be reused without growing the Map. It rearranges the table in
+// place, without allocating, unless the table is in use by a Snapshot, a
+// call to Iter or an Iterator that is not done, in which case it rehashes
+// the Map into a new table of the same size.
+func (m *Map[K, V]) Compact() {
+	m.settle()
+	if m.dead == 0 {
+		return
+	}
+	if m.hasSnapshots() || m.iters > 0 {
+		m.rehash(uint32(len(m.groups)))
+		return
+	}
+	m.dropTombstones()
 }
 
 // find returns the location of |key| if present, or its insertion location if absent.
@@ -277,31 +487,209 @@ func (m *Map[K, V]) find(key K, hi h1, lo h2) (g, s uint32, ok bool) {
 	}
 }
 
-func (m *Map[K, V]) nextSize() (n uint32) {
-	n = uint32(len(m.groups)) * 2
-	if m.dead >= (m.resident / 2) {
-		n = uint32(len(m.groups))
+// insert stores |key| and |value|, whose hash is |hi|, |lo|, at the
+// insertion location |g|, |s| returned by find, rehashing first if the
+// Map is full. It returns the location where |key| was stored.
+func (m *Map[K, V]) insert(key K, value V, g, s uint32, hi h1, lo h2) (uint32, uint32) {
+	if m.resident >= m.limit {
+		m.makeRoom()
+		// |key| may have a new location,
+		// and rehashing reseeds |m.hash|
+		hi, lo = splitHash(m.hash.Hash(key))
+		g, s, _ = m.find(key, hi, lo)
+	}
+	if m.ext != nil {
+		m.beforeInsert(g, key, value)
+	}
+	m.groups[g].keys[s] = key
+	m.groups[g].values[s] = value
+	m.ctrl[g][s] = int8(lo)
+	if m.hashes != nil {
+		m.hashes[g][s] = joinHash(hi, lo)
+	}
+	m.resident++
+	return g, s
+}
+
+// deleteAt removes the element stored in slot |s| of group |g|.
+func (m *Map[K, V]) deleteAt(g, s uint32) {
+	if m.ext != nil {
+		m.beforeDelete(g, s)
+	}
+	// optimization: if |m.ctrl[g]| contains any empty
+	// metadata bytes, we can physically delete |key|
+	// rather than placing a tombstone.
+	// The observation is that any probes into group |g|
+	// would already be terminated by the existing empty
+	// slot, and therefore reclaiming slot |s| will not
+	// cause premature termination of probes into |g|.
+	if metaMatchEmpty(&m.ctrl[g]) != 0 {
+		m.ctrl[g][s] = empty
+		m.resident--
+	} else {
+		m.ctrl[g][s] = tombstone
+		m.dead++
+	}
+	if m.ptrs {
+		// release the key and value for the garbage collector
+		var k K
+		var v V
+		m.groups[g].keys[s], m.groups[g].values[s] = k, v
+	}
+}
+
+// shrinkIfSparse shrinks the Map if less than 1/shrinkRatio of its
+// capacity is in use, leaving room for it to double before growing again.
+func (m *Map[K, V]) shrinkIfSparse() {
+	live := uint64(m.Count())
+	if len(m.groups) > 1 && live < m.limit/shrinkRatio {
+		m.rehash(m.groupsFor(live * 2))
 	}
-	return
 }
 
+// makeRoom makes room for an insert into a full Map, by compacting it if
+// enough of its resident slots are tombstones, or else by growing it. An
+// incrementally rehashed Map starts migrating to a new table instead.
+func (m *Map[K, V]) makeRoom() {
+	m.settle()
+	compact := m.dead > 0 && float32(m.dead) >= float32(m.resident)*m.compactRatio
+	n := uint32(len(m.groups))
+	if !compact {
+		n = m.nextSize()
+	}
+	switch {
+	case m.incremental && !m.hasSnapshots() && m.iters == 0:
+		// an Iter or Iterator in progress must not see
+		// the old table being emptied, see Compact
+		m.startMigration(n)
+	case compact:
+		m.Compact()
+	default:
+		m.rehash(n)
+	}
+}
+
+// dropTombstones compacts |m| in place, as Abseil's DropDeletesWithoutResize
+// does: it marks every element as misplaced by turning full slots into
+// tombstones and tombstones into empty slots, then moves each misplaced
+// element into the first free slot of its probe sequence, swapping it with
+// the misplaced element there if that slot is not empty. An element never
+// needs to move past its own group, so the elements already placed are
+// not displaced by the empty slots this leaves behind.
+func (m *Map[K, V]) dropTombstones() {
+	for g := range m.ctrl {
+		for s, c := range m.ctrl[g] {
+			if c == tombstone {
+				m.ctrl[g][s] = empty
+			} else if c != empty {
+				m.ctrl[g][s] = tombstone
+			}
+		}
+	}
+	n := uint32(len(m.groups))
+	for g := uint32(0); g < n; g++ {
+		for s := uint32(0); s < groupSize; s++ {
+			for m.ctrl[g][s] == tombstone {
+				var (
+					hi h1
+					lo h2
+				)
+				if m.hashes != nil {
+					hi, lo = splitHash(m.hashes[g][s])
+				} else {
+					hi, lo = splitHash(m.hash.Hash(m.groups[g].keys[s]))
+				}
+				t := probeStart(hi, int(n))
+				u, ok := firstFree(&m.ctrl[t])
+				for !ok {
+					t += 1 // linear probing
+					if t >= n {
+						t = 0
+					}
+					u, ok = firstFree(&m.ctrl[t])
+				}
+				if t == g {
+					// already in the first group it can be in
+					m.ctrl[g][s] = int8(lo)
+					break
+				}
+				if m.hashes != nil {
+					m.hashes[t][u], m.hashes[g][s] = m.hashes[g][s], m.hashes[t][u]
+				}
+				if m.ctrl[t][u] == empty {
+					m.groups[t].keys[u] = m.groups[g].keys[s]
+					m.groups[t].values[u] = m.groups[g].values[s]
+					m.ctrl[g][s] = empty
+					if m.ptrs {
+						var k K
+						var v V
+						m.groups[g].keys[s], m.groups[g].values[s] = k, v
+					}
+				} else {
+					// place the element in slot |u| of group |t|
+					// and the misplaced one from there in slot |s|
+					k, v := &m.groups[t].keys[u], &m.groups[t].values[u]
+					*k, m.groups[g].keys[s] = m.groups[g].keys[s], *k
+					*v, m.groups[g].values[s] = m.groups[g].values[s], *v
+				}
+				m.ctrl[t][u] = int8(lo)
+			}
+		}
+	}
+	m.resident -= m.dead
+	m.dead = 0
+	m.epoch++
+}
+
+// firstFree returns the first slot of |c| that is empty or a tombstone.
+func firstFree(c *metadata) (s uint32, ok bool) {
+	for i, b := range c {
+		if b == empty || b == tombstone {
+			return uint32(i), true
+		}
+	}
+	return 0, false
+}
+
+// rehash moves the elements of |m| into a new table of |n| groups. Keys
+// are known to be unique, so each is placed in the first empty slot of
+// its probe sequence without being compared to others, and keys are only
+// hashed if the seed is renewed or their hashes are not cached.
 func (m *Map[K, V]) rehash(n uint32) {
-	groups, ctrl := m.groups, m.ctrl
+	m.settle()
+	groups, ctrl, hashes := m.groups, m.ctrl, m.hashes
 	m.groups = make([]group[K, V], n)
 	m.ctrl = make([]metadata, n)
 	for i := range m.ctrl {
 		m.ctrl[i] = newEmptyMetadata()
 	}
-	m.hash = maphash.NewSeed(m.hash)
-	m.limit = n * maxAvgGroupLoad
+	if hashes != nil {
+		m.hashes = make([]hashGroup, n)
+	}
+	if !m.keepSeed {
+		m.hash = m.hash.reseeded()
+		hashes = nil
+	}
+	m.limit = m.limitFor(n)
 	m.resident, m.dead = 0, 0
+	m.epoch++
+	// the old table is left to the Snapshots
+	m.detach()
 	for g := range ctrl {
-		for s := range ctrl[g] {
-			c := ctrl[g][s]
+		for s, c := range ctrl[g] {
 			if c == empty || c == tombstone {
 				continue
 			}
-			m.Put(groups[g].keys[s], groups[g].values[s])
+			var (
+				hi h1
+				lo h2
+			)
+			if hashes != nil {
+				hi, lo = splitHash(hashes[g][s])
+			} else {
+				hi, lo = splitHash(m.hash.Hash(groups[g].keys[s]))
+			}
+			m.place(groups[g].keys[s], groups[g].values[s], hi, lo)
 		}
 	}
 }
@@ -311,15 +699,123 @@ func (m *Map[K, V]) loadFactor() float32 {
 	return float32(m.resident-m.dead) / slots
 }
 
+// nextSize returns the number of groups a full |m| grows to,
+// which leaves room for at least one more element.
+func (m *Map[K, V]) nextSize() uint32 {
+	n := uint64(len(m.groups))
+	next := n * 2
+	if m.growth != 0 {
+		next = uint64(float64(n) * float64(m.growth))
+		if next <= n {
+			next = n + 1
+		}
+	}
+	if next > maxGroups {
+		next = maxGroups
+	}
+	for next <= maxGroups && m.limitFor(uint32(next)) <= m.limit {
+		next++
+	}
+	if next > maxGroups {
+		panic("swiss: table size out of range")
+	}
+	return uint32(next)
+}
+
+// groupsFor returns the minimum number of groups needed to store |n|
+// elems within the maximum load factor of |m|, panicking if a table of
+// that many groups cannot be indexed.
+func (m *Map[K, V]) groupsFor(n uint64) uint32 {
+	var groups uint64
+	if m.maxLoad == 0 {
+		groups = numGroups64(n)
+	} else {
+		groups = uint64(math.Ceil(float64(n) / (float64(m.maxLoad) * groupSize)))
+		for groups <= maxGroups && m.limitFor(uint32(groups)) < n {
+			groups++
+		}
+	}
+	if groups > maxGroups {
+		panic("swiss: table size out of range")
+	}
+	if groups == 0 {
+		groups = 1
+	}
+	return uint32(groups)
+}
+
+// limitFor returns the number of elements a table of |groups|
+// groups may hold within the maximum load factor of |m|.
+func (m *Map[K, V]) limitFor(groups uint32) uint64 {
+	if m.maxLoad == 0 {
+		return defaultLimit(groups)
+	}
+	limit := uint64(float64(groups) * groupSize * float64(m.maxLoad))
+	if limit == 0 {
+		limit = 1
+	}
+	return limit
+}
+
 // numGroups returns the minimum number of groups needed to store |n| elems.
 func numGroups(n uint32) (groups uint32) {
-	groups = (n + maxAvgGroupLoad - 1) / maxAvgGroupLoad
+	// computed in 64 bits, as n + maxAvgGroupLoad may overflow
+	groups = uint32((uint64(n) + maxAvgGroupLoad - 1) / maxAvgGroupLoad)
+	if groups == 0 {
+		groups = 1
+	}
+	return
+}
+
+// defaultGroups returns the minimum number of groups needed to store |n|
+// elems within the default maximum load factor, panicking if a table of
+// that many groups cannot be indexed.
+func defaultGroups(n uint64) uint32 {
+	groups := numGroups64(n)
+	if groups > maxGroups {
+		panic("swiss: table size out of range")
+	}
+	return uint32(groups)
+}
+
+// defaultLimit returns the number of elements a table of |groups|
+// groups may hold within the default maximum load factor.
+func defaultLimit(groups uint32) uint64 {
+	return uint64(groups) * maxAvgGroupLoad
+}
+
+// numGroups64 returns the minimum number of groups needed to store |n|
+// elems, which may be more groups than a table can have.
+func numGroups64(n uint64) (groups uint64) {
+	groups = n / maxAvgGroupLoad
+	if n%maxAvgGroupLoad != 0 {
+		groups++
+	}
 	if groups == 0 {
 		groups = 1
 	}
 	return
 }
 
+// tableSize converts the element count |n| to a table
+// size, panicking if |n| is out of range.
+func tableSize(n int) uint32 {
+	if n < 0 || uint64(n) > math.MaxUint32-maxAvgGroupLoad {
+		panic("swiss: table size out of range")
+	}
+	return uint32(n)
+}
+
+// tableSize64 converts the element count |n| to the table size of a
+// Map, panicking if |n| is negative. The number of groups it needs is
+// checked by groupsFor.
+func tableSize64(n int) uint64 {
+	if n < 0 {
+		panic("swiss: table size out of range")
+	}
+	return uint64(n)
+}
+
 func newEmptyMetadata() (meta metadata) {
 	for i := range meta {
 		meta[i] = empty
@@ -331,7 +827,22 @@ func splitHash(h uint64) (h1, h2) {
 	return h1((h & h1Mask) >> 7), h2(h & h2Mask)
 }
 
+// joinHash returns the hash split into |hi| and |lo| by splitHash.
+func joinHash(hi h1, lo h2) uint64 {
+	return uint64(hi)<<7 | uint64(lo)
+}
+
+// probeStart returns the group at which the probe sequence of |hi| starts,
+// by a 64-bit fastModN of all 57 bits of |hi|, so that every group of a
+// table of more than 2^32 slots can start a probe sequence.
 func probeStart(hi h1, groups int) uint32 {
+	g, _ := bits.Mul64(uint64(hi)<<7, uint64(groups))
+	return uint32(g)
+}
+
+// probeStart32 is probeStart by a 32-bit fastModN of the low 32 bits of
+// |hi|, which lays out the tables of Frozen maps and SharedMaps.
+func probeStart32(hi h1, groups int) uint32 {
 	return fastModN(uint32(hi), uint32(groups))
 }
 
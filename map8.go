@@ -1,8 +1,6 @@
 package swiss
 
 import (
-	"github.com/dolthub/maphash"
-
 	"math/bits"
 	"unsafe"
 )
@@ -42,6 +40,10 @@ func fastrand8() uint32
 //goland:noinspection GoUnusedConst
 const (
 	maxLoadFactor8 = float32(maxAvgGroupLoad8) / float32(groupSize8)
+
+	// shrinkRatio8 is the ratio of capacity to elements
+	// below which an auto-shrinking Map8 is shrunk
+	shrinkRatio8 = 8
 )
 
 // Map8 is an open-addressing hash map
@@ -49,10 +51,15 @@ const (
 type Map8[K comparable, V any] struct {
 	ctrl     []metadata8
 	groups   []group8[K, V]
-	hash     maphash.Hasher[K]
+	hash     hasher[K]
 	resident uint32
 	dead     uint32
 	limit    uint32
+	// shrink enables automatic shrinking on Delete
+	shrink bool
+	// ptrs is true if K or V contain pointers,
+	// which removed slots must release
+	ptrs bool
 }
 
 // metadata8 is the h2E8 metadata8 array for a group8.
@@ -87,8 +94,9 @@ func NewMap8[K comparable, V any](sz uint32) (m *Map8[K, V]) {
 	m = &Map8[K, V]{
 		ctrl:   make([]metadata8, groups),
 		groups: make([]group8[K, V], groups),
-		hash:   maphash.NewHasher[K](),
+		hash:   newHasher[K](),
 		limit:  groups * maxAvgGroupLoad8,
+		ptrs:   hasPointers[K]() || hasPointers[V](),
 	}
 	for i := range m.ctrl {
 		m.ctrl[i] = newEmptyMetadata8()
@@ -195,19 +203,9 @@ func (m *Map8[K, V]) Delete(key K) (ok bool) {
 			s := nextMatch8(&matches)
 			if key == m.groups[g].keys[s] {
 				ok = true
-				// optimization: if |m.ctrl[g]| contains any empty8
-				// metadata8 bytes, we can physically delete |key|
-				// rather than placing a tombstone8.
-				// The observation is that any probes into group8 |g|
-				// would already be terminated by the existing empty8
-				// slot, and therefore reclaiming slot |s| will not
-				// cause premature termination of probes into |g|.
-				if metaMatchEmpty8(&m.ctrl[g]) != 0 {
-					m.ctrl[g][s] = empty8
-					m.resident--
-				} else {
-					m.ctrl[g][s] = tombstone8
-					m.dead++
+				m.deleteAt(g, s)
+				if m.shrink {
+					m.shrinkIfSparse()
 				}
 				return
 			}
@@ -261,6 +259,13 @@ func (m *Map8[K, V]) Clear() {
 			m.ctrl[i][j] = empty8
 		}
 	}
+	if m.ptrs {
+		// release all keys and values for the garbage
+		// collector, compiled to a bulk memory clear
+		for i := range m.groups {
+			m.groups[i] = group8[K, V]{}
+		}
+	}
 	m.resident, m.dead = 0, 0
 }
 
@@ -275,6 +280,44 @@ func (m *Map8[K, V]) Capacity() int {
 	return int(m.limit - m.resident)
 }
 
+// Grow ensures that |n| more elements can be added
+// to the Map8 without rehashing.
+func (m *Map8[K, V]) Grow(n int) {
+	if n <= m.Capacity() {
+		return
+	}
+	m.rehash(numGroups8(tableSize(m.Count() + n)))
+}
+
+// Shrink rehashes the Map8 into the smallest table that can hold its
+// elements, releasing the memory held by unused groups and tombstones.
+func (m *Map8[K, V]) Shrink() {
+	n := numGroups8(uint32(m.Count()))
+	if n < uint32(len(m.groups)) || m.dead > 0 {
+		m.rehash(n)
+	}
+}
+
+// Reset removes all elements from the Map8 and reallocates it
+// with room for |capacity| elements, releasing its previous table.
+func (m *Map8[K, V]) Reset(capacity int) {
+	groups := numGroups8(tableSize(capacity))
+	m.ctrl = make([]metadata8, groups)
+	m.groups = make([]group8[K, V], groups)
+	for i := range m.ctrl {
+		m.ctrl[i] = newEmptyMetadata8()
+	}
+	m.limit = groups * maxAvgGroupLoad8
+	m.resident, m.dead = 0, 0
+}
+
+// SetAutoShrink enables or disables automatic shrinking. When enabled,
+// Delete rehashes the Map8 into a smaller table once fewer than 1/8
+// of its capacity is in use, so that a drained Map8 releases its memory.
+func (m *Map8[K, V]) SetAutoShrink(enabled bool) {
+	m.shrink = enabled
+}
+
 // find returns the location of |key| if present, or its insertion location if absent.
 // for performance, find is manually inlined into public methods.
 func (m *Map8[K, V]) find(key K, hi h1E8, lo h2E8) (g, s uint32, ok bool) {
@@ -301,6 +344,57 @@ func (m *Map8[K, V]) find(key K, hi h1E8, lo h2E8) (g, s uint32, ok bool) {
 	}
 }
 
+// insert stores |key| and |value| at the insertion location |g|, |s|
+// returned by find, rehashing first if the Map8 is full. It returns
+// the location where |key| was stored.
+func (m *Map8[K, V]) insert(key K, value V, g, s uint32, lo h2E8) (uint32, uint32) {
+	if m.resident >= m.limit {
+		m.rehash(m.nextSize())
+		// rehashing reseeds |m.hash|
+		var hi h1E8
+		hi, lo = splitHash8(m.hash.Hash(key))
+		g, s, _ = m.find(key, hi, lo)
+	}
+	m.groups[g].keys[s] = key
+	m.groups[g].values[s] = value
+	m.ctrl[g][s] = int8(lo)
+	m.resident++
+	return g, s
+}
+
+// deleteAt removes the element stored in slot |s| of group8 |g|.
+func (m *Map8[K, V]) deleteAt(g, s uint32) {
+	// optimization: if |m.ctrl[g]| contains any empty8
+	// metadata8 bytes, we can physically delete |key|
+	// rather than placing a tombstone8.
+	// The observation is that any probes into group8 |g|
+	// would already be terminated by the existing empty8
+	// slot, and therefore reclaiming slot |s| will not
+	// cause premature termination of probes into |g|.
+	if metaMatchEmpty8(&m.ctrl[g]) != 0 {
+		m.ctrl[g][s] = empty8
+		m.resident--
+	} else {
+		m.ctrl[g][s] = tombstone8
+		m.dead++
+	}
+	if m.ptrs {
+		// release the key and value for the garbage collector
+		var k K
+		var v V
+		m.groups[g].keys[s], m.groups[g].values[s] = k, v
+	}
+}
+
+// shrinkIfSparse shrinks the Map8 if less than 1/shrinkRatio8 of its
+// capacity is in use, leaving room for it to double before growing again.
+func (m *Map8[K, V]) shrinkIfSparse() {
+	live := m.resident - m.dead
+	if len(m.groups) > 1 && live < m.limit/shrinkRatio8 {
+		m.rehash(numGroups8(live * 2))
+	}
+}
+
 func (m *Map8[K, V]) nextSize() (n uint32) {
 	n = uint32(len(m.groups)) * 2
 	if m.dead >= (m.resident / 2) {
@@ -316,7 +410,10 @@ func (m *Map8[K, V]) rehash(n uint32) {
 	for i := range m.ctrl {
 		m.ctrl[i] = newEmptyMetadata8()
 	}
-	m.hash = maphash.NewSeed(m.hash)
+	if m.hash.stable == nil {
+		// a StableHasher is kept
+		m.hash = m.hash.reseeded()
+	}
 	m.limit = n * maxAvgGroupLoad8
 	m.resident, m.dead = 0, 0
 	for g := range ctrl {
@@ -337,7 +434,7 @@ func (m *Map8[K, V]) loadFactor() float32 {
 
 // numGroups8 returns the minimum number of groups needed to store |n| elems.
 func numGroups8(n uint32) (groups uint32) {
-	groups = (n + maxAvgGroupLoad8 - 1) / maxAvgGroupLoad8
+	groups = uint32((uint64(n) + maxAvgGroupLoad8 - 1) / maxAvgGroupLoad8)
 	if groups == 0 {
 		groups = 1
 	}
@@ -80,6 +80,7 @@ type hasher8 struct {
 }
 
 func setConstSeed8[K comparable, V any](m *Map8[K, V], seed uintptr) {
-	h := (*hasher8)((unsafe.Pointer)(&m.hash))
+	h := (*hasher8)((unsafe.Pointer)(&m.hash.runtime))
 	h.seed = seed
+	m.hash = m.hash.bind()
 }
@@ -504,12 +504,96 @@ func TestNumGroups8(t *testing.T) {
 	assert.Equal(t, expected8(29), numGroups8(29))
 	assert.Equal(t, expected8(56), numGroups8(56))
 	assert.Equal(t, expected8(57), numGroups8(57))
+	assert.Equal(t, expected8(math.MaxUint32), numGroups8(math.MaxUint32))
 }
 
-func expected8(x int) (groups uint32) {
+func expected8(x uint64) (groups uint32) {
 	groups = uint32(math.Ceil(float64(x) / float64(maxAvgGroupLoad8)))
 	if groups == 0 {
 		groups = 1
 	}
 	return
 }
+
+func TestMap8Resize(t *testing.T) {
+	keys := genUint32Data8(10_000)
+	m := NewMap8[uint32, int](0)
+	m.Grow(len(keys))
+	groups := len(m.groups)
+	assert.GreaterOrEqual(t, m.Capacity(), len(keys))
+	for i, k := range keys {
+		m.Put(k, i)
+	}
+	assert.Equal(t, groups, len(m.groups), "Grow should pre-size the table")
+
+	for _, k := range keys[100:] {
+		m.Delete(k)
+	}
+	m.Shrink()
+	assert.Equal(t, int(numGroups8(100)), len(m.groups))
+	assert.Equal(t, 100, m.Count())
+	for i, k := range keys[:100] {
+		act, ok := m.Get(k)
+		assert.True(t, ok)
+		assert.Equal(t, i, act)
+	}
+
+	m.Reset(1000)
+	assert.Equal(t, 0, m.Count())
+	assert.GreaterOrEqual(t, m.Capacity(), 1000)
+	assert.False(t, m.Has(keys[0]))
+
+	m.SetAutoShrink(true)
+	for i, k := range keys {
+		m.Put(k, i)
+	}
+	groups = len(m.groups)
+	for _, k := range keys[10:] {
+		m.Delete(k)
+	}
+	assert.Less(t, len(m.groups), groups/8)
+	assert.Equal(t, 10, m.Count())
+	for i, k := range keys[:10] {
+		act, ok := m.Get(k)
+		assert.True(t, ok)
+		assert.Equal(t, i, act)
+	}
+	for _, k := range keys[:10] {
+		m.Delete(k)
+	}
+	assert.Equal(t, 1, len(m.groups))
+}
+
+func TestMap8ReleasesReferences(t *testing.T) {
+	keys := genStringData(16, 1000)
+	m := NewMap8[string, *int](0)
+	for i, k := range keys {
+		v := i
+		m.Put(k, &v)
+	}
+	for _, k := range keys[:500] {
+		m.Delete(k)
+	}
+	live := 0
+	for g := range m.groups {
+		for s, c := range m.ctrl[g] {
+			if c == empty8 || c == tombstone8 {
+				assert.Equal(t, "", m.groups[g].keys[s])
+				assert.Nil(t, m.groups[g].values[s])
+			} else {
+				live++
+			}
+		}
+	}
+	assert.Equal(t, 500, live)
+	m.Clear()
+	for g := range m.groups {
+		assert.Equal(t, group8[string, *int]{}, m.groups[g])
+	}
+
+	// pointer-free slots are left as-is
+	n := NewMap8[int, int](0)
+	n.Put(1, 1)
+	n.Delete(1)
+	assert.False(t, n.ptrs)
+}
@@ -108,6 +108,61 @@ func benchmarkSwissMap[K comparable](b *testing.B, keys []K) {
 	b.ReportAllocs()
 }
 
+// BenchmarkDelete measures the cost of releasing removed slots,
+// which is skipped for pointer-free keys and values.
+func BenchmarkDelete(b *testing.B) {
+	keys := generateInt64Data(1024)
+	b.Run("values=int64", func(b *testing.B) {
+		benchmarkDelete(b, keys, func(k int64) int64 { return k })
+	})
+	b.Run("values=*int64", func(b *testing.B) {
+		benchmarkDelete(b, keys, func(k int64) *int64 { return &k })
+	})
+}
+
+// BenchmarkClear measures the cost of releasing all slots,
+// which is skipped for pointer-free keys and values.
+func BenchmarkClear(b *testing.B) {
+	sizes := []int{1024, 131072}
+	for _, n := range sizes {
+		b.Run("n="+strconv.Itoa(n), func(b *testing.B) {
+			b.Run("values=int64", func(b *testing.B) {
+				benchmarkClear[int64](b, n)
+			})
+			b.Run("values=*int64", func(b *testing.B) {
+				benchmarkClear[*int64](b, n)
+			})
+		})
+	}
+}
+
+func benchmarkDelete[V any](b *testing.B, keys []int64, val func(int64) V) {
+	n := uint32(len(keys))
+	mod := n - 1 // power of 2 fast modulus
+	require.Equal(b, 1, bits.OnesCount32(n))
+	values := make([]V, n)
+	for i, k := range keys {
+		values[i] = val(k)
+	}
+	m := NewMap[int64, V](n)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		j := uint32(i) & mod
+		m.Put(keys[j], values[j])
+		m.Delete(keys[j])
+	}
+	b.ReportAllocs()
+}
+
+func benchmarkClear[V any](b *testing.B, n int) {
+	m := NewMap[int64, V](uint32(n))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Clear()
+	}
+	b.ReportAllocs()
+}
+
 func generateInt64Data(n int) (data []int64) {
 	data = make([]int64, n)
 	var x int64
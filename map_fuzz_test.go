@@ -16,7 +16,6 @@ package swiss
 
 import (
 	"testing"
-	"unsafe"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -45,12 +44,11 @@ func fuzzTestStringMap(t *testing.T, keySz, init, count uint32) {
 	if count > limit || init > limit {
 		t.Skip()
 	}
-	m := NewMap[string, int](init)
+	// make tests deterministic
+	m := NewMapWith[string, int](WithCapacity(int(init)), WithSeed(1), WithReseed(false))
 	if count == 0 {
 		return
 	}
-	// make tests deterministic
-	setConstSeed(m, 1)
 
 	keys := genStringData(int(keySz), int(count))
 	golden := make(map[string]int, init)
@@ -87,13 +85,3 @@ func fuzzTestStringMap(t *testing.T, keySz, init, count uint32) {
 		assert.Equal(t, exp, act)
 	}
 }
-
-type hasher struct {
-	hash func()
-	seed uintptr
-}
-
-func setConstSeed[K comparable, V any](m *Map[K, V], seed uintptr) {
-	h := (*hasher)((unsafe.Pointer)(&m.hash))
-	h.seed = seed
-}
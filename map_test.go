@@ -19,6 +19,7 @@ import (
 	"math"
 	"math/rand"
 	"testing"
+	"unsafe"
 
 	"github.com/stretchr/testify/require"
 
@@ -416,12 +417,349 @@ func TestNumGroups(t *testing.T) {
 	assert.Equal(t, expected(29), numGroups(29))
 	assert.Equal(t, expected(56), numGroups(56))
 	assert.Equal(t, expected(57), numGroups(57))
+	assert.Equal(t, expected(math.MaxUint32), numGroups(math.MaxUint32))
+	assert.Equal(t, uint32(math.MaxUint32/maxAvgGroupLoad+1), numGroups(math.MaxUint32))
 }
 
-func expected(x int) (groups uint32) {
+func TestGroupsFor(t *testing.T) {
+	m := NewMap[int, int](0)
+	for _, n := range []uint64{0, 1, 14, 15, 57, math.MaxUint32, math.MaxUint32 + 1} {
+		g := m.groupsFor(n)
+		assert.GreaterOrEqual(t, m.limitFor(g), n)
+		if g > 1 {
+			assert.Less(t, m.limitFor(g-1), n)
+		}
+	}
+	// tables of more than 2^32 slots
+	assert.Greater(t, uint64(m.groupsFor(math.MaxUint32))*groupSize, uint64(math.MaxUint32))
+	assert.Equal(t, uint64(maxGroups)*maxAvgGroupLoad, m.limitFor(maxGroups))
+	assert.Equal(t, uint32(maxGroups), m.groupsFor(m.limitFor(maxGroups)))
+	assert.Panics(t, func() { m.groupsFor(m.limitFor(maxGroups) + 1) })
+	assert.Panics(t, func() { m.groupsFor(math.MaxUint64) })
+	assert.Panics(t, func() { tableSize64(-1) })
+	assert.Equal(t, uint64(maxGroups), numGroups64(maxElems))
+	assert.Equal(t, uint64(maxGroups)+1, numGroups64(maxElems+1))
+	assert.Equal(t, uint64(maxElems), defaultLimit(maxGroups))
+	assert.Equal(t, uint32(maxGroups), defaultGroups(maxElems))
+	assert.Panics(t, func() { defaultGroups(maxElems + 1) })
+
+	m = NewMapWith[int, int](WithMaxLoadFactor(0.5))
+	assert.Equal(t, uint32(maxGroups), m.groupsFor(m.limitFor(maxGroups)))
+	assert.Panics(t, func() { m.groupsFor(m.limitFor(maxGroups) + 1) })
+}
+
+func TestProbeStart(t *testing.T) {
+	for _, groups := range []uint64{1, 2, 100, 1 << 20, math.MaxInt32} {
+		counts := make([]int, 16)
+		for i := 0; i < 32*1024; i++ {
+			hi, _ := splitHash(rand.Uint64())
+			g := probeStart(hi, int(groups))
+			require.Less(t, uint64(g), groups)
+			counts[uint64(g)*16/groups]++
+		}
+		if groups >= 16 {
+			// every part of the table starts probe sequences
+			for _, c := range counts {
+				assert.Greater(t, c, 1024)
+			}
+		}
+	}
+	// the high bits of |hi| pick the group, even if its low 32 bits are equal
+	assert.NotEqual(t, probeStart(h1(1)<<56, math.MaxInt32), probeStart(h1(1)<<55, math.MaxInt32))
+}
+
+func expected(x uint64) (groups uint32) {
 	groups = uint32(math.Ceil(float64(x) / float64(maxAvgGroupLoad)))
 	if groups == 0 {
 		groups = 1
 	}
 	return
 }
+
+func TestMapResize(t *testing.T) {
+	keys := genUint32Data(10_000)
+	m := NewMap[uint32, int](0)
+	m.Grow(len(keys))
+	groups := len(m.groups)
+	assert.GreaterOrEqual(t, m.Capacity(), len(keys))
+	for i, k := range keys {
+		m.Put(k, i)
+	}
+	assert.Equal(t, groups, len(m.groups), "Grow should pre-size the table")
+
+	for _, k := range keys[100:] {
+		m.Delete(k)
+	}
+	m.Shrink()
+	assert.Equal(t, int(numGroups(100)), len(m.groups))
+	assert.Equal(t, 100, m.Count())
+	for i, k := range keys[:100] {
+		act, ok := m.Get(k)
+		assert.True(t, ok)
+		assert.Equal(t, i, act)
+	}
+
+	m.Reset(1000)
+	assert.Equal(t, 0, m.Count())
+	assert.GreaterOrEqual(t, m.Capacity(), 1000)
+	assert.False(t, m.Has(keys[0]))
+
+	m.SetAutoShrink(true)
+	for i, k := range keys {
+		m.Put(k, i)
+	}
+	groups = len(m.groups)
+	for _, k := range keys[10:] {
+		m.Delete(k)
+	}
+	assert.Less(t, len(m.groups), groups/8)
+	assert.Equal(t, 10, m.Count())
+	for i, k := range keys[:10] {
+		act, ok := m.Get(k)
+		assert.True(t, ok)
+		assert.Equal(t, i, act)
+	}
+	for _, k := range keys[:10] {
+		m.Delete(k)
+	}
+	assert.Equal(t, 1, len(m.groups))
+}
+
+func TestMapReleasesReferences(t *testing.T) {
+	keys := genStringData(16, 1000)
+	m := NewMap[string, *int](0)
+	for i, k := range keys {
+		v := i
+		m.Put(k, &v)
+	}
+	for _, k := range keys[:500] {
+		m.Delete(k)
+	}
+	live := 0
+	for g := range m.groups {
+		for s, c := range m.ctrl[g] {
+			if c == empty || c == tombstone {
+				assert.Equal(t, "", m.groups[g].keys[s])
+				assert.Nil(t, m.groups[g].values[s])
+			} else {
+				live++
+			}
+		}
+	}
+	assert.Equal(t, 500, live)
+	m.Clear()
+	for g := range m.groups {
+		assert.Equal(t, group[string, *int]{}, m.groups[g])
+	}
+
+	// pointer-free slots are left as-is
+	n := NewMap[int, int](0)
+	n.Put(1, 1)
+	n.Delete(1)
+	assert.False(t, n.ptrs)
+}
+
+func TestMapCompact(t *testing.T) {
+	t.Run("queue", func(t *testing.T) {
+		// a queue keyed by ID: the oldest key is deleted as each new one
+		// is inserted, so the table fills with tombstones
+		m := NewMap[int, *int](2000)
+		for i := 0; i < 1000; i++ {
+			m.Put(i, &i)
+		}
+		groups := &m.groups[0]
+		for i := 1000; i < 100_000; i++ {
+			require.True(t, m.Delete(i-1000))
+			v := i
+			m.Put(i, &v)
+		}
+		assert.Equal(t, 1000, m.Count())
+		// the table was compacted, never reallocated
+		assert.True(t, groups == &m.groups[0])
+		for i := 99_000; i < 100_000; i++ {
+			v, ok := m.Get(i)
+			require.True(t, ok)
+			assert.Equal(t, i, *v)
+		}
+		for g := range m.groups {
+			for s, c := range m.ctrl[g] {
+				if c == empty {
+					assert.Nil(t, m.groups[g].values[s])
+				}
+			}
+		}
+	})
+	t.Run("random", func(t *testing.T) {
+		m := NewMap[uint32, uint32](0)
+		m.SetCompactRatio(0.25)
+		exp := make(map[uint32]uint32)
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 100_000; i++ {
+			k := uint32(r.Intn(2000))
+			if r.Intn(2) == 0 {
+				m.Put(k, uint32(i))
+				exp[k] = uint32(i)
+			} else {
+				assert.Equal(t, hasKey(exp, k), m.Delete(k))
+				delete(exp, k)
+			}
+			if i%10_000 == 0 {
+				m.Compact()
+				assert.Equal(t, uint64(0), m.dead)
+			}
+		}
+		assert.Equal(t, exp, ToMap(m))
+	})
+	t.Run("snapshot", func(t *testing.T) {
+		m := NewMap[int, int](0)
+		for i := 0; i < 10_000; i++ {
+			m.Put(i, i)
+		}
+		for i := 0; i < 5000; i++ {
+			m.Delete(i)
+		}
+		dead := m.dead
+		require.NotZero(t, dead)
+		s := m.Snapshot()
+		defer s.Close()
+		groups := &m.groups[0]
+		m.Compact()
+		// the table is left to the Snapshot
+		assert.False(t, groups == &m.groups[0])
+		assert.Equal(t, 5000, m.Count())
+		assert.Equal(t, 5000, s.Count())
+		for i := 5000; i < 10_000; i++ {
+			assert.Equal(t, i, must(s.Get(i)))
+		}
+	})
+	t.Run("iteration", func(t *testing.T) {
+		m := NewMap[int, int](0)
+		for i := 0; i < 100; i++ {
+			m.Put(i, i)
+		}
+		for i := 0; i < 50; i++ {
+			m.Delete(i)
+		}
+		seen := make(map[int]bool)
+		m.Iter(func(k, _ int) (stop bool) {
+			assert.False(t, seen[k])
+			seen[k] = true
+			m.Compact()
+			return
+		})
+		assert.Equal(t, 50, len(seen))
+		assert.Zero(t, m.iters)
+
+		it := m.Iterator()
+		assert.Equal(t, uint32(1), m.iters)
+		for it.Next() {
+		}
+		assert.Zero(t, m.iters)
+	})
+	t.Run("abandoned iterator", func(t *testing.T) {
+		m := NewMap[int, int](0)
+		for i := 0; i < 100; i++ {
+			m.Put(i, i)
+		}
+		for i := 0; i < 50; i++ {
+			m.Delete(i)
+		}
+		it := m.Iterator()
+		assert.True(t, it.Next())
+		assert.Equal(t, uint32(1), m.iters)
+		it.Stop()
+		assert.Zero(t, m.iters)
+		assert.False(t, it.Next())
+		it.Stop()
+		assert.Zero(t, m.iters)
+
+		// the table is compacted in place
+		groups := &m.groups[0]
+		m.Compact()
+		assert.True(t, groups == &m.groups[0])
+		assert.Zero(t, m.dead)
+	})
+	t.Run("copied iterator", func(t *testing.T) {
+		m := NewMap[int, int](0)
+		for i := 0; i < 100; i++ {
+			m.Put(i, i)
+		}
+		it := m.Iterator()
+		cp := it
+		for it.Next() {
+		}
+		for cp.Next() {
+		}
+		assert.Zero(t, m.iters)
+		cp.Stop()
+		it.Stop()
+		assert.Zero(t, m.iters)
+	})
+	t.Run("ratio", func(t *testing.T) {
+		m := NewMap[int, int](0)
+		assert.Panics(t, func() { m.SetCompactRatio(0) })
+		assert.Panics(t, func() { m.SetCompactRatio(1.5) })
+		m.SetCompactRatio(1)
+	})
+}
+
+func hasKey[K comparable, V any](m map[K]V, k K) (ok bool) {
+	_, ok = m[k]
+	return
+}
+
+func TestMapRehashHashes(t *testing.T) {
+	// countHashes makes |m| count the keys it hashes
+	countHashes := func(m *Map[string, int]) *int {
+		n := new(int)
+		h, err := NewStableHasher[string](1)
+		require.NoError(t, err)
+		m.hash = hasher[string]{seed: 1, stable: func(seed uint64, key unsafe.Pointer) uint64 {
+			*n++
+			return h.hash(seed, key)
+		}}.bind()
+		return n
+	}
+	keys := genStringData(64, 10_000)
+	for _, test := range []struct {
+		name        string
+		keep, cache bool
+		hashed      int
+	}{
+		{name: "default", hashed: 1},
+		{name: "keep seed", keep: true, hashed: 1},
+		{name: "cache", cache: true, hashed: 1},
+		{name: "keep seed and cache", keep: true, cache: true, hashed: 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			m := NewMap[string, int](0)
+			m.SetKeepSeed(test.keep)
+			m.SetCacheHashes(test.cache)
+			n := countHashes(m)
+			for i, k := range keys {
+				m.Put(k, i)
+			}
+			*n = 0
+			seed := m.hash.seed
+			m.rehash(uint32(len(m.groups)) * 2)
+			assert.Equal(t, test.hashed*len(keys), *n)
+			assert.Equal(t, test.keep, seed == m.hash.seed)
+
+			for _, k := range keys[:len(keys)/2] {
+				m.Delete(k)
+			}
+			*n = 0
+			m.Compact()
+			if test.cache {
+				assert.Equal(t, 0, *n)
+			}
+			for i, k := range keys[len(keys)/2:] {
+				require.Equal(t, len(keys)/2+i, must(m.Get(k)))
+			}
+			assert.Equal(t, len(keys)/2, m.Count())
+			c := Clone(m)
+			c.rehash(uint32(len(c.groups)) * 2)
+			assert.True(t, Equal(m, c))
+		})
+	}
+}
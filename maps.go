@@ -0,0 +1,187 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+// This file mirrors the standard library's maps package for Map.
+
+// Clone returns a copy of |m|. The table is copied wholesale,
+// so no key is rehashed. Keys and values are copied as if by
+// assignment, so this is a shallow clone.
+func Clone[K comparable, V any](m *Map[K, V]) *Map[K, V] {
+	m.settle()
+	c := *m
+	c.ext, c.iters = nil, 0
+	c.ctrl = make([]metadata, len(m.ctrl))
+	copy(c.ctrl, m.ctrl)
+	c.groups = make([]group[K, V], len(m.groups))
+	copy(c.groups, m.groups)
+	if m.hashes != nil {
+		c.hashes = make([]hashGroup, len(m.hashes))
+		copy(c.hashes, m.hashes)
+	}
+	return &c
+}
+
+// Copy copies all key-value pairs of |src| into |dst|,
+// overwriting the values of keys already present in |dst|.
+// If |dst| is empty, it adopts a copy of the table of |src|
+// and no key is rehashed, provided both Maps have the same
+// maximum load factor, cache hashes alike, and hash keys
+// alike. The hashers of NewMap hash keys alike, as |dst|
+// then adopts the random seed of |src|, while a Map given
+// a StableHasher or WithSeed only does so with the same seed.
+func Copy[K comparable, V any](dst, src *Map[K, V]) {
+	if dst == src {
+		return
+	}
+	dst.settle()
+	src.settle()
+	adopt := dst.Count() == 0 && dst.maxLoad == src.maxLoad &&
+		(dst.hashes == nil) == (src.hashes == nil) &&
+		(dst.hash.same(src.hash) || (dst.hash.stable == nil && src.hash.stable == nil))
+	if adopt {
+		if len(dst.groups) == len(src.groups) && !dst.hasSnapshots() {
+			copy(dst.ctrl, src.ctrl)
+			copy(dst.groups, src.groups)
+			copy(dst.hashes, src.hashes)
+		} else {
+			dst.ctrl = make([]metadata, len(src.ctrl))
+			copy(dst.ctrl, src.ctrl)
+			dst.groups = make([]group[K, V], len(src.groups))
+			copy(dst.groups, src.groups)
+			if src.hashes != nil {
+				dst.hashes = make([]hashGroup, len(src.hashes))
+				copy(dst.hashes, src.hashes)
+			}
+		}
+		dst.hash = src.hash
+		dst.resident, dst.dead, dst.limit = src.resident, src.dead, src.limit
+		dst.epoch++
+		if dst.ext != nil {
+			dst.afterReplace()
+		}
+		dst.detach()
+		return
+	}
+	dst.Grow(src.Count())
+	for g := range src.ctrl {
+		for s, c := range src.ctrl[g] {
+			if c == empty || c == tombstone {
+				continue
+			}
+			dst.Put(src.groups[g].keys[s], src.groups[g].values[s])
+		}
+	}
+}
+
+// Equal returns true if |m1| and |m2| contain the same key-value pairs.
+func Equal[K, V comparable](m1, m2 *Map[K, V]) bool {
+	return EqualFunc(m1, m2, func(v1, v2 V) bool {
+		return v1 == v2
+	})
+}
+
+// EqualFunc is like Equal, but compares values using |eq|.
+// Keys are still compared with ==.
+func EqualFunc[K comparable, V1, V2 any](m1 *Map[K, V1], m2 *Map[K, V2], eq func(V1, V2) bool) bool {
+	if m1.Count() != m2.Count() {
+		return false
+	}
+	m1.settle()
+	for g := range m1.ctrl {
+		for s, c := range m1.ctrl[g] {
+			if c == empty || c == tombstone {
+				continue
+			}
+			v2, ok := m2.Get(m1.groups[g].keys[s])
+			if !ok || !eq(m1.groups[g].values[s], v2) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// DeleteFunc removes every key-value pair of |m| for which |del| returns true.
+func DeleteFunc[K comparable, V any](m *Map[K, V], del func(K, V) bool) {
+	m.settle()
+	for g := range m.ctrl {
+		for s, c := range m.ctrl[g] {
+			if c == empty || c == tombstone {
+				continue
+			}
+			if del(m.groups[g].keys[s], m.groups[g].values[s]) {
+				m.deleteAt(uint32(g), uint32(s))
+			}
+		}
+	}
+	if m.shrink {
+		m.shrinkIfSparse()
+	}
+}
+
+// Keys returns the keys of |m| in an unspecified order.
+func Keys[K comparable, V any](m *Map[K, V]) []K {
+	m.settle()
+	keys := make([]K, 0, m.Count())
+	for g := range m.ctrl {
+		for s, c := range m.ctrl[g] {
+			if c == empty || c == tombstone {
+				continue
+			}
+			keys = append(keys, m.groups[g].keys[s])
+		}
+	}
+	return keys
+}
+
+// Values returns the values of |m| in an unspecified order.
+func Values[K comparable, V any](m *Map[K, V]) []V {
+	m.settle()
+	values := make([]V, 0, m.Count())
+	for g := range m.ctrl {
+		for s, c := range m.ctrl[g] {
+			if c == empty || c == tombstone {
+				continue
+			}
+			values = append(values, m.groups[g].values[s])
+		}
+	}
+	return values
+}
+
+// FromMap returns a Map containing the key-value pairs of |src|.
+func FromMap[K comparable, V any](src map[K]V) *Map[K, V] {
+	m := NewMapWith[K, V](WithCapacity(len(src)))
+	for k, v := range src {
+		m.Put(k, v)
+	}
+	return m
+}
+
+// ToMap returns a builtin map containing the key-value pairs of |m|.
+func ToMap[K comparable, V any](m *Map[K, V]) map[K]V {
+	m.settle()
+	dst := make(map[K]V, m.Count())
+	for g := range m.ctrl {
+		for s, c := range m.ctrl[g] {
+			if c == empty || c == tombstone {
+				continue
+			}
+			dst[m.groups[g].keys[s]] = m.groups[g].values[s]
+		}
+	}
+	return dst
+}
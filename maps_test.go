@@ -0,0 +1,132 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapsHelpers(t *testing.T) {
+	keys := genUint32Data(1000)
+	golden := make(map[uint32]int, len(keys))
+	for i, k := range keys {
+		golden[k] = i
+	}
+
+	t.Run("from map and to map", func(t *testing.T) {
+		m := FromMap(golden)
+		assert.Equal(t, len(golden), m.Count())
+		assert.Equal(t, golden, ToMap(m))
+		assert.ElementsMatch(t, keys, Keys(m))
+		vals := Values(m)
+		assert.Len(t, vals, len(keys))
+		for _, v := range vals {
+			assert.Equal(t, v, golden[keys[v]])
+		}
+	})
+	t.Run("clone", func(t *testing.T) {
+		m := FromMap(golden)
+		m.Delete(keys[0])
+		c := Clone(m)
+		assert.True(t, Equal(m, c))
+		assert.Equal(t, m.resident, c.resident)
+		assert.Equal(t, m.dead, c.dead)
+		// clones do not share storage
+		c.Put(keys[1], -1)
+		c.Put(keys[0], -1)
+		assert.Equal(t, golden[keys[1]], must(m.Get(keys[1])))
+		assert.False(t, m.Has(keys[0]))
+		assert.False(t, Equal(m, c))
+		for _, k := range keys {
+			c.Delete(k)
+		}
+		assert.Equal(t, len(keys)-1, m.Count())
+	})
+	t.Run("copy", func(t *testing.T) {
+		src := FromMap(golden)
+		// empty destination adopts the table of |src|
+		dst := NewMap[uint32, int](0)
+		Copy(dst, src)
+		assert.True(t, Equal(src, dst))
+		dst.Put(keys[0], -1)
+		assert.Equal(t, golden[keys[0]], must(src.Get(keys[0])))
+
+		// non-empty destination is merged into
+		dst = NewMap[uint32, int](0)
+		dst.Put(keys[0], -1)
+		dst.Put(0xdeadbeef, 1)
+		Copy(dst, src)
+		assert.Equal(t, len(golden)+1, dst.Count())
+		for k, v := range golden {
+			assert.Equal(t, v, must(dst.Get(k)))
+		}
+		Copy(dst, dst)
+		assert.Equal(t, len(golden)+1, dst.Count())
+
+		// a seeded destination keeps its own hasher
+		dst = NewMapWith[uint32, int](WithSeed(1), WithReseed(false))
+		Copy(dst, src)
+		assert.NotNil(t, dst.hash.stable)
+		assert.Equal(t, uint64(1), dst.hash.seed)
+		assert.True(t, Equal(src, dst))
+	})
+	t.Run("equal", func(t *testing.T) {
+		m1, m2 := FromMap(golden), NewMap[uint32, int](0)
+		assert.False(t, Equal(m1, m2))
+		// insert in reverse order so the layouts differ
+		for i := len(keys) - 1; i >= 0; i-- {
+			m2.Put(keys[i], i)
+		}
+		assert.True(t, Equal(m1, m2))
+		m2.Put(keys[0], -1)
+		assert.False(t, Equal(m1, m2))
+		m2.Delete(keys[0])
+		m2.Put(0xdeadbeef, 0)
+		assert.False(t, Equal(m1, m2))
+
+		strs := NewMap[uint32, string](0)
+		for k, v := range golden {
+			strs.Put(k, strconv.Itoa(v))
+		}
+		assert.True(t, EqualFunc(m1, strs, func(v1 int, v2 string) bool {
+			return strconv.Itoa(v1) == v2
+		}))
+	})
+	t.Run("delete func", func(t *testing.T) {
+		m := FromMap(golden)
+		DeleteFunc(m, func(k uint32, v int) bool {
+			return v%2 == 1
+		})
+		assert.Equal(t, (len(keys)+1)/2, m.Count())
+		for i, k := range keys {
+			assert.Equal(t, i%2 == 0, m.Has(k))
+		}
+		m.SetAutoShrink(true)
+		groups := len(m.groups)
+		DeleteFunc(m, func(uint32, int) bool { return true })
+		assert.Equal(t, 0, m.Count())
+		assert.Less(t, len(m.groups), groups)
+	})
+}
+
+func must[V any](v V, ok bool) V {
+	if !ok {
+		panic("missing value")
+	}
+	return v
+}
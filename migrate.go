@@ -0,0 +1,211 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+// migrationStep is the least number of groups of the old table that
+// each write migrates while a Map is rehashed incrementally.
+const migrationStep = 4
+
+// migration is the old table of a Map being rehashed incrementally.
+// Groups before |next| have been migrated to the new table, and the
+// elements of the remaining groups are in the old table only.
+type migration[K comparable, V any] struct {
+	ctrl   []metadata
+	groups []group[K, V]
+	hashes []hashGroup
+	next   uint32
+	// live is the number of elements left in the table
+	live uint64
+	// step is the number of groups migrated by each
+	// write, enough to finish before the Map is full
+	step uint32
+}
+
+// SetIncrementalRehash enables or disables incremental rehashing. By
+// default, the insert that fills a Map rehashes every element into a new
+// table at once, which stalls for as long as it takes to rehash them all.
+// When enabled, that insert allocates the new table but leaves the
+// elements in the old one, and each later write (Put, Delete, GetOrPut,
+// Compute, Entry, and the Ptr and Ref methods) migrates a bounded number
+// of groups, so that no single write rehashes more than a few dozen
+// elements. Maps with too many tombstones are compacted the same way.
+//
+// Until the migration finishes, both tables are held in memory. For a
+// growing Map, that is an overhead of the old table, half the size of the
+// new one, held for up to one write per four groups of the old table rather
+// than only for the duration of a rehash. Get and Has probe
+// both tables for keys absent from the new one, and the hash seed of the
+// Map is kept rather than renewed. Iter, Iterator, Snapshot, Grow, Shrink,
+// Compact and the functions visiting every element, such as Clone, Keys
+// and MarshalBinary, finish the migration first, as does disabling it.
+// A Map being iterated by Iter or an Iterator rehashes at once instead.
+func (m *Map[K, V]) SetIncrementalRehash(enabled bool) {
+	m.incremental = enabled
+	if !enabled {
+		m.settle()
+	}
+}
+
+// startMigration replaces the table of |m| by an empty table of |n|
+// groups, keeping the old one to be migrated by later writes.
+func (m *Map[K, V]) startMigration(n uint32) {
+	live := uint64(m.Count())
+	o := &migration[K, V]{ctrl: m.ctrl, groups: m.groups, hashes: m.hashes, live: live}
+	m.ctrl = make([]metadata, n)
+	m.groups = make([]group[K, V], n)
+	for i := range m.ctrl {
+		m.ctrl[i] = newEmptyMetadata()
+	}
+	if m.hashes != nil {
+		m.hashes = make([]hashGroup, n)
+	}
+	m.limit = m.limitFor(n)
+	m.resident, m.dead = 0, 0
+	m.epoch++
+	// each write adds at most one element to the new table, so
+	// the migration must finish within |room| writes
+	room := m.limit - live
+	o.step = uint32((uint64(len(o.groups)) + room - 1) / room)
+	if o.step < migrationStep {
+		o.step = migrationStep
+	}
+	m.old = o
+}
+
+// migrate moves |key| to the new table if it is still in the old one,
+// so that writers only need to find it in the new table, and then
+// continues the migration.
+func (m *Map[K, V]) migrate(key K, hi h1, lo h2) {
+	o := m.old
+	if g, s, ok := o.find(key, hi, lo); ok {
+		m.place(o.groups[g].keys[s], o.groups[g].values[s], hi, lo)
+		o.remove(g, s, m.ptrs)
+	}
+	m.migrateGroups(o.step)
+}
+
+// settle finishes the migration of |m|, if one is in progress.
+func (m *Map[K, V]) settle() {
+	if m.old != nil {
+		m.migrateGroups(uint32(len(m.old.groups)))
+	}
+}
+
+// migrateGroups migrates up to |n| groups of the old table,
+// releasing it once every group has been migrated.
+func (m *Map[K, V]) migrateGroups(n uint32) {
+	o := m.old
+	for ; n > 0 && o.next < uint32(len(o.groups)); n-- {
+		g := o.next
+		for s, c := range o.ctrl[g] {
+			if c == empty || c == tombstone {
+				continue
+			}
+			var (
+				hi h1
+				lo h2
+			)
+			if o.hashes != nil {
+				hi, lo = splitHash(o.hashes[g][s])
+			} else {
+				hi, lo = splitHash(m.hash.Hash(o.groups[g].keys[s]))
+			}
+			m.place(o.groups[g].keys[s], o.groups[g].values[s], hi, lo)
+			o.live--
+		}
+		// the elements of |g| now live in the new table only
+		o.ctrl[g] = newEmptyMetadata()
+		if m.ptrs {
+			// release the keys and values for the garbage collector
+			o.groups[g] = group[K, V]{}
+		}
+		o.next++
+	}
+	if o.next == uint32(len(o.groups)) {
+		m.old = nil
+	}
+}
+
+// place stores |key| and |value| in the first empty slot of their probe
+// sequence. Unlike insert, it does not call the hooks of |m|, as it moves
+// an element rather than adding one.
+func (m *Map[K, V]) place(key K, value V, hi h1, lo h2) {
+	g := probeStart(hi, len(m.groups))
+	for {
+		matches := metaMatchEmpty(&m.ctrl[g])
+		if matches != 0 {
+			s := nextMatch(&matches)
+			m.groups[g].keys[s] = key
+			m.groups[g].values[s] = value
+			m.ctrl[g][s] = int8(lo)
+			if m.hashes != nil {
+				m.hashes[g][s] = joinHash(hi, lo)
+			}
+			m.resident++
+			return
+		}
+		g += 1 // linear probing
+		if g >= uint32(len(m.groups)) {
+			g = 0
+		}
+	}
+}
+
+// find returns the location of |key| in the old table. Migrated groups
+// are skipped, as are the groups before them on any probe sequence: an
+// element that is yet to be migrated is never found past them.
+func (o *migration[K, V]) find(key K, hi h1, lo h2) (g, s uint32, ok bool) {
+	n := uint32(len(o.groups))
+	g = probeStart(hi, len(o.groups))
+	if g < o.next {
+		g = o.next
+	}
+	for i := o.next; i < n; i++ {
+		matches := metaMatchH2(&o.ctrl[g], lo)
+		for matches != 0 {
+			s = nextMatch(&matches)
+			if key == o.groups[g].keys[s] {
+				return g, s, true
+			}
+		}
+		// |key| is not in group |g|,
+		// stop probing if we see an empty slot
+		if metaMatchEmpty(&o.ctrl[g]) != 0 {
+			return
+		}
+		g += 1 // linear probing
+		if g >= n {
+			g = o.next
+		}
+	}
+	return
+}
+
+// remove removes the element in slot |s| of group |g| of the old table.
+func (o *migration[K, V]) remove(g, s uint32, ptrs bool) {
+	// as in deleteAt, a group with an empty slot
+	// does not need a tombstone
+	if metaMatchEmpty(&o.ctrl[g]) != 0 {
+		o.ctrl[g][s] = empty
+	} else {
+		o.ctrl[g][s] = tombstone
+	}
+	if ptrs {
+		var k K
+		var v V
+		o.groups[g].keys[s], o.groups[g].values[s] = k, v
+	}
+	o.live--
+}
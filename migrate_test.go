@@ -0,0 +1,207 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncrementalRehash(t *testing.T) {
+	m := NewMap[uint32, uint32](0)
+	m.SetIncrementalRehash(true)
+	keys := uniq(genUint32Data(100_000))
+	migrations := 0
+	for i, k := range keys {
+		groups := len(m.groups)
+		m.Put(k, uint32(i))
+		if len(m.groups) != groups {
+			// each write, including the Put that grows
+			// the Map, migrates a few groups
+			migrations++
+			if groups > migrationStep {
+				require.NotNil(t, m.old)
+				assert.Equal(t, uint32(migrationStep), m.old.step)
+				assert.Equal(t, m.old.step, m.old.next)
+			}
+		} else if m.old != nil {
+			assert.LessOrEqual(t, int(m.old.next), groups/2)
+		}
+		require.Equal(t, i+1, m.Count())
+	}
+	assert.Greater(t, migrations, 10)
+	for i, k := range keys {
+		v, ok := m.Get(k)
+		require.True(t, ok)
+		assert.Equal(t, uint32(i), v)
+	}
+
+	// keys in the old table are found by every operation
+	for k := uint32(0); m.old == nil; k++ {
+		if !m.Has(k) {
+			m.Put(k, 0)
+			keys = append(keys, k)
+		}
+	}
+	assert.True(t, m.Has(keys[0]))
+	assert.True(t, m.Delete(keys[1]))
+	assert.False(t, m.Has(keys[1]))
+	assert.Equal(t, uint32(0), must(m.Compute(keys[0], func(old uint32, ok bool) (uint32, bool) {
+		assert.True(t, ok)
+		return old, true
+	})))
+	assert.Equal(t, uint32(2), *m.GetPtr(keys[2]))
+	v, loaded := m.GetOrPut(keys[3], 0)
+	assert.True(t, loaded)
+	assert.Equal(t, uint32(3), v)
+	assert.Equal(t, len(keys)-1, m.Count())
+	assert.Equal(t, len(keys)-1, len(ToMap(m)))
+	assert.Nil(t, m.old)
+}
+
+func TestIncrementalRehashRandom(t *testing.T) {
+	m := NewMap[uint32, uint32](0)
+	m.SetIncrementalRehash(true)
+	m.SetCompactRatio(0.1)
+	require.NoError(t, m.EnableDigest())
+	replica := NewMap[uint32, uint32](0)
+	var log OpLog[uint32, uint32]
+	cancel := m.Observe(log.Record)
+	defer cancel()
+
+	exp := make(map[uint32]uint32)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200_000; i++ {
+		k, v := uint32(r.Intn(20_000)), uint32(i)
+		switch r.Intn(8) {
+		case 0, 1:
+			_, ok := exp[k]
+			require.Equal(t, ok, m.Delete(k))
+			delete(exp, k)
+		case 2:
+			m.Entry(k).AndModify(func(old uint32) uint32 { return old + v }).OrInsert(v)
+			if old, ok := exp[k]; ok {
+				exp[k] = old + v
+			} else {
+				exp[k] = v
+			}
+		case 3:
+			actual, _ := m.GetOrPut(k, v)
+			if old, ok := exp[k]; ok {
+				require.Equal(t, old, actual)
+			} else {
+				exp[k] = v
+			}
+		case 4:
+			m.Compute(k, func(old uint32, ok bool) (uint32, bool) {
+				return v, v%2 == 0
+			})
+			if v%2 == 0 {
+				exp[k] = v
+			} else {
+				delete(exp, k)
+			}
+		case 5:
+			act, ok := m.Get(k)
+			old, exp := exp[k]
+			require.Equal(t, exp, ok)
+			require.Equal(t, old, act)
+		default:
+			m.Put(k, v)
+			exp[k] = v
+		}
+		require.Equal(t, len(exp), m.Count())
+	}
+	assert.Equal(t, exp, ToMap(m))
+	log.Replay(replica)
+	assert.True(t, Equal(m, replica))
+	d := m.ext.digest
+	assert.Equal(t, d.compute(m), m.Digest())
+}
+
+func TestIncrementalRehashSettles(t *testing.T) {
+	fill := func() *Map[int, int] {
+		m := NewMap[int, int](0)
+		m.SetIncrementalRehash(true)
+		for i := 0; m.old == nil || i < 1000; i++ {
+			m.Put(i, i)
+		}
+		require.NotNil(t, m.old)
+		return m
+	}
+	for name, settle := range map[string]func(m *Map[int, int]){
+		"iter":     func(m *Map[int, int]) { m.Iter(func(int, int) bool { return true }) },
+		"iterator": func(m *Map[int, int]) { m.Iterator() },
+		"snapshot": func(m *Map[int, int]) { m.Snapshot().Close() },
+		"clone":    func(m *Map[int, int]) { Clone(m) },
+		"grow":     func(m *Map[int, int]) { m.Grow(m.Capacity() + 1) },
+		"disable":  func(m *Map[int, int]) { m.SetIncrementalRehash(false) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			m := fill()
+			n := m.Count()
+			settle(m)
+			assert.Nil(t, m.old)
+			assert.Equal(t, n, m.Count())
+			for i := 0; i < n; i++ {
+				require.Equal(t, i, must(m.Get(i)))
+			}
+		})
+	}
+	t.Run("clear", func(t *testing.T) {
+		m := fill()
+		m.Clear()
+		assert.Nil(t, m.old)
+		assert.Equal(t, 0, m.Count())
+		assert.False(t, m.Has(0))
+	})
+}
+
+func TestIncrementalRehashDuringIteration(t *testing.T) {
+	m := NewMap[string, int](0)
+	m.SetIncrementalRehash(true)
+	keys := genStringData(16, 100)
+	for i, k := range keys[:50] {
+		m.Put(k, i)
+	}
+	seen := make(map[string]bool)
+	it := m.Iterator()
+	for i := 50; it.Next(); i++ {
+		require.NotEqual(t, "", it.Key())
+		seen[it.Key()] = true
+		if i < len(keys) {
+			// grow the Map while it is being iterated
+			m.Put(keys[i], i)
+			assert.Nil(t, m.old)
+		}
+	}
+	for _, k := range keys[:50] {
+		assert.True(t, seen[k])
+	}
+	m.Iter(func(k string, v int) bool {
+		require.NotEqual(t, "", k)
+		m.Put(k+"!", v)
+		assert.Nil(t, m.old)
+		return false
+	})
+	// once iteration is over, growth is incremental again
+	for i := 0; m.old == nil; i++ {
+		m.Put(strconv.Itoa(i), i)
+	}
+}
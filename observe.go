@@ -0,0 +1,148 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+// Op is the kind of an Event.
+type Op uint8
+
+const (
+	// OpPut stores Value under Key.
+	OpPut Op = iota + 1
+	// OpDelete removes Key.
+	OpDelete
+	// OpClear removes every element.
+	OpClear
+)
+
+func (op Op) String() string {
+	switch op {
+	case OpPut:
+		return "put"
+	case OpDelete:
+		return "delete"
+	case OpClear:
+		return "clear"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a modification of a Map, as passed to its observers.
+type Event[K comparable, V any] struct {
+	Op    Op
+	Key   K
+	Value V
+	// Old is the value replaced or deleted
+	// by the Event, if HasOld is true
+	Old    V
+	HasOld bool
+}
+
+type observer[K comparable, V any] struct {
+	fn func(e Event[K, V])
+}
+
+// Observe registers |fn| to be called with every Event of |m|, and returns
+// a function that unregisters it. Events are delivered synchronously, in
+// the order they happen. Put and Delete Events are delivered before they
+// are applied to |m|, and Clear Events after. |fn| must not modify |m|.
+// Rehashing |m| produces no Events.
+//
+// Values modified through pointers returned by GetPtr, PutPtr and Ref.Ptr
+// are not observed; PutPtr observes a Put of the zero value. Observers are
+// not carried over by Clone. A Map without observers pays no cost for them.
+func (m *Map[K, V]) Observe(fn func(e Event[K, V])) (cancel func()) {
+	o := &observer[K, V]{fn: fn}
+	if m.ext == nil {
+		m.ext = &mapExt[K, V]{}
+	}
+	m.ext.observers = append(m.ext.observers, o)
+	return func() {
+		if m.ext == nil {
+			return
+		}
+		for i, x := range m.ext.observers {
+			if x == o {
+				// copy, as |m.ext.observers| may be being notified
+				m.ext.observers = append(m.ext.observers[:i:i], m.ext.observers[i+1:]...)
+				m.releaseExt()
+				return
+			}
+		}
+	}
+}
+
+// notify passes |e| to the observers of |m|.
+func (m *Map[K, V]) notify(e Event[K, V]) {
+	for _, o := range m.ext.observers {
+		o.fn(e)
+	}
+}
+
+// OpLog buffers the Events of a Map, so that they can be replayed on
+// another Map. Register it with Observe:
+//
+//	var log swiss.OpLog[K, V]
+//	cancel := m.Observe(log.Record)
+type OpLog[K comparable, V any] struct {
+	events []Event[K, V]
+}
+
+// Record appends |e| to |l|. A Clear Event
+// discards the Events recorded before it.
+func (l *OpLog[K, V]) Record(e Event[K, V]) {
+	if e.Op == OpClear {
+		// earlier Events are overwritten by the Clear
+		l.Reset()
+	}
+	l.events = append(l.events, e)
+}
+
+// Len returns the number of Events in |l|.
+func (l *OpLog[K, V]) Len() int {
+	return len(l.events)
+}
+
+// Iter passes the Events of |l| to the callback in the order they happened.
+func (l *OpLog[K, V]) Iter(cb func(e Event[K, V]) (stop bool)) {
+	for _, e := range l.events {
+		if stop := cb(e); stop {
+			return
+		}
+	}
+}
+
+// Replay applies the Events of |l| to |m|, in the order they happened.
+func (l *OpLog[K, V]) Replay(m *Map[K, V]) {
+	for _, e := range l.events {
+		switch e.Op {
+		case OpPut:
+			m.Put(e.Key, e.Value)
+		case OpDelete:
+			m.Delete(e.Key)
+		case OpClear:
+			m.Clear()
+		}
+	}
+}
+
+// Reset removes all Events from |l|, retaining its buffer.
+func (l *OpLog[K, V]) Reset() {
+	var zero Event[K, V]
+	for i := range l.events {
+		l.events[i] = zero
+	}
+	l.events = l.events[:0]
+}
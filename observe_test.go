@@ -0,0 +1,136 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserve(t *testing.T) {
+	m := NewMap[string, int](0)
+	var events []Event[string, int]
+	cancel := m.Observe(func(e Event[string, int]) {
+		events = append(events, e)
+	})
+	m.Put("a", 1)
+	m.Put("a", 2)
+	m.Delete("a")
+	m.Delete("missing")
+	m.Entry("b").OrInsert(3)
+	m.Compute("b", func(old int, ok bool) (int, bool) { return old + 1, true })
+	m.Clear()
+	assert.Equal(t, []Event[string, int]{
+		{Op: OpPut, Key: "a", Value: 1},
+		{Op: OpPut, Key: "a", Value: 2, Old: 1, HasOld: true},
+		{Op: OpDelete, Key: "a", Old: 2, HasOld: true},
+		{Op: OpPut, Key: "b", Value: 3},
+		{Op: OpPut, Key: "b", Value: 4, Old: 3, HasOld: true},
+		{Op: OpClear},
+	}, events)
+
+	// growing the Map is not observed
+	events = nil
+	for i := 0; i < 100; i++ {
+		m.Put(string(rune('a'+i)), i)
+	}
+	assert.Len(t, events, 100)
+
+	cancel()
+	assert.Nil(t, m.ext)
+	m.Put("c", 5)
+	assert.Len(t, events, 100)
+	cancel()
+}
+
+func TestObserveCancel(t *testing.T) {
+	m := NewMap[int, int](0)
+	var a, b int
+	var cancelA func()
+	cancelA = m.Observe(func(e Event[int, int]) {
+		a++
+		cancelA()
+	})
+	cancelB := m.Observe(func(e Event[int, int]) { b++ })
+	m.Put(1, 1)
+	m.Put(2, 2)
+	assert.Equal(t, 1, a)
+	assert.Equal(t, 2, b)
+	cancelB()
+	assert.Nil(t, m.ext)
+
+	// with a Snapshot and a digest
+	s := m.Snapshot()
+	require.NoError(t, m.EnableDigest())
+	cancelB = m.Observe(func(e Event[int, int]) { b++ })
+	m.Put(3, 3)
+	cancelB()
+	s.Close()
+	m.Put(4, 4)
+	m.DisableDigest()
+	assert.Nil(t, m.ext)
+	assert.Equal(t, 3, b)
+	assert.Equal(t, 2, s.Count())
+}
+
+func TestOpLog(t *testing.T) {
+	primary := NewMap[uint32, uint32](0)
+	replica := NewMap[uint32, uint32](0)
+	var log OpLog[uint32, uint32]
+	cancel := primary.Observe(log.Record)
+	defer cancel()
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		for j := 0; j < 200; j++ {
+			k, v := uint32(r.Intn(1000)), r.Uint32()
+			switch r.Intn(10) {
+			case 0:
+				primary.Delete(k)
+			case 1:
+				DeleteFunc(primary, func(k, _ uint32) bool { return k%7 == 0 })
+			case 2:
+				primary.Entry(k).AndModify(func(old uint32) uint32 { return old + v }).OrInsert(v)
+			default:
+				primary.Put(k, v)
+			}
+		}
+		if i%10 == 9 {
+			primary.Clear()
+			assert.Equal(t, 1, log.Len())
+		}
+		log.Replay(replica)
+		log.Reset()
+		require.True(t, Equal(primary, replica))
+	}
+
+	// a Map copied into an empty Map is observed as Puts
+	dst := NewMap[uint32, uint32](0)
+	cancel = dst.Observe(log.Record)
+	defer cancel()
+	Copy(dst, replica)
+	assert.Equal(t, replica.Count(), log.Len())
+	n := 0
+	log.Iter(func(e Event[uint32, uint32]) (stop bool) {
+		assert.Equal(t, OpPut, e.Op)
+		assert.Equal(t, must(replica.Get(e.Key)), e.Value)
+		n++
+		return
+	})
+	assert.Equal(t, replica.Count(), n)
+}
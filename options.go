@@ -0,0 +1,131 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import "math"
+
+// Option configures a Map constructed by NewMapWith.
+type Option func(o *options)
+
+type options struct {
+	capacity     uint64
+	maxLoad      float32
+	growth       float32
+	compactRatio float32
+	seed         uint64
+	hasSeed      bool
+	keepSeed     bool
+}
+
+// NewMapWith constructs a Map configured by |opts|. Options other than
+// WithSeed only affect how the Map is sized and grown, never how it is
+// probed, so a Map built without WithSeed performs exactly as one built
+// by NewMap.
+func NewMapWith[K comparable, V any](opts ...Option) (m *Map[K, V]) {
+	o := options{compactRatio: defaultCompactRatio}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	m = &Map[K, V]{
+		hash:     newHasher[K](),
+		ptrs:     hasPointers[K]() || hasPointers[V](),
+		keepSeed: o.keepSeed,
+
+		compactRatio: o.compactRatio,
+		maxLoad:      o.maxLoad,
+		growth:       o.growth,
+	}
+	if o.hasSeed {
+		h, err := NewStableHasher[K](o.seed)
+		if err != nil {
+			panic(err)
+		}
+		// a StableHasher keeps its seed, see SetKeepSeed
+		m.hash, m.keepSeed = h.hasher(), true
+	}
+	groups := m.groupsFor(o.capacity)
+	m.ctrl = make([]metadata, groups)
+	m.groups = make([]group[K, V], groups)
+	for i := range m.ctrl {
+		m.ctrl[i] = newEmptyMetadata()
+	}
+	m.limit = m.limitFor(groups)
+	return
+}
+
+// WithCapacity sizes the Map to hold |n| elements without rehashing.
+// It panics if |n| is negative, and NewMapWith panics if a table for
+// |n| elements would have more groups than a uint32 can index.
+func WithCapacity(n int) Option {
+	sz := tableSize64(n)
+	return func(o *options) {
+		o.capacity = sz
+	}
+}
+
+// WithMaxLoadFactor sets the fraction of slots the Map fills before it
+// grows. |f| must be in (0, 1), as a probe must always find an empty slot.
+// The default is 7/8. Lower factors shorten probes at the cost of memory.
+func WithMaxLoadFactor(f float32) Option {
+	if !(f > 0 && f < 1) {
+		panic("swiss: max load factor out of range")
+	}
+	return func(o *options) {
+		o.maxLoad = f
+	}
+}
+
+// WithGrowthFactor sets the factor by which a full Map grows. |f| must be
+// greater than 1. The default is 2. Lower factors waste less memory, at the
+// cost of rehashing more often.
+func WithGrowthFactor(f float32) Option {
+	if !(f > 1) || math.IsInf(float64(f), 1) {
+		panic("swiss: growth factor out of range")
+	}
+	return func(o *options) {
+		o.growth = f
+	}
+}
+
+// WithCompactRatio sets the ratio of tombstones to resident
+// slots at which a full Map is compacted, see SetCompactRatio.
+func WithCompactRatio(ratio float32) Option {
+	if !(ratio > 0 && ratio <= 1) {
+		panic("swiss: compact ratio out of range")
+	}
+	return func(o *options) {
+		o.compactRatio = ratio
+	}
+}
+
+// WithSeed makes the Map hash keys as a StableHasher of |seed| does, so
+// that its table layout is reproducible given the same sequence of writes,
+// including rehashes, which keep the seed whatever WithReseed is given.
+// Iteration order is not, as it starts at a random group. K must be a type
+// a StableHasher can hash, or NewMapWith panics, and keys are hashed more
+// slowly than by the hasher of NewMap.
+func WithSeed(seed uint64) Option {
+	return func(o *options) {
+		o.seed, o.hasSeed = seed, true
+	}
+}
+
+// WithReseed sets whether rehashing renews the hash seed of the Map,
+// see SetKeepSeed. The default is true. It has no effect with WithSeed.
+func WithReseed(enabled bool) Option {
+	return func(o *options) {
+		o.keepSeed = !enabled
+	}
+}
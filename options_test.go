@@ -0,0 +1,131 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMapWith(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		for _, sz := range []int{0, 1, 100, 1000} {
+			m := NewMapWith[int, int](WithCapacity(sz))
+			exp := NewMap[int, int](uint32(sz))
+			assert.Equal(t, len(exp.groups), len(m.groups))
+			assert.Equal(t, exp.limit, m.limit)
+			assert.Equal(t, exp.compactRatio, m.compactRatio)
+			for i := 0; i < 10_000; i++ {
+				m.Put(i, i)
+				exp.Put(i, i)
+			}
+			assert.Equal(t, len(exp.groups), len(m.groups))
+		}
+	})
+	t.Run("max load factor", func(t *testing.T) {
+		for _, f := range []float32{0.01, 0.25, 0.5, 0.99} {
+			m := NewMapWith[int, int](WithMaxLoadFactor(f), WithCapacity(100))
+			assert.GreaterOrEqual(t, m.Capacity(), 100)
+			for i := 0; i < 10_000; i++ {
+				m.Put(i, i)
+				require.LessOrEqual(t, m.loadFactor(), f)
+			}
+			for i := 0; i < 10_000; i++ {
+				v, ok := m.Get(i)
+				require.True(t, ok)
+				require.Equal(t, i, v)
+			}
+			m.Shrink()
+			assert.LessOrEqual(t, m.loadFactor(), f)
+		}
+	})
+	t.Run("growth factor", func(t *testing.T) {
+		m := NewMapWith[int, int](WithGrowthFactor(1.5), WithCapacity(1000))
+		n := len(m.groups)
+		for i := 0; len(m.groups) == n; i++ {
+			m.Put(i, i)
+		}
+		assert.Equal(t, n*3/2, len(m.groups))
+
+		m = NewMapWith[int, int](WithGrowthFactor(1.001))
+		for i := 0; i < 1000; i++ {
+			m.Put(i, i)
+		}
+		assert.Equal(t, 1000, m.Count())
+	})
+	t.Run("compact ratio", func(t *testing.T) {
+		m := NewMapWith[int, int](WithCompactRatio(0.25))
+		assert.Equal(t, float32(0.25), m.compactRatio)
+	})
+	t.Run("seed", func(t *testing.T) {
+		a := NewMapWith[int, int](WithSeed(42), WithReseed(false))
+		b := NewMapWith[int, int](WithSeed(42), WithReseed(false))
+		for i := 0; i < 1000; i++ {
+			a.Put(i, i)
+			b.Put(i, i)
+		}
+		assert.Equal(t, a.ctrl, b.ctrl)
+		assert.Equal(t, a.groups, b.groups)
+		assert.Equal(t, a.hash.Hash(7), b.hash.Hash(7))
+		assert.True(t, a.keepSeed)
+
+		h, err := NewStableHasher[int](42)
+		require.NoError(t, err)
+		assert.Equal(t, h.Hash(7), a.hash.Hash(7))
+
+		// the seed survives rehashing, whatever is asked
+		c := NewMapWith[int, int](WithReseed(true), WithSeed(42))
+		d := NewMapWith[int, int](WithSeed(42), WithReseed(true))
+		d.SetKeepSeed(false)
+		for _, m := range []*Map[int, int]{c, d} {
+			assert.True(t, m.keepSeed)
+			m.rehash(2)
+			assert.Equal(t, a.hash.Hash(7), m.hash.Hash(7))
+		}
+		c, d = NewMapWith[int, int](WithSeed(42)), NewMapWith[int, int](WithSeed(42))
+		groups := len(c.groups)
+		for i := 0; i < 1000; i++ {
+			c.Put(i, i)
+			d.Put(i, i)
+		}
+		assert.Greater(t, len(c.groups), groups)
+		assert.Equal(t, tableOrder(c), tableOrder(d))
+		assert.Equal(t, tableOrder(a), tableOrder(c))
+
+		assert.Panics(t, func() { NewMapWith[*int, int](WithSeed(1)) })
+	})
+	t.Run("invalid", func(t *testing.T) {
+		assert.Panics(t, func() { WithCapacity(-1) })
+		assert.Panics(t, func() { WithMaxLoadFactor(0) })
+		assert.Panics(t, func() { WithMaxLoadFactor(1) })
+		assert.Panics(t, func() { WithGrowthFactor(1) })
+		assert.Panics(t, func() { WithCompactRatio(0) })
+	})
+}
+
+// tableOrder returns the keys of |m| in the order of its table,
+// which is the order of iteration from the first group.
+func tableOrder[K comparable, V any](m *Map[K, V]) (keys []K) {
+	for g := range m.ctrl {
+		for s, c := range m.ctrl[g] {
+			if c != empty && c != tombstone {
+				keys = append(keys, m.groups[g].keys[s])
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,127 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+// GetPtr returns a pointer to the value mapped by |key|, or nil if |key|
+// is absent. The value may be modified in place through the pointer.
+//
+// The pointer refers to the Map's internal storage and is only valid until
+// the Map is next modified: an insert may rehash the Map and relocate every
+// value, and a deleted slot may be reused by another key. Writes through a
+// stale pointer are silently lost or corrupt another entry. GetRef is a
+// checked alternative.
+func (m *Map[K, V]) GetPtr(key K) *V {
+	hi, lo := splitHash(m.hash.Hash(key))
+	if m.old != nil {
+		m.migrate(key, hi, lo)
+	}
+	g, s, ok := m.find(key, hi, lo)
+	if !ok {
+		return nil
+	}
+	if m.ext != nil {
+		m.beforeWriteThrough(g)
+	}
+	return &m.groups[g].values[s]
+}
+
+// PutPtr returns a pointer to the value mapped by |key|, inserting the zero
+// value first if |key| is absent. The pointer is subject to the same
+// restrictions as those returned by GetPtr.
+func (m *Map[K, V]) PutPtr(key K) *V {
+	hi, lo := splitHash(m.hash.Hash(key))
+	if m.old != nil {
+		m.migrate(key, hi, lo)
+	}
+	g, s, ok := m.find(key, hi, lo)
+	if !ok {
+		var zero V
+		g, s = m.insert(key, zero, g, s, hi, lo)
+	}
+	if m.ext != nil {
+		m.beforeWriteThrough(g)
+	}
+	return &m.groups[g].values[s]
+}
+
+// Ref is a checked reference to a value stored in a Map. Unlike the raw
+// pointers returned by GetPtr and PutPtr, a Ref detects when the Map has
+// been rehashed or cleared, or its key deleted, since the Ref was taken
+// and panics instead of handing out a stale pointer. The check costs a
+// comparison of the key on every access, so Ref is intended for debugging
+// and for code paths where safety matters more than speed.
+type Ref[K comparable, V any] struct {
+	m     *Map[K, V]
+	key   K
+	g, s  uint32
+	epoch uint32
+}
+
+// GetRef returns a Ref to the value mapped by |key|, if one exists.
+func (m *Map[K, V]) GetRef(key K) (r Ref[K, V], ok bool) {
+	hi, lo := splitHash(m.hash.Hash(key))
+	if m.old != nil {
+		m.migrate(key, hi, lo)
+	}
+	g, s, ok := m.find(key, hi, lo)
+	if !ok {
+		return r, false
+	}
+	return Ref[K, V]{m: m, key: key, g: g, s: s, epoch: m.epoch}, true
+}
+
+// PutRef returns a Ref to the value mapped by |key|,
+// inserting the zero value first if |key| is absent.
+func (m *Map[K, V]) PutRef(key K) Ref[K, V] {
+	hi, lo := splitHash(m.hash.Hash(key))
+	if m.old != nil {
+		m.migrate(key, hi, lo)
+	}
+	g, s, ok := m.find(key, hi, lo)
+	if !ok {
+		var zero V
+		g, s = m.insert(key, zero, g, s, hi, lo)
+	}
+	return Ref[K, V]{m: m, key: key, g: g, s: s, epoch: m.epoch}
+}
+
+// Key returns the key of |r|.
+func (r Ref[K, V]) Key() K {
+	return r.key
+}
+
+// Valid returns true if |r| still refers to the value mapped by its key.
+func (r Ref[K, V]) Valid() bool {
+	if r.m == nil || r.epoch != r.m.epoch {
+		return false
+	}
+	c := r.m.ctrl[r.g][r.s]
+	if c == empty || c == tombstone {
+		return false
+	}
+	return r.m.groups[r.g].keys[r.s] == r.key
+}
+
+// Ptr returns a pointer to the value referred to by |r|.
+// It panics if |r| is no longer valid.
+func (r Ref[K, V]) Ptr() *V {
+	if !r.Valid() {
+		panic("swiss: use of stale Ref")
+	}
+	if r.m.ext != nil {
+		r.m.beforeWriteThrough(r.g)
+	}
+	return &r.m.groups[r.g].values[r.s]
+}
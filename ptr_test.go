@@ -0,0 +1,93 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type aggregate struct {
+	count uint64
+	sum   [24]uint64
+}
+
+func TestMapPtr(t *testing.T) {
+	keys := genUint32Data(10_000)
+	m := NewMap[uint32, aggregate](0)
+	for _, k := range keys {
+		assert.Nil(t, m.GetPtr(k))
+		p := m.PutPtr(k)
+		assert.Equal(t, aggregate{}, *p)
+		p.count = 1
+		p.sum[0] = uint64(k)
+	}
+	for _, k := range keys {
+		p := m.GetPtr(k)
+		p.count++
+		p.sum[1] = uint64(k)
+		p = m.PutPtr(k)
+		p.count++
+	}
+	assert.Equal(t, len(keys), m.Count())
+	for _, k := range keys {
+		v, ok := m.Get(k)
+		assert.True(t, ok)
+		assert.Equal(t, uint64(3), v.count)
+		assert.Equal(t, uint64(k), v.sum[0])
+		assert.Equal(t, uint64(k), v.sum[1])
+	}
+}
+
+func TestMapRef(t *testing.T) {
+	m := NewMap[string, int](0)
+	_, ok := m.GetRef("a")
+	assert.False(t, ok)
+	r := m.PutRef("a")
+	assert.True(t, r.Valid())
+	assert.Equal(t, "a", r.Key())
+	*r.Ptr() = 42
+	r, ok = m.GetRef("a")
+	assert.True(t, ok)
+	assert.Equal(t, 42, *r.Ptr())
+
+	t.Run("stale after rehash", func(t *testing.T) {
+		r := m.PutRef("a")
+		for _, k := range genStringData(8, 100) {
+			m.Put(k, 0)
+		}
+		assert.False(t, r.Valid())
+		assert.Panics(t, func() { r.Ptr() })
+		assert.Equal(t, 42, *m.PutRef("a").Ptr())
+	})
+	t.Run("stale after delete", func(t *testing.T) {
+		r := m.PutRef("a")
+		m.Delete("a")
+		assert.False(t, r.Valid())
+		assert.Panics(t, func() { r.Ptr() })
+	})
+	t.Run("stale after clear", func(t *testing.T) {
+		r := m.PutRef("b")
+		m.Clear()
+		m.Put("b", 1)
+		assert.False(t, r.Valid())
+		assert.Panics(t, func() { r.Ptr() })
+	})
+	t.Run("zero ref", func(t *testing.T) {
+		var r Ref[string, int]
+		assert.False(t, r.Valid())
+	})
+}
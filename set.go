@@ -0,0 +1,373 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+// Set is an open-addressing hash set. It shares the
+// table layout of Map, but stores no values.
+type Set[K comparable] struct {
+	ctrl     []metadata
+	groups   []setGroup[K]
+	hash     hasher[K]
+	resident uint64
+	dead     uint64
+	limit    uint64
+	// ptrs is true if K contains pointers,
+	// which removed slots must release
+	ptrs bool
+}
+
+// setGroup is a group of 16 keys
+type setGroup[K comparable] struct {
+	keys [groupSize]K
+}
+
+// NewSet constructs a Set.
+func NewSet[K comparable](sz uint32) *Set[K] {
+	return newSet[K](uint64(sz), newHasher[K]())
+}
+
+// newSet constructs a Set with room for |n| keys hashed by |hash|.
+func newSet[K comparable](n uint64, hash hasher[K]) (s *Set[K]) {
+	groups := defaultGroups(n)
+	s = &Set[K]{
+		ctrl:   make([]metadata, groups),
+		groups: make([]setGroup[K], groups),
+		hash:   hash,
+		limit:  defaultLimit(groups),
+		ptrs:   hasPointers[K](),
+	}
+	for i := range s.ctrl {
+		s.ctrl[i] = newEmptyMetadata()
+	}
+	return
+}
+
+// Contains returns true if |key| is present in |s|.
+func (s *Set[K]) Contains(key K) (ok bool) {
+	hi, lo := splitHash(s.hash.Hash(key))
+	_, _, ok = s.find(key, hi, lo)
+	return
+}
+
+// Add inserts |key|, returning true if it was absent.
+func (s *Set[K]) Add(key K) (ok bool) {
+	hi, lo := splitHash(s.hash.Hash(key))
+	g, i, found := s.find(key, hi, lo)
+	if found {
+		return false
+	}
+	s.insert(key, g, i, lo)
+	return true
+}
+
+// Remove removes |key|, returning true if it was present.
+func (s *Set[K]) Remove(key K) (ok bool) {
+	hi, lo := splitHash(s.hash.Hash(key))
+	g, i, ok := s.find(key, hi, lo)
+	if ok {
+		s.deleteAt(g, i)
+	}
+	return
+}
+
+// Len returns the number of keys in the Set.
+func (s *Set[K]) Len() int {
+	return int(s.resident - s.dead)
+}
+
+// Iter iterates the keys of the Set, passing them to the callback.
+// It provides the same guarantees as Map.Iter.
+func (s *Set[K]) Iter(cb func(k K) (stop bool)) {
+	// take a consistent view of the table in case
+	// we rehash during iteration
+	ctrl, groups := s.ctrl, s.groups
+	// pick a random starting group
+	g := randIntN(len(groups))
+	for n := 0; n < len(groups); n++ {
+		for i, c := range ctrl[g] {
+			if c == empty || c == tombstone {
+				continue
+			}
+			if stop := cb(groups[g].keys[i]); stop {
+				return
+			}
+		}
+		g++
+		if g >= uint32(len(groups)) {
+			g = 0
+		}
+	}
+}
+
+// Clear removes all keys from the Set.
+func (s *Set[K]) Clear() {
+	for i, c := range s.ctrl {
+		for j := range c {
+			s.ctrl[i][j] = empty
+		}
+	}
+	if s.ptrs {
+		for i := range s.groups {
+			s.groups[i] = setGroup[K]{}
+		}
+	}
+	s.resident, s.dead = 0, 0
+}
+
+// Clone returns a copy of |s|. The table is copied wholesale,
+// so no key is rehashed.
+func (s *Set[K]) Clone() *Set[K] {
+	c := *s
+	c.ctrl = make([]metadata, len(s.ctrl))
+	copy(c.ctrl, s.ctrl)
+	c.groups = make([]setGroup[K], len(s.groups))
+	copy(c.groups, s.groups)
+	return &c
+}
+
+// Set operations hash each key of their operands at most once:
+// keys of one operand are hashed with the hasher of the other in
+// order to probe it, and the result adopts that hasher so the same
+// hash locates the key in the result.
+
+// Union returns a new Set containing the keys present in |s| or |o|.
+func (s *Set[K]) Union(o *Set[K]) *Set[K] {
+	small, large := s, o
+	if small.Len() > large.Len() {
+		small, large = large, small
+	}
+	u := large.cloneFor(small.Len())
+	small.each(func(key K) {
+		hi, lo := splitHash(u.hash.Hash(key))
+		if g, i, ok := u.find(key, hi, lo); !ok {
+			u.insert(key, g, i, lo)
+		}
+	})
+	return u
+}
+
+// Intersect returns a new Set containing the keys present in both |s| and |o|.
+// It iterates the smaller Set and probes the larger one.
+func (s *Set[K]) Intersect(o *Set[K]) *Set[K] {
+	small, large := s, o
+	if small.Len() > large.Len() {
+		small, large = large, small
+	}
+	x := newSet[K](uint64(small.Len()), large.hash)
+	small.each(func(key K) {
+		hi, lo := splitHash(large.hash.Hash(key))
+		if _, _, ok := large.find(key, hi, lo); ok {
+			x.insertUnique(key, hi, lo)
+		}
+	})
+	return x
+}
+
+// Difference returns a new Set containing the keys present in |s| but not in |o|.
+func (s *Set[K]) Difference(o *Set[K]) *Set[K] {
+	if o.Len() < s.Len() {
+		// cheaper to remove the keys of |o| from a copy of |s|
+		d := s.Clone()
+		o.each(func(key K) {
+			d.Remove(key)
+		})
+		return d
+	}
+	d := newSet[K](uint64(s.Len()), o.hash)
+	s.each(func(key K) {
+		hi, lo := splitHash(o.hash.Hash(key))
+		if _, _, ok := o.find(key, hi, lo); !ok {
+			d.insertUnique(key, hi, lo)
+		}
+	})
+	return d
+}
+
+// SymmetricDifference returns a new Set containing the keys
+// present in exactly one of |s| and |o|.
+func (s *Set[K]) SymmetricDifference(o *Set[K]) *Set[K] {
+	small, large := s, o
+	if small.Len() > large.Len() {
+		small, large = large, small
+	}
+	d := large.cloneFor(small.Len())
+	small.each(func(key K) {
+		hi, lo := splitHash(d.hash.Hash(key))
+		if g, i, ok := d.find(key, hi, lo); ok {
+			d.deleteAt(g, i)
+		} else {
+			d.insert(key, g, i, lo)
+		}
+	})
+	return d
+}
+
+// IsSubset returns true if every key of |s| is present in |o|.
+func (s *Set[K]) IsSubset(o *Set[K]) (ok bool) {
+	if s.Len() > o.Len() {
+		return false
+	}
+	ok = true
+	s.Iter(func(key K) (stop bool) {
+		hi, lo := splitHash(o.hash.Hash(key))
+		_, _, ok = o.find(key, hi, lo)
+		return !ok
+	})
+	return
+}
+
+// cloneFor returns a copy of |s| with room for |n| more keys, so that
+// inserting them does not rehash. The copy keeps the hasher of |s|: if
+// the table of |s| is large enough it is copied wholesale, otherwise
+// each key of |s| is hashed once into a table sized for both.
+func (s *Set[K]) cloneFor(n int) *Set[K] {
+	if uint64(n) <= s.limit-s.resident {
+		return s.Clone()
+	}
+	c := newSet[K](uint64(s.Len())+uint64(n), s.hash)
+	s.each(func(key K) {
+		hi, lo := splitHash(c.hash.Hash(key))
+		c.insertUnique(key, hi, lo)
+	})
+	return c
+}
+
+// each calls |cb| for every key of |s| in table order.
+// |cb| must not modify |s|.
+func (s *Set[K]) each(cb func(key K)) {
+	for g := range s.ctrl {
+		for i, c := range s.ctrl[g] {
+			if c == empty || c == tombstone {
+				continue
+			}
+			cb(s.groups[g].keys[i])
+		}
+	}
+}
+
+// find returns the location of |key| if present, or its insertion location if absent.
+func (s *Set[K]) find(key K, hi h1, lo h2) (g, i uint32, ok bool) {
+	g = probeStart(hi, len(s.groups))
+	for {
+		matches := metaMatchH2(&s.ctrl[g], lo)
+		for matches != 0 {
+			i = nextMatch(&matches)
+			if key == s.groups[g].keys[i] {
+				return g, i, true
+			}
+		}
+		// |key| is not in group |g|,
+		// stop probing if we see an empty slot
+		matches = metaMatchEmpty(&s.ctrl[g])
+		if matches != 0 {
+			i = nextMatch(&matches)
+			return g, i, false
+		}
+		g += 1 // linear probing
+		if g >= uint32(len(s.groups)) {
+			g = 0
+		}
+	}
+}
+
+// insert stores |key| at the insertion location |g|, |i| returned
+// by find, rehashing first if the Set is full.
+func (s *Set[K]) insert(key K, g, i uint32, lo h2) {
+	if s.resident >= s.limit {
+		s.rehash(s.nextSize())
+		// rehashing reseeds |s.hash|
+		var hi h1
+		hi, lo = splitHash(s.hash.Hash(key))
+		g, i, _ = s.find(key, hi, lo)
+	}
+	s.groups[g].keys[i] = key
+	s.ctrl[g][i] = int8(lo)
+	s.resident++
+}
+
+// insertUnique stores |key|, which must be absent from |s|, in the first
+// empty slot of its probe sequence. The Set must have room for |key|.
+func (s *Set[K]) insertUnique(key K, hi h1, lo h2) {
+	g := probeStart(hi, len(s.groups))
+	for {
+		matches := metaMatchEmpty(&s.ctrl[g])
+		if matches != 0 {
+			i := nextMatch(&matches)
+			s.groups[g].keys[i] = key
+			s.ctrl[g][i] = int8(lo)
+			s.resident++
+			return
+		}
+		g += 1 // linear probing
+		if g >= uint32(len(s.groups)) {
+			g = 0
+		}
+	}
+}
+
+// deleteAt removes the key stored in slot |i| of group |g|.
+func (s *Set[K]) deleteAt(g, i uint32) {
+	// see Map.deleteAt
+	if metaMatchEmpty(&s.ctrl[g]) != 0 {
+		s.ctrl[g][i] = empty
+		s.resident--
+	} else {
+		s.ctrl[g][i] = tombstone
+		s.dead++
+	}
+	if s.ptrs {
+		var k K
+		s.groups[g].keys[i] = k
+	}
+}
+
+func (s *Set[K]) nextSize() uint32 {
+	n := uint64(len(s.groups))
+	if s.dead >= (s.resident / 2) {
+		return uint32(n)
+	}
+	if n == maxGroups {
+		panic("swiss: table size out of range")
+	}
+	if n *= 2; n > maxGroups {
+		n = maxGroups
+	}
+	return uint32(n)
+}
+
+func (s *Set[K]) rehash(n uint32) {
+	groups, ctrl := s.groups, s.ctrl
+	s.groups = make([]setGroup[K], n)
+	s.ctrl = make([]metadata, n)
+	for i := range s.ctrl {
+		s.ctrl[i] = newEmptyMetadata()
+	}
+	if s.hash.stable == nil {
+		// a StableHasher is kept
+		s.hash = s.hash.reseeded()
+	}
+	s.limit = defaultLimit(n)
+	s.resident, s.dead = 0, 0
+	for g := range ctrl {
+		for i := range ctrl[g] {
+			c := ctrl[g][i]
+			if c == empty || c == tombstone {
+				continue
+			}
+			hi, lo := splitHash(s.hash.Hash(groups[g].keys[i]))
+			s.insertUnique(groups[g].keys[i], hi, lo)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSet(t *testing.T) {
+	keys := genStringData(8, 1000)
+	s := NewSet[string](0)
+	for _, k := range keys {
+		assert.True(t, s.Add(k))
+		assert.False(t, s.Add(k))
+	}
+	assert.Equal(t, len(keys), s.Len())
+	for _, k := range keys {
+		assert.True(t, s.Contains(k))
+	}
+	for i, k := range keys {
+		if i%2 == 0 {
+			assert.True(t, s.Remove(k))
+			assert.False(t, s.Remove(k))
+		}
+	}
+	assert.Equal(t, len(keys)/2, s.Len())
+	seen := make(map[string]bool)
+	s.Iter(func(k string) (stop bool) {
+		assert.False(t, seen[k])
+		seen[k] = true
+		return
+	})
+	assert.Equal(t, s.Len(), len(seen))
+	for i, k := range keys {
+		assert.Equal(t, i%2 == 1, s.Contains(k))
+		assert.Equal(t, i%2 == 1, seen[k])
+	}
+	c := s.Clone()
+	s.Clear()
+	assert.Equal(t, 0, s.Len())
+	assert.Equal(t, len(keys)/2, c.Len())
+	assert.False(t, s.Contains(keys[1]))
+	assert.True(t, c.Contains(keys[1]))
+}
+
+func TestSetAlgebra(t *testing.T) {
+	keys := genUint32Data(3000)
+	// |a| holds keys [0, 2000), |b| holds keys [1000, 3000)
+	// and |c| holds every fourth key of |a|
+	a, b, c := NewSet[uint32](0), NewSet[uint32](0), NewSet[uint32](0)
+	for i, k := range keys {
+		if i < 2000 {
+			a.Add(k)
+		}
+		if i >= 1000 {
+			b.Add(k)
+		}
+		if i < 2000 && i%4 == 0 {
+			c.Add(k)
+		}
+	}
+	// |d| shares the hasher of |a|
+	d := a.Intersect(c)
+
+	tests := []struct {
+		name string
+		set  *Set[uint32]
+		exp  func(i int) bool
+	}{
+		{"union", a.Union(b), func(i int) bool {
+			return true
+		}},
+		{"intersect", a.Intersect(b), func(i int) bool {
+			return i >= 1000 && i < 2000
+		}},
+		{"intersect shared seed", d, func(i int) bool {
+			return i < 2000 && i%4 == 0
+		}},
+		{"difference", a.Difference(b), func(i int) bool {
+			return i < 1000
+		}},
+		{"difference larger", c.Difference(b), func(i int) bool {
+			return i < 1000 && i%4 == 0
+		}},
+		{"difference smaller", a.Difference(c), func(i int) bool {
+			return i < 2000 && i%4 != 0
+		}},
+		{"symmetric difference", a.SymmetricDifference(b), func(i int) bool {
+			return i < 1000 || i >= 2000
+		}},
+		{"union shared seed", d.Union(a), func(i int) bool {
+			return i < 2000
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n := 0
+			for i, k := range keys {
+				exp := test.exp(i)
+				assert.Equal(t, exp, test.set.Contains(k))
+				if exp {
+					n++
+				}
+			}
+			assert.Equal(t, n, test.set.Len())
+		})
+	}
+	t.Run("operands unchanged", func(t *testing.T) {
+		assert.Equal(t, 2000, a.Len())
+		assert.Equal(t, 2000, b.Len())
+		assert.Equal(t, 500, c.Len())
+	})
+	t.Run("subset", func(t *testing.T) {
+		assert.True(t, c.IsSubset(a))
+		assert.True(t, d.IsSubset(c))
+		assert.True(t, c.IsSubset(d))
+		assert.False(t, a.IsSubset(c))
+		assert.False(t, c.IsSubset(b))
+		assert.True(t, NewSet[uint32](0).IsSubset(c))
+	})
+	t.Run("hash once", func(t *testing.T) {
+		// count the keys hashed with the hasher of |x|
+		x, y := NewSet[uint32](0), NewSet[uint32](0)
+		n := 0
+		h, err := NewStableHasher[uint32](1)
+		assert.NoError(t, err)
+		x.hash = hasher[uint32]{seed: 1, stable: func(seed uint64, key unsafe.Pointer) uint64 {
+			n++
+			return h.hash(seed, key)
+		}}.bind()
+		// fill |x| so that it must grow to hold the keys of both
+		i := 0
+		for ; i < 1000 || x.resident < x.limit; i++ {
+			x.Add(keys[i])
+		}
+		for _, k := range keys[i : i+100] {
+			y.Add(k)
+		}
+		for _, op := range []func(a, b *Set[uint32]) *Set[uint32]{
+			(*Set[uint32]).Union,
+			(*Set[uint32]).SymmetricDifference,
+		} {
+			n = 0
+			u := op(x, y)
+			assert.Equal(t, x.Len()+y.Len(), u.Len())
+			assert.Equal(t, x.Len()+y.Len(), n)
+			assert.Equal(t, uint64(1), u.hash.seed)
+		}
+	})
+}
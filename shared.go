@@ -0,0 +1,502 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+// The file of a SharedMap is laid out like that of a Frozen map: a header
+// of mappedHeaderSize bytes, the control bytes of every group and then, at
+// the next multiple of mappedAlign, the groups. Its header is sharedHeader,
+// in native byte order.
+
+const sharedVersion = 1
+
+var sharedMagic = [4]byte{'S', 'W', 'S', 'H'}
+
+// sharedHeader is the header of a SharedMap file. The fields up to crc
+// are fixed when the file is created, the following ones are shared state.
+type sharedHeader struct {
+	magic     [4]byte
+	version   uint16
+	width     uint8
+	flags     uint8
+	keySize   uint32
+	valueSize uint32
+	groupSize uint32
+	groups    uint32
+	seed      uint64
+	// crc is the CRC-32C of the preceding fields
+	crc uint32
+
+	// rw is a readers-writer spin lock, holding the number
+	// of readers and the sharedWriter and sharedWaiting bits
+	rw       uint32
+	resident uint32
+	dead     uint32
+	// moved is set once the table has been grown
+	// into a new file, which replaced it at its path
+	moved uint32
+	_     [12]byte
+}
+
+const (
+	sharedWriter  uint32 = 1 << 31
+	sharedWaiting uint32 = 1 << 30
+)
+
+// SharedMap is a hash map of fixed capacity whose table is a memory-mapped
+// file, which several processes on a host can open and update at once.
+// Its operations take a readers-writer spin lock in the file. If a process
+// dies while holding it, the file remains locked.
+//
+// K and V must not contain pointers, and keys are hashed by a StableHasher
+// with a seed stored in the file. When the table is full, Put grows it into
+// a new file that replaces the old one, and other processes switch to it
+// when they next take its lock. A SharedMap is not safe for concurrent use
+// by several goroutines, which should each open their own.
+type SharedMap[K comparable, V any] struct {
+	path   string
+	data   []byte
+	hdr    *sharedHeader
+	ctrl   []metadata
+	groups []group[K, V]
+	hash   StableHasher[K]
+	limit  uint32
+}
+
+// OpenSharedMap opens the SharedMap at |path|, creating it with room for
+// |sz| elements if it doesn't exist.
+func OpenSharedMap[K comparable, V any](path string, sz uint32) (*SharedMap[K, V], error) {
+	if hasPointers[K]() || hasPointers[V]() {
+		var (
+			k K
+			v V
+		)
+		return nil, fmt.Errorf("swiss: SharedMap of %T keys and %T values, which contain pointers", k, v)
+	}
+	for {
+		m, err := openSharedMap[K, V](path)
+		if !errors.Is(err, os.ErrNotExist) {
+			return m, err
+		}
+		m, tmp, err := createSharedMap[K, V](path, numGroups(sz), uint64(fastrand())<<32|uint64(fastrand()))
+		if err != nil {
+			return nil, err
+		}
+		// link rather than rename, to not replace
+		// a SharedMap created by another process
+		err = os.Link(tmp, path)
+		_ = os.Remove(tmp)
+		if err == nil {
+			m.path = path
+			return m, nil
+		}
+		_ = m.Close()
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+	}
+}
+
+// Has returns true if |key| is present in |m|.
+func (m *SharedMap[K, V]) Has(key K) (ok bool) {
+	_, ok = m.Get(key)
+	return
+}
+
+// Get returns the |value| mapped by |key| if one exists.
+func (m *SharedMap[K, V]) Get(key K) (value V, ok bool) {
+	m.rlock()
+	defer m.runlock()
+	hi, lo := splitHash(m.hash.Hash(key))
+	g, s, ok := m.find(key, hi, lo)
+	if ok {
+		value = m.groups[g].values[s]
+	}
+	return
+}
+
+// Put attempts to insert |key| and |value|. It returns an error if the
+// table is full and could not be grown.
+func (m *SharedMap[K, V]) Put(key K, value V) error {
+	if err := m.lock(); err != nil {
+		return err
+	}
+	defer m.unlock()
+	// growing keeps the seed, so |key| is hashed once
+	hi, lo := splitHash(m.hash.Hash(key))
+	g, s, ok := m.find(key, hi, lo)
+	if ok {
+		m.groups[g].values[s] = value
+		return nil
+	}
+	if m.hdr.resident >= m.limit {
+		n := uint32(len(m.groups)) * 2
+		if m.hdr.dead >= m.hdr.resident/2 {
+			n = uint32(len(m.groups))
+		}
+		if err := m.grow(n); err != nil {
+			return err
+		}
+		g, s, _ = m.find(key, hi, lo)
+	}
+	m.insert(key, value, g, s, lo)
+	return nil
+}
+
+// Delete attempts to remove |key|, returns true successful.
+func (m *SharedMap[K, V]) Delete(key K) (ok bool, err error) {
+	if err = m.lock(); err != nil {
+		return false, err
+	}
+	defer m.unlock()
+	hi, lo := splitHash(m.hash.Hash(key))
+	g, s, ok := m.find(key, hi, lo)
+	if !ok {
+		return false, nil
+	}
+	// see Map.deleteAt
+	if metaMatchEmpty(&m.ctrl[g]) != 0 {
+		m.ctrl[g][s] = empty
+		m.hdr.resident--
+	} else {
+		m.ctrl[g][s] = tombstone
+		m.hdr.dead++
+	}
+	return true, nil
+}
+
+// Iter iterates the elements of the SharedMap, passing them to the
+// callback. The SharedMap is locked for reading during iteration, and
+// must not be used by the callback.
+func (m *SharedMap[K, V]) Iter(cb func(k K, v V) (stop bool)) {
+	m.rlock()
+	defer m.runlock()
+	for g := range m.ctrl {
+		for s, c := range m.ctrl[g] {
+			if c == empty || c == tombstone {
+				continue
+			}
+			if stop := cb(m.groups[g].keys[s], m.groups[g].values[s]); stop {
+				return
+			}
+		}
+	}
+}
+
+// Count returns the number of elements in the SharedMap.
+func (m *SharedMap[K, V]) Count() int {
+	m.rlock()
+	defer m.runlock()
+	return int(m.hdr.resident - m.hdr.dead)
+}
+
+// Capacity returns the number of additional elements
+// the SharedMap can hold before it is grown.
+func (m *SharedMap[K, V]) Capacity() int {
+	m.rlock()
+	defer m.runlock()
+	return int(m.limit - m.hdr.resident)
+}
+
+// Close unmaps the SharedMap, which must not be used after it is closed.
+func (m *SharedMap[K, V]) Close() (err error) {
+	if m.data != nil {
+		err = syscall.Munmap(m.data)
+	}
+	*m = SharedMap[K, V]{}
+	return
+}
+
+// find returns the location of |key| if present, or its insertion location if absent.
+func (m *SharedMap[K, V]) find(key K, hi h1, lo h2) (g, s uint32, ok bool) {
+	g = probeStart32(hi, len(m.groups))
+	for {
+		matches := metaMatchH2(&m.ctrl[g], lo)
+		for matches != 0 {
+			s = nextMatch(&matches)
+			if key == m.groups[g].keys[s] {
+				return g, s, true
+			}
+		}
+		// |key| is not in group |g|,
+		// stop probing if we see an empty slot
+		matches = metaMatchEmpty(&m.ctrl[g])
+		if matches != 0 {
+			s = nextMatch(&matches)
+			return g, s, false
+		}
+		g += 1 // linear probing
+		if g >= uint32(len(m.groups)) {
+			g = 0
+		}
+	}
+}
+
+// insert stores |key| and |value| at the insertion location |g|, |s| returned by find.
+func (m *SharedMap[K, V]) insert(key K, value V, g, s uint32, lo h2) {
+	m.groups[g].keys[s] = key
+	m.groups[g].values[s] = value
+	m.ctrl[g][s] = int8(lo)
+	m.hdr.resident++
+}
+
+// grow replaces the table, which must be locked for writing, with a new
+// table of |n| groups. The new table is locked for writing in its place.
+func (m *SharedMap[K, V]) grow(n uint32) error {
+	nm, tmp, err := createSharedMap[K, V](m.path, n, m.hash.seed)
+	if err != nil {
+		return err
+	}
+	nm.hdr.rw = sharedWriter
+	for g := range m.ctrl {
+		for s, c := range m.ctrl[g] {
+			if c == empty || c == tombstone {
+				continue
+			}
+			k := m.groups[g].keys[s]
+			hi, lo := splitHash(nm.hash.Hash(k))
+			gg, ss, _ := nm.find(k, hi, lo)
+			nm.insert(k, m.groups[g].values[s], gg, ss, lo)
+		}
+	}
+	if err = os.Rename(tmp, m.path); err != nil {
+		_ = os.Remove(tmp)
+		_ = nm.Close()
+		return err
+	}
+	// processes waiting for the old table will reopen
+	// the path once they see that it was moved
+	atomic.StoreUint32(&m.hdr.moved, 1)
+	m.hdr.unlock()
+	_ = syscall.Munmap(m.data)
+	nm.path = m.path
+	*m = *nm
+	return nil
+}
+
+// lock locks the table for writing, switching to the table that replaced
+// it if it was grown by another process.
+func (m *SharedMap[K, V]) lock() error {
+	for {
+		m.hdr.lock()
+		if atomic.LoadUint32(&m.hdr.moved) == 0 {
+			return nil
+		}
+		m.hdr.unlock()
+		if err := m.reopen(); err != nil {
+			return err
+		}
+	}
+}
+
+// rlock locks the table for reading, switching to the table that replaced
+// it if it was grown by another process. If that fails, it reads the old
+// table, which is never modified once it has been replaced.
+func (m *SharedMap[K, V]) rlock() {
+	for {
+		m.hdr.rlock()
+		if atomic.LoadUint32(&m.hdr.moved) == 0 {
+			return
+		}
+		m.hdr.runlock()
+		if err := m.reopen(); err != nil {
+			m.hdr.rlock()
+			return
+		}
+	}
+}
+
+// unlock unlocks the table locked by lock, which may have been grown since.
+func (m *SharedMap[K, V]) unlock() {
+	m.hdr.unlock()
+}
+
+func (m *SharedMap[K, V]) runlock() {
+	m.hdr.runlock()
+}
+
+func (m *SharedMap[K, V]) reopen() error {
+	nm, err := openSharedMap[K, V](m.path)
+	if err != nil {
+		return err
+	}
+	_ = syscall.Munmap(m.data)
+	*m = *nm
+	return nil
+}
+
+// openSharedMap maps the SharedMap file at |path|.
+func openSharedMap[K comparable, V any](path string) (*SharedMap[K, V], error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() < mappedHeaderSize || int64(int(info.Size())) != info.Size() {
+		return nil, fmt.Errorf("%w: %s is %d bytes", ErrCorrupt, path, info.Size())
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, &os.PathError{Op: "mmap", Path: path, Err: err}
+	}
+	m, err := newSharedMap[K, V](data)
+	if err != nil {
+		_ = syscall.Munmap(data)
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	m.path = path
+	return m, nil
+}
+
+// createSharedMap creates and maps an empty SharedMap of |n| groups in a
+// temporary file beside |path|, returning it and the name of the file.
+func createSharedMap[K comparable, V any](path string, n uint32, seed uint64) (m *SharedMap[K, V], tmp string, err error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, "", err
+	}
+	tmp = f.Name()
+	defer func() {
+		_ = f.Close()
+		if err != nil {
+			_ = os.Remove(tmp)
+		}
+	}()
+	var g group[K, V]
+	size := mappedGroupsOffset(int(n)) + int(n)*int(unsafe.Sizeof(g))
+	if err = f.Truncate(int64(size)); err != nil {
+		return nil, "", err
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, "", &os.PathError{Op: "mmap", Path: tmp, Err: err}
+	}
+	var (
+		k K
+		v V
+	)
+	h := (*sharedHeader)(unsafe.Pointer(&data[0]))
+	h.magic = sharedMagic
+	h.version = sharedVersion
+	h.width = groupSize
+	if nativeBigEndian() {
+		h.flags = flagBigEndian
+	}
+	h.keySize = uint32(unsafe.Sizeof(k))
+	h.valueSize = uint32(unsafe.Sizeof(v))
+	h.groupSize = uint32(unsafe.Sizeof(g))
+	h.groups = n
+	h.seed = seed
+	h.crc = crc32.Checksum(data[:unsafe.Offsetof(h.crc)], crcTable)
+	if m, err = newSharedMap[K, V](data); err != nil {
+		_ = syscall.Munmap(data)
+		return nil, "", err
+	}
+	for i := range m.ctrl {
+		m.ctrl[i] = newEmptyMetadata()
+	}
+	return m, tmp, nil
+}
+
+// newSharedMap validates the SharedMap file mapped at |data|.
+func newSharedMap[K comparable, V any](data []byte) (*SharedMap[K, V], error) {
+	var (
+		k K
+		v V
+		g group[K, V]
+	)
+	h := (*sharedHeader)(unsafe.Pointer(&data[0]))
+	switch {
+	case h.magic != sharedMagic:
+		return nil, fmt.Errorf("%w: bad magic %q", ErrCorrupt, h.magic[:])
+	case crc32.Checksum(data[:unsafe.Offsetof(h.crc)], crcTable) != h.crc:
+		return nil, fmt.Errorf("%w: header checksum mismatch", ErrCorrupt)
+	case h.version != sharedVersion:
+		return nil, fmt.Errorf("swiss: unsupported SharedMap version %d", h.version)
+	case h.width != groupSize:
+		return nil, fmt.Errorf("swiss: SharedMap of width %d, not %d", h.width, groupSize)
+	case (h.flags&flagBigEndian != 0) != nativeBigEndian():
+		return nil, fmt.Errorf("swiss: SharedMap has foreign byte order")
+	case h.keySize != uint32(unsafe.Sizeof(k)),
+		h.valueSize != uint32(unsafe.Sizeof(v)),
+		h.groupSize != uint32(unsafe.Sizeof(g)):
+		return nil, fmt.Errorf("swiss: SharedMap does not hold %T keys and %T values", k, v)
+	case h.groups == 0 || mappedGroupsOffset(int(h.groups))+int(h.groups)*int(unsafe.Sizeof(g)) != len(data):
+		return nil, fmt.Errorf("%w: %d bytes do not hold %d groups", ErrCorrupt, len(data), h.groups)
+	}
+	hash, err := NewStableHasher[K](h.seed)
+	if err != nil {
+		return nil, err
+	}
+	n := int(h.groups)
+	return &SharedMap[K, V]{
+		data:   data,
+		hdr:    h,
+		ctrl:   unsafe.Slice((*metadata)(unsafe.Pointer(&data[mappedHeaderSize])), n),
+		groups: unsafe.Slice((*group[K, V])(unsafe.Pointer(&data[mappedGroupsOffset(n)])), n),
+		hash:   hash,
+		limit:  h.groups * maxAvgGroupLoad,
+	}, nil
+}
+
+func (h *sharedHeader) rlock() {
+	for {
+		l := atomic.LoadUint32(&h.rw)
+		if l&(sharedWriter|sharedWaiting) == 0 && atomic.CompareAndSwapUint32(&h.rw, l, l+1) {
+			return
+		}
+		runtime.Gosched()
+	}
+}
+
+func (h *sharedHeader) runlock() {
+	atomic.AddUint32(&h.rw, ^uint32(0))
+}
+
+func (h *sharedHeader) lock() {
+	for {
+		l := atomic.LoadUint32(&h.rw)
+		if l&^sharedWaiting == 0 {
+			if atomic.CompareAndSwapUint32(&h.rw, l, sharedWriter) {
+				return
+			}
+		} else if l&sharedWaiting == 0 {
+			// keep new readers out
+			atomic.CompareAndSwapUint32(&h.rw, l, l|sharedWaiting)
+		}
+		runtime.Gosched()
+	}
+}
+
+func (h *sharedHeader) unlock() {
+	atomic.StoreUint32(&h.rw, 0)
+}
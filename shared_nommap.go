@@ -0,0 +1,73 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"fmt"
+	"runtime"
+)
+
+var errNoSharedMap = fmt.Errorf("swiss: SharedMap is not supported on %s", runtime.GOOS)
+
+// SharedMap is a hash map whose table is a memory-mapped file shared by
+// several processes. Memory mapping is not supported on this platform, so
+// OpenSharedMap always fails.
+type SharedMap[K comparable, V any] struct{}
+
+// OpenSharedMap returns an error, as memory
+// mapping is not supported on this platform.
+func OpenSharedMap[K comparable, V any](path string, sz uint32) (*SharedMap[K, V], error) {
+	return nil, errNoSharedMap
+}
+
+// Has returns true if |key| is present in |m|.
+func (m *SharedMap[K, V]) Has(key K) (ok bool) {
+	return
+}
+
+// Get returns the |value| mapped by |key| if one exists.
+func (m *SharedMap[K, V]) Get(key K) (value V, ok bool) {
+	return
+}
+
+// Put attempts to insert |key| and |value|.
+func (m *SharedMap[K, V]) Put(key K, value V) error {
+	return errNoSharedMap
+}
+
+// Delete attempts to remove |key|, returns true successful.
+func (m *SharedMap[K, V]) Delete(key K) (ok bool, err error) {
+	return false, errNoSharedMap
+}
+
+// Iter iterates the elements of the SharedMap.
+func (m *SharedMap[K, V]) Iter(cb func(k K, v V) (stop bool)) {}
+
+// Count returns the number of elements in the SharedMap.
+func (m *SharedMap[K, V]) Count() int {
+	return 0
+}
+
+// Capacity returns the number of elements the SharedMap can hold.
+func (m *SharedMap[K, V]) Capacity() int {
+	return 0
+}
+
+// Close closes the SharedMap.
+func (m *SharedMap[K, V]) Close() error {
+	return nil
+}
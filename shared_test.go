@@ -0,0 +1,121 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharedMap(t *testing.T) {
+	assert.Equal(t, uintptr(mappedHeaderSize), unsafe.Sizeof(sharedHeader{}))
+
+	type route struct {
+		port uint16
+		addr [4]byte
+	}
+	path := filepath.Join(t.TempDir(), "routes")
+	// each SharedMap maps the file separately,
+	// as the SharedMaps of other processes do
+	a, err := OpenSharedMap[uint32, route](path, 100)
+	require.NoError(t, err)
+	b, err := OpenSharedMap[uint32, route](path, 0)
+	require.NoError(t, err)
+
+	keys := genUint32Data(1000)
+	for i, k := range keys[:100] {
+		require.NoError(t, a.Put(k, route{port: uint16(i)}))
+	}
+	assert.Equal(t, 100, b.Count())
+	for i, k := range keys[:100] {
+		assert.Equal(t, route{port: uint16(i)}, must(b.Get(k)))
+	}
+	ok, err := b.Delete(keys[0])
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.False(t, a.Has(keys[0]))
+
+	// growing replaces the file
+	for i, k := range keys {
+		require.NoError(t, b.Put(k, route{port: uint16(i), addr: [4]byte{10, 0, 0, 1}}))
+	}
+	assert.Greater(t, len(b.groups), int(numGroups(100)))
+	assert.Equal(t, 1000, a.Count())
+	assert.Equal(t, len(b.groups), len(a.groups))
+	n := 0
+	a.Iter(func(k uint32, r route) (stop bool) {
+		assert.Equal(t, byte(10), r.addr[0])
+		n++
+		return
+	})
+	assert.Equal(t, 1000, n)
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	require.NoError(t, a.Close())
+	require.NoError(t, b.Close())
+
+	_, err = OpenSharedMap[uint32, uint64](path, 0)
+	assert.Error(t, err)
+	_, err = OpenSharedMap[uint32, *int](path, 0)
+	assert.Error(t, err)
+}
+
+func TestSharedMapConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counts")
+	const writers, n = 4, 2000
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			m, err := OpenSharedMap[int, int](path, 0)
+			require.NoError(t, err)
+			defer m.Close()
+			for i := 0; i < n; i++ {
+				assert.NoError(t, m.Put(w*n+i, i))
+				if i%3 == 0 {
+					_, err = m.Delete(w*n + i)
+					assert.NoError(t, err)
+				}
+				m.Has(i)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	m, err := OpenSharedMap[int, int](path, 0)
+	require.NoError(t, err)
+	defer m.Close()
+	assert.Equal(t, writers*(n-(n+2)/3), m.Count())
+	for w := 0; w < writers; w++ {
+		for i := 0; i < n; i++ {
+			v, ok := m.Get(w*n + i)
+			assert.Equal(t, i%3 != 0, ok)
+			if ok {
+				assert.Equal(t, i, v)
+			}
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Snapshot is a read-only view of a Map as of the call to Snapshot.
+// It shares the table of the Map until the Map modifies a group, at
+// which point the group is copied into the Snapshot first. A Snapshot
+// may be read from any goroutine, concurrently with the goroutine that
+// modifies the Map.
+//
+// Every open Snapshot adds the cost of a group copy to the first
+// modification of each group, so Snapshots should be closed once
+// they are no longer needed. Rehashing the Map releases its
+// Snapshots from this cost, as the old table is no longer modified.
+type Snapshot[K comparable, V any] struct {
+	// mu guards |saved| and the groups of the Map it shares
+	mu     sync.RWMutex
+	ctrl   []metadata
+	groups []group[K, V]
+	// saved holds a copy of each group
+	// modified since the Snapshot was taken
+	saved  []*savedGroup[K, V]
+	hash   hasher[K]
+	count  int
+	closed int32
+}
+
+type savedGroup[K comparable, V any] struct {
+	ctrl  metadata
+	group group[K, V]
+}
+
+// Snapshot returns a read-only view of |m| in O(number of groups).
+// Values must not be modified through pointers returned by GetPtr,
+// PutPtr or Ref.Ptr before the call to Snapshot.
+func (m *Map[K, V]) Snapshot() *Snapshot[K, V] {
+	m.settle()
+	s := &Snapshot[K, V]{
+		ctrl:   m.ctrl,
+		groups: m.groups,
+		saved:  make([]*savedGroup[K, V], len(m.groups)),
+		hash:   m.hash,
+		count:  m.Count(),
+	}
+	if m.ext == nil {
+		m.ext = &mapExt[K, V]{}
+	}
+	m.ext.snaps = append(m.ext.snaps, s)
+	return s
+}
+
+// preserve saves group |g| in every open Snapshot of |m| that
+// shares it. It must be called before group |g| is modified.
+func (m *Map[K, V]) preserve(g uint32) {
+	snaps := m.ext.snaps[:0]
+	for _, s := range m.ext.snaps {
+		if atomic.LoadInt32(&s.closed) != 0 {
+			continue
+		}
+		// only the writer stores to |s.saved|
+		if s.saved[g] == nil {
+			sg := &savedGroup[K, V]{ctrl: m.ctrl[g], group: m.groups[g]}
+			s.mu.Lock()
+			s.saved[g] = sg
+			s.mu.Unlock()
+		}
+		snaps = append(snaps, s)
+	}
+	for i := len(snaps); i < len(m.ext.snaps); i++ {
+		m.ext.snaps[i] = nil
+	}
+	m.ext.snaps = snaps
+	m.releaseExt()
+}
+
+// detach releases the Snapshots of |m|. It must be called once |m|
+// has stopped modifying the table they share, e.g. after a rehash.
+func (m *Map[K, V]) detach() {
+	if m.ext != nil {
+		m.ext.snaps = nil
+		m.releaseExt()
+	}
+}
+
+// hasSnapshots returns true if the table of |m| is shared with a Snapshot.
+func (m *Map[K, V]) hasSnapshots() bool {
+	return m.ext != nil && len(m.ext.snaps) > 0
+}
+
+// Close releases |s|. It is safe to call Close from any goroutine,
+// but |s| must not be read after Close.
+func (s *Snapshot[K, V]) Close() {
+	atomic.StoreInt32(&s.closed, 1)
+}
+
+// Count returns the number of elements in the Snapshot.
+func (s *Snapshot[K, V]) Count() int {
+	return s.count
+}
+
+// Has returns true if |key| is present in |s|.
+func (s *Snapshot[K, V]) Has(key K) (ok bool) {
+	_, ok = s.Get(key)
+	return
+}
+
+// Get returns the |value| mapped by |key| if one exists.
+func (s *Snapshot[K, V]) Get(key K) (value V, ok bool) {
+	hi, lo := splitHash(s.hash.Hash(key))
+	g := probeStart(hi, len(s.groups))
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for {
+		ctrl, grp := s.group(g)
+		matches := metaMatchH2(ctrl, lo)
+		for matches != 0 {
+			i := nextMatch(&matches)
+			if key == grp.keys[i] {
+				value, ok = grp.values[i], true
+				return
+			}
+		}
+		// |key| is not in group |g|,
+		// stop probing if we see an empty slot
+		matches = metaMatchEmpty(ctrl)
+		if matches != 0 {
+			ok = false
+			return
+		}
+		g += 1 // linear probing
+		if g >= uint32(len(s.groups)) {
+			g = 0
+		}
+	}
+}
+
+// Iter iterates the elements of the Snapshot, passing them to the
+// callback. Unlike Map.Iter, every element is visited exactly once,
+// regardless of modifications to the Map. |cb| may modify the Map.
+func (s *Snapshot[K, V]) Iter(cb func(k K, v V) (stop bool)) {
+	var (
+		ctrl metadata
+		grp  group[K, V]
+	)
+	// pick a random starting group
+	g := randIntN(len(s.groups))
+	for n := 0; n < len(s.groups); n++ {
+		// copy the group so that |cb| runs unlocked
+		s.mu.RLock()
+		pc, pg := s.group(g)
+		ctrl, grp = *pc, *pg
+		s.mu.RUnlock()
+		for i, c := range ctrl {
+			if c == empty || c == tombstone {
+				continue
+			}
+			if stop := cb(grp.keys[i], grp.values[i]); stop {
+				return
+			}
+		}
+		g++
+		if g >= uint32(len(s.groups)) {
+			g = 0
+		}
+	}
+}
+
+// group returns group |g| as of the Snapshot. |s.mu| must be held.
+func (s *Snapshot[K, V]) group(g uint32) (*metadata, *group[K, V]) {
+	if sg := s.saved[g]; sg != nil {
+		return &sg.ctrl, &sg.group
+	}
+	return &s.ctrl[g], &s.groups[g]
+}
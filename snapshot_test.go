@@ -0,0 +1,161 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	keys := genUint32Data(2000)
+	m := NewMap[uint32, int](uint32(len(keys)))
+	golden := make(map[uint32]int, len(keys)/2)
+	for i, k := range keys[:len(keys)/2] {
+		m.Put(k, i)
+		golden[k] = i
+	}
+
+	writes := []struct {
+		name string
+		fn   func()
+	}{
+		{"put", func() {
+			for i, k := range keys {
+				m.Put(k, -i)
+			}
+		}},
+		{"delete", func() {
+			for _, k := range keys[:len(keys)/4] {
+				m.Delete(k)
+			}
+		}},
+		{"compute and entry", func() {
+			for _, k := range keys[:len(keys)/4] {
+				m.Compute(k, func(v int, ok bool) (int, bool) {
+					return v + 1, true
+				})
+				m.Entry(k).AndModify(func(v int) int {
+					return v + 1
+				})
+			}
+		}},
+		{"pointers", func() {
+			for _, k := range keys[:len(keys)/2] {
+				*m.PutPtr(k) = 42
+				if r, ok := m.GetRef(k); ok {
+					*r.Ptr()++
+				}
+			}
+		}},
+		{"delete func", func() {
+			DeleteFunc(m, func(k uint32, v int) bool {
+				return v%2 == 0
+			})
+		}},
+		{"grow", func() {
+			m.Grow(len(keys) * 4)
+		}},
+		{"clear", func() {
+			m.Clear()
+		}},
+	}
+	for _, w := range writes {
+		t.Run(w.name, func(t *testing.T) {
+			before := ToMap(m)
+			s := m.Snapshot()
+			defer s.Close()
+			w.fn()
+			assertSnapshot(t, before, s)
+			for _, k := range keys {
+				_, ok := before[k]
+				assert.Equal(t, ok, s.Has(k))
+			}
+		})
+		// restore the original contents
+		m.Clear()
+		for k, v := range golden {
+			m.Put(k, v)
+		}
+	}
+
+	t.Run("close", func(t *testing.T) {
+		s1, s2 := m.Snapshot(), m.Snapshot()
+		s1.Close()
+		m.Put(keys[0], -1)
+		assert.Len(t, m.ext.snaps, 1)
+		assertSnapshot(t, golden, s2)
+		s2.Close()
+		m.Put(keys[0], -2)
+		assert.Nil(t, m.ext)
+	})
+	t.Run("clone", func(t *testing.T) {
+		s := m.Snapshot()
+		defer s.Close()
+		c := Clone(m)
+		assert.Nil(t, c.ext)
+		Copy(m, c)
+		assert.True(t, Equal(m, c))
+	})
+}
+
+// TestSnapshotConcurrent reads Snapshots from other goroutines
+// while the Map is modified. It is intended to be run with -race.
+func TestSnapshotConcurrent(t *testing.T) {
+	keys := genStringData(8, 4000)
+	m := NewMap[string, int](0)
+	for i, k := range keys[:1000] {
+		m.Put(k, i)
+	}
+	var wg sync.WaitGroup
+	for round := 0; round < 8; round++ {
+		golden := ToMap(m)
+		s := m.Snapshot()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer s.Close()
+			assertSnapshot(t, golden, s)
+		}()
+		// modify the Map while the Snapshot is read
+		for i, k := range keys {
+			switch (i + round) % 3 {
+			case 0:
+				m.Put(k, i*round)
+			case 1:
+				m.Delete(k)
+			}
+		}
+	}
+	wg.Wait()
+}
+
+func assertSnapshot[K comparable, V any](t *testing.T, golden map[K]V, s *Snapshot[K, V]) {
+	assert.Equal(t, len(golden), s.Count())
+	for k, exp := range golden {
+		act, ok := s.Get(k)
+		assert.True(t, ok)
+		assert.Equal(t, exp, act)
+	}
+	n := 0
+	s.Iter(func(k K, v V) (stop bool) {
+		assert.Equal(t, golden[k], v)
+		n++
+		return
+	})
+	assert.Equal(t, len(golden), n)
+}
@@ -0,0 +1,318 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/dolthub/maphash"
+)
+
+// StableHasher hashes keys by their content using wyhash, so that a key
+// hashes the same for a given seed in every process, on every platform
+// and in every Go version. Keys may be booleans, integers, floats and
+// strings, and arrays and structs of them. A StableHasher is slower than
+// the hasher of NewMap, and as its seed may be known it doesn't protect
+// tables from keys chosen to collide.
+type StableHasher[K comparable] struct {
+	seed uint64
+	hash func(seed uint64, key unsafe.Pointer) uint64
+}
+
+// NewStableHasher returns a StableHasher using |seed|.
+func NewStableHasher[K comparable](seed uint64) (StableHasher[K], error) {
+	hash, err := stableHashFunc(reflect.TypeOf((*K)(nil)).Elem())
+	if err != nil {
+		return StableHasher[K]{}, err
+	}
+	return StableHasher[K]{seed: seed, hash: hash}, nil
+}
+
+// NewStableMap constructs a Map whose keys are hashed by |h|. Unlike the
+// hasher of NewMap, |h| is kept when the Map is rehashed.
+func NewStableMap[K comparable, V any](sz uint32, h StableHasher[K]) (m *Map[K, V]) {
+	m = NewMap[K, V](sz)
+	m.hash, m.keepSeed = h.hasher(), true
+	return
+}
+
+// NewStableMap8 constructs a Map8 whose keys are hashed by |h|. Unlike the
+// hasher of NewMap8, |h| is kept when the Map8 is rehashed.
+func NewStableMap8[K comparable, V any](sz uint32, h StableHasher[K]) (m *Map8[K, V]) {
+	m = NewMap8[K, V](sz)
+	m.hash = h.hasher()
+	return
+}
+
+// NewStableSet constructs a Set whose keys are hashed by |h|. Unlike the
+// hasher of NewSet, |h| is kept when the Set is rehashed.
+func NewStableSet[K comparable](sz uint32, h StableHasher[K]) *Set[K] {
+	return newSet[K](uint64(sz), h.hasher())
+}
+
+// Hash hashes |key|.
+func (h StableHasher[K]) Hash(key K) uint64 {
+	return h.hash(h.seed, noescape(unsafe.Pointer(&key)))
+}
+
+// Seed returns the seed of |h|.
+func (h StableHasher[K]) Seed() uint64 {
+	return h.seed
+}
+
+// hasher hashes the keys of a table. It is either a maphash.Hasher, or a
+// StableHasher whose function and seed it keeps, which hashes keys by their
+// content with all 64 bits of the hash on every platform.
+type hasher[K comparable] struct {
+	// hash hashes keys with |runtime| or |stable|,
+	// chosen when the hasher is made or reseeded
+	hash    func(key K) uint64
+	runtime maphash.Hasher[K]
+	// id identifies the seed of |runtime|, which is private
+	id uint64
+	// stable hashes keys with |seed| if non-nil
+	stable func(seed uint64, key unsafe.Pointer) uint64
+	seed   uint64
+}
+
+// hasherIDs is the last id given to a hasher
+var hasherIDs uint64
+
+func newHasher[K comparable]() hasher[K] {
+	return hasher[K]{runtime: maphash.NewHasher[K](), id: atomic.AddUint64(&hasherIDs, 1)}.bind()
+}
+
+// Hash hashes |key|.
+func (h *hasher[K]) Hash(key K) uint64 {
+	return h.hash(key)
+}
+
+// bind returns a copy of |h| whose hash function hashes with its seed.
+func (h hasher[K]) bind() hasher[K] {
+	if stable, seed := h.stable, h.seed; stable != nil {
+		h.hash = func(key K) uint64 {
+			return stable(seed, noescape(unsafe.Pointer(&key)))
+		}
+	} else {
+		h.hash = h.runtime.Hash
+	}
+	return h
+}
+
+// reseeded returns a copy of |h| with a new random seed.
+func (h hasher[K]) reseeded() hasher[K] {
+	if h.stable != nil {
+		h.seed = uint64(fastrand())<<32 | uint64(fastrand())
+	} else {
+		h.runtime = maphash.NewSeed(h.runtime)
+		h.id = atomic.AddUint64(&hasherIDs, 1)
+	}
+	return h.bind()
+}
+
+// same returns true if |h| and |o| hash keys identically: they are
+// copies of the same hasher, or StableHashers of K with the same seed.
+func (h hasher[K]) same(o hasher[K]) bool {
+	if h.stable != nil || o.stable != nil {
+		return h.stable != nil && o.stable != nil && h.seed == o.seed
+	}
+	return h.id == o.id
+}
+
+// hasher returns a hasher that hashes keys with |h|.
+func (h StableHasher[K]) hasher() hasher[K] {
+	return hasher[K]{stable: h.hash, seed: h.seed}.bind()
+}
+
+// stableHashFunc returns a function hashing values of type |t| by their
+// canonical encoding: booleans are a byte, integers are little-endian
+// and of their declared size (int, uint and uintptr are 8 bytes), floats
+// are their little-endian IEEE 754 bits with -0 stored as +0, strings are
+// their bytes, prefixed by their length as a uvarint when within an array
+// or struct, and arrays and structs are their elements or fields in order,
+// skipping blank fields.
+func stableHashFunc(t reflect.Type) (func(seed uint64, p unsafe.Pointer) uint64, error) {
+	if t.Kind() == reflect.String {
+		return func(seed uint64, p unsafe.Pointer) uint64 {
+			return wyhash(seed, stringBytes((*string)(p)))
+		}, nil
+	}
+	if typeIsMemComparable(t) && !nativeBigEndian() && strconv.IntSize == 64 {
+		// the memory of these types is their canonical encoding
+		size := t.Size()
+		return func(seed uint64, p unsafe.Pointer) uint64 {
+			return wyhash(seed, unsafe.Slice((*byte)(p), size))
+		}, nil
+	}
+	enc, err := stableEncoder(t)
+	if err != nil {
+		return nil, err
+	}
+	pool := sync.Pool{New: func() any {
+		b := make([]byte, 0, 64)
+		return &b
+	}}
+	return func(seed uint64, p unsafe.Pointer) uint64 {
+		buf := pool.Get().(*[]byte)
+		*buf = enc((*buf)[:0], p)
+		h := wyhash(seed, *buf)
+		pool.Put(buf)
+		return h
+	}, nil
+}
+
+// stableEncoder returns a function appending the canonical encoding of
+// the value of type |t| at |p|.
+func stableEncoder(t reflect.Type) (func(b []byte, p unsafe.Pointer) []byte, error) {
+	switch t.Kind() {
+	case reflect.Bool:
+		return func(b []byte, p unsafe.Pointer) []byte {
+			if *(*bool)(p) {
+				return append(b, 1)
+			}
+			return append(b, 0)
+		}, nil
+	case reflect.Int8, reflect.Uint8:
+		return func(b []byte, p unsafe.Pointer) []byte {
+			return append(b, *(*uint8)(p))
+		}, nil
+	case reflect.Int16, reflect.Uint16:
+		return func(b []byte, p unsafe.Pointer) []byte {
+			var w [2]byte
+			binary.LittleEndian.PutUint16(w[:], *(*uint16)(p))
+			return append(b, w[:]...)
+		}, nil
+	case reflect.Int32, reflect.Uint32:
+		return func(b []byte, p unsafe.Pointer) []byte {
+			var w [4]byte
+			binary.LittleEndian.PutUint32(w[:], *(*uint32)(p))
+			return append(b, w[:]...)
+		}, nil
+	case reflect.Int64, reflect.Uint64:
+		return stableEncodeUint64(func(p unsafe.Pointer) uint64 {
+			return *(*uint64)(p)
+		}), nil
+	case reflect.Int:
+		return stableEncodeUint64(func(p unsafe.Pointer) uint64 {
+			return uint64(*(*int)(p))
+		}), nil
+	case reflect.Uint:
+		return stableEncodeUint64(func(p unsafe.Pointer) uint64 {
+			return uint64(*(*uint)(p))
+		}), nil
+	case reflect.Uintptr:
+		return stableEncodeUint64(func(p unsafe.Pointer) uint64 {
+			return uint64(*(*uintptr)(p))
+		}), nil
+	case reflect.Float32:
+		return func(b []byte, p unsafe.Pointer) []byte {
+			var w [4]byte
+			f := *(*float32)(p)
+			if f == 0 {
+				f = 0 // -0 == +0
+			}
+			binary.LittleEndian.PutUint32(w[:], math.Float32bits(f))
+			return append(b, w[:]...)
+		}, nil
+	case reflect.Float64:
+		return stableEncodeUint64(func(p unsafe.Pointer) uint64 {
+			f := *(*float64)(p)
+			if f == 0 {
+				f = 0 // -0 == +0
+			}
+			return math.Float64bits(f)
+		}), nil
+	case reflect.String:
+		return func(b []byte, p unsafe.Pointer) []byte {
+			s := *(*string)(p)
+			var w [binary.MaxVarintLen64]byte
+			b = append(b, w[:binary.PutUvarint(w[:], uint64(len(s)))]...)
+			return append(b, s...)
+		}, nil
+	case reflect.Array:
+		elem, err := stableEncoder(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		n, size := t.Len(), t.Elem().Size()
+		return func(b []byte, p unsafe.Pointer) []byte {
+			for i := 0; i < n; i++ {
+				b = elem(b, unsafe.Pointer(uintptr(p)+uintptr(i)*size))
+			}
+			return b
+		}, nil
+	case reflect.Struct:
+		type field struct {
+			offset uintptr
+			enc    func(b []byte, p unsafe.Pointer) []byte
+		}
+		var fields []field
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Name == "_" {
+				continue
+			}
+			enc, err := stableEncoder(f.Type)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, field{offset: f.Offset, enc: enc})
+		}
+		return func(b []byte, p unsafe.Pointer) []byte {
+			for _, f := range fields {
+				b = f.enc(b, unsafe.Pointer(uintptr(p)+f.offset))
+			}
+			return b
+		}, nil
+	default:
+		return nil, fmt.Errorf("swiss: StableHasher cannot hash %v", t)
+	}
+}
+
+func stableEncodeUint64(load func(p unsafe.Pointer) uint64) func(b []byte, p unsafe.Pointer) []byte {
+	return func(b []byte, p unsafe.Pointer) []byte {
+		var w [8]byte
+		binary.LittleEndian.PutUint64(w[:], load(p))
+		return append(b, w[:]...)
+	}
+}
+
+// stringBytes returns the bytes of |*s| without copying them.
+func stringBytes(s *string) []byte {
+	type header struct {
+		data *byte
+		len  int
+	}
+	h := (*header)(unsafe.Pointer(s))
+	return unsafe.Slice(h.data, h.len)
+}
+
+// noescape hides a pointer from escape analysis. It is the identity
+// function, but escape analysis doesn't think the output depends on the
+// input, as it is laundered through a uintptr.
+//
+//go:nosplit
+//go:nocheckptr
+func noescape(p unsafe.Pointer) unsafe.Pointer {
+	x := uintptr(p)
+	return *(*unsafe.Pointer)(unsafe.Pointer(&x))
+}
@@ -0,0 +1,125 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStableHasher(t *testing.T) {
+	t.Run("golden", func(t *testing.T) {
+		// hashes may be persisted, and must never change
+		s, err := NewStableHasher[string](7)
+		require.NoError(t, err)
+		assert.Equal(t, wyhash(7, []byte("hello")), s.Hash("hello"))
+		assert.Equal(t, uint64(7), s.Seed())
+
+		type key struct {
+			a int
+			b string
+			c [2]bool
+			_ int8
+			d float32
+		}
+		k, err := NewStableHasher[key](7)
+		require.NoError(t, err)
+		enc := []byte{
+			1, 0, 0, 0, 0, 0, 0, 0, // a
+			2, 'h', 'i', // b
+			1, 0, // c
+			0, 0, 0xc0, 0x3f, // d
+		}
+		assert.Equal(t, wyhash(7, enc), k.Hash(key{a: 1, b: "hi", c: [2]bool{true}, d: 1.5}))
+	})
+	t.Run("canonical memory", func(t *testing.T) {
+		type key struct {
+			a int32
+			b [2]uint16
+			c uint64
+			d int
+		}
+		h, err := NewStableHasher[key](1)
+		require.NoError(t, err)
+		enc, err := stableEncoder(reflect.TypeOf(key{}))
+		require.NoError(t, err)
+		for _, k := range []key{{}, {a: -1}, {1, [2]uint16{2, 3}, 4, -5}} {
+			b := enc(nil, unsafe.Pointer(&k))
+			assert.Equal(t, wyhash(1, b), h.Hash(k))
+		}
+	})
+	t.Run("floats", func(t *testing.T) {
+		h, err := NewStableHasher[[2]float64](1)
+		require.NoError(t, err)
+		assert.Equal(t, h.Hash([2]float64{0, 1}), h.Hash([2]float64{math.Copysign(0, -1), 1}))
+		assert.NotEqual(t, h.Hash([2]float64{0, 1}), h.Hash([2]float64{1, 0}))
+	})
+	t.Run("seeds", func(t *testing.T) {
+		h1, _ := NewStableHasher[uint64](1)
+		h2, _ := NewStableHasher[uint64](2)
+		assert.NotEqual(t, h1.Hash(42), h2.Hash(42))
+	})
+	t.Run("unsupported", func(t *testing.T) {
+		_, err := NewStableHasher[*int](1)
+		assert.Error(t, err)
+		_, err = NewStableHasher[[2]*int](1)
+		assert.Error(t, err)
+		_, err = NewStableHasher[struct {
+			a int
+			b chan int
+		}](1)
+		assert.Error(t, err)
+	})
+	t.Run("allocs", func(t *testing.T) {
+		u, _ := NewStableHasher[uint64](1)
+		s, _ := NewStableHasher[string](1)
+		assert.Equal(t, 0.0, testing.AllocsPerRun(100, func() {
+			u.Hash(42)
+			s.Hash("forty two")
+		}))
+	})
+}
+
+func TestStableMap(t *testing.T) {
+	h, err := NewStableHasher[string](42)
+	require.NoError(t, err)
+	keys := genStringData(8, 1000)
+
+	m := NewStableMap[string, int](0, h)
+	m8 := NewStableMap8[string, int](0, h)
+	s := NewStableSet[string](0, h)
+	for i, k := range keys {
+		m.Put(k, i)
+		m8.Put(k, i)
+		s.Add(k)
+	}
+	// the StableHasher survives rehashing
+	for i, k := range keys {
+		assert.Equal(t, h.Hash(k), m.hash.Hash(k))
+		assert.Equal(t, h.Hash(k), m8.hash.Hash(k))
+		assert.Equal(t, h.Hash(k), s.hash.Hash(k))
+		assert.Equal(t, i, must(m.Get(k)))
+		assert.Equal(t, i, must(m8.Get(k)))
+		assert.True(t, s.Contains(k))
+	}
+	m.Clear()
+	m.Shrink()
+	assert.Equal(t, h.Hash("x"), m.hash.Hash("x"))
+}
@@ -0,0 +1,75 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import "reflect"
+
+// hasPointers returns true if values of type T contain pointers
+// that keep memory reachable for the garbage collector.
+func hasPointers[T any]() bool {
+	return typeHasPointers(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+func typeHasPointers(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return false
+	case reflect.Array:
+		return t.Len() > 0 && typeHasPointers(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if typeHasPointers(t.Field(i).Type) {
+				return true
+			}
+		}
+		return false
+	default: // pointers, strings, slices, maps, chans, funcs and interfaces
+		return true
+	}
+}
+
+// isMemComparable returns true if values of type T are equal exactly
+// when their memory is, so that hashing their memory is consistent with
+// ==. Floats (where 0 == -0 and NaN != NaN), pointers and structs with
+// padding are not.
+func isMemComparable[T any]() bool {
+	return typeIsMemComparable(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+func typeIsMemComparable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	case reflect.Array:
+		return t.Len() == 0 || typeIsMemComparable(t.Elem())
+	case reflect.Struct:
+		var size uintptr
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Name == "_" || f.Offset != size || !typeIsMemComparable(f.Type) {
+				return false
+			}
+			size += f.Type.Size()
+		}
+		return size == t.Size()
+	default:
+		return false
+	}
+}
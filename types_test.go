@@ -0,0 +1,78 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasPointers(t *testing.T) {
+	assert.False(t, hasPointers[int]())
+	assert.False(t, hasPointers[uintptr]())
+	assert.False(t, hasPointers[complex128]())
+	assert.False(t, hasPointers[[4]uint32]())
+	assert.False(t, hasPointers[struct{}]())
+	assert.False(t, hasPointers[struct {
+		a int8
+		b [2]float64
+	}]())
+	assert.False(t, hasPointers[[0]*int]())
+
+	assert.True(t, hasPointers[string]())
+	assert.True(t, hasPointers[*int]())
+	assert.True(t, hasPointers[[]byte]())
+	assert.True(t, hasPointers[any]())
+	assert.True(t, hasPointers[map[int]int]())
+	assert.True(t, hasPointers[chan int]())
+	assert.True(t, hasPointers[func()]())
+	assert.True(t, hasPointers[unsafe.Pointer]())
+	assert.True(t, hasPointers[[2]string]())
+	assert.True(t, hasPointers[struct {
+		a int
+		b *int
+	}]())
+}
+
+func TestIsMemComparable(t *testing.T) {
+	assert.True(t, isMemComparable[int]())
+	assert.True(t, isMemComparable[bool]())
+	assert.True(t, isMemComparable[[4]uint32]())
+	assert.True(t, isMemComparable[struct{}]())
+	assert.True(t, isMemComparable[struct {
+		a, b int32
+		c    [2]uint64
+	}]())
+
+	assert.False(t, isMemComparable[float64]())
+	assert.False(t, isMemComparable[complex64]())
+	assert.False(t, isMemComparable[string]())
+	assert.False(t, isMemComparable[*int]())
+	assert.False(t, isMemComparable[[2]float32]())
+	assert.False(t, isMemComparable[struct {
+		a int8
+		b int64
+	}]())
+	assert.False(t, isMemComparable[struct {
+		a int64
+		b int8
+	}]())
+	assert.False(t, isMemComparable[struct {
+		a int32
+		_ int32
+	}]())
+}
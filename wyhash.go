@@ -0,0 +1,77 @@
+// Copyright 2023 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swiss
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// wyhash is a portable implementation of wyhash (github.com/wangyi-fudan/wyhash).
+// Unlike maphash.Hasher, its results depend only on |seed| and |p|, and not on
+// the process, platform or Go version, so they may be persisted.
+func wyhash(seed uint64, p []byte) uint64 {
+	const (
+		wyp0 = 0xa0761d6478bd642f
+		wyp1 = 0xe7037ed1a0b428db
+		wyp2 = 0x8ebc6af09c88c6e3
+		wyp3 = 0x589965cc75374cc3
+	)
+	n := len(p)
+	seed ^= wymix(seed^wyp0, wyp1)
+
+	var a, b uint64
+	if n <= 16 {
+		if n >= 4 {
+			q := (n >> 3) << 2
+			a = wyr4(p)<<32 | wyr4(p[q:])
+			b = wyr4(p[n-4:])<<32 | wyr4(p[n-4-q:])
+		} else if n > 0 {
+			a = uint64(p[0])<<16 | uint64(p[n>>1])<<8 | uint64(p[n-1])
+		}
+	} else {
+		// |o| is the offset of the remaining |i| bytes,
+		// the last 16 bytes may overlap consumed ones
+		o, i := 0, n
+		if i > 48 {
+			s1, s2 := seed, seed
+			for ; i > 48; o, i = o+48, i-48 {
+				seed = wymix(wyr8(p[o:])^wyp1, wyr8(p[o+8:])^seed)
+				s1 = wymix(wyr8(p[o+16:])^wyp2, wyr8(p[o+24:])^s1)
+				s2 = wymix(wyr8(p[o+32:])^wyp3, wyr8(p[o+40:])^s2)
+			}
+			seed ^= s1 ^ s2
+		}
+		for ; i > 16; o, i = o+16, i-16 {
+			seed = wymix(wyr8(p[o:])^wyp1, wyr8(p[o+8:])^seed)
+		}
+		a, b = wyr8(p[n-16:]), wyr8(p[n-8:])
+	}
+	hi, lo := bits.Mul64(a^wyp1, b^seed)
+	return wymix(lo^wyp0^uint64(n), hi^wyp1)
+}
+
+func wymix(a, b uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	return hi ^ lo
+}
+
+func wyr8(p []byte) uint64 {
+	return binary.LittleEndian.Uint64(p)
+}
+
+func wyr4(p []byte) uint64 {
+	return uint64(binary.LittleEndian.Uint32(p))
+}
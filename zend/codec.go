@@ -0,0 +1,125 @@
+package zend
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"unsafe"
+)
+
+// SwissCodec converts values of type T to and from bytes.
+type SwissCodec[T any] interface {
+	// Append appends the encoding of |v| to |b|.
+	Append(b []byte, v T) ([]byte, error)
+	// Decode decodes a value from the entirety of |b|.
+	// It must not retain |b|.
+	Decode(b []byte) (T, error)
+}
+
+// swissFixedCodec is implemented by SwissCodecs whose encodings all have
+// the same size, which the binary format then stores without a length prefix.
+type swissFixedCodec interface {
+	fixedSize() int
+}
+
+// SwissRawCodec encodes values as a copy of their memory. T must not
+// contain pointers. Encodings are in native byte order, and include the
+// padding of struct types.
+type SwissRawCodec[T any] struct{}
+
+// Append implements SwissCodec.
+func (SwissRawCodec[T]) Append(b []byte, v T) ([]byte, error) {
+	if swissHasPointers[T]() {
+		return b, swissRawCodecError[T]()
+	}
+	return append(b, swissRawBytes(&v)...), nil
+}
+
+// Decode implements SwissCodec.
+func (SwissRawCodec[T]) Decode(b []byte) (v T, err error) {
+	if swissHasPointers[T]() {
+		return v, swissRawCodecError[T]()
+	}
+	if len(b) != int(unsafe.Sizeof(v)) {
+		return v, fmt.Errorf("zend: raw %T of %d bytes", v, len(b))
+	}
+	copy(swissRawBytes(&v), b)
+	return
+}
+
+func (SwissRawCodec[T]) fixedSize() int {
+	var v T
+	return int(unsafe.Sizeof(v))
+}
+
+func swissRawCodecError[T any]() error {
+	return fmt.Errorf("zend: SwissRawCodec of %v, which contains pointers", reflect.TypeOf((*T)(nil)).Elem())
+}
+
+// swissRawBytes returns the memory of |*p|.
+func swissRawBytes[T any](p *T) []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(p)), unsafe.Sizeof(*p))
+}
+
+// SwissStringCodec encodes strings as their bytes.
+type SwissStringCodec[T ~string] struct{}
+
+// Append implements SwissCodec.
+func (SwissStringCodec[T]) Append(b []byte, v T) ([]byte, error) {
+	return append(b, v...), nil
+}
+
+// Decode implements SwissCodec.
+func (SwissStringCodec[T]) Decode(b []byte) (T, error) {
+	return T(b), nil
+}
+
+// swissAnyStringCodec is SwissStringCodec for a type T of kind string.
+type swissAnyStringCodec[T any] struct{}
+
+func (swissAnyStringCodec[T]) Append(b []byte, v T) ([]byte, error) {
+	return append(b, *(*string)(unsafe.Pointer(&v))...), nil
+}
+
+func (swissAnyStringCodec[T]) Decode(b []byte) (v T, err error) {
+	*(*string)(unsafe.Pointer(&v)) = string(b)
+	return
+}
+
+// swissBinaryCodec encodes a type T whose values
+// implement encoding.BinaryMarshaler, and whose
+// pointers implement encoding.BinaryUnmarshaler.
+type swissBinaryCodec[T any] struct{}
+
+func (swissBinaryCodec[T]) Append(b []byte, v T) ([]byte, error) {
+	buf, err := any(v).(encoding.BinaryMarshaler).MarshalBinary()
+	return append(b, buf...), err
+}
+
+func (swissBinaryCodec[T]) Decode(b []byte) (v T, err error) {
+	err = any(&v).(encoding.BinaryUnmarshaler).UnmarshalBinary(b)
+	return
+}
+
+// swissDefaultCodec returns the SwissCodec used for T when none is given:
+// SwissRawCodec if T contains no pointers, a string codec if T is of kind
+// string, or the methods of encoding.BinaryMarshaler and
+// encoding.BinaryUnmarshaler if T implements them.
+func swissDefaultCodec[T any]() (SwissCodec[T], error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	switch {
+	case !swissTypeHasPointers(t):
+		return SwissRawCodec[T]{}, nil
+	case t.Kind() == reflect.String:
+		return swissAnyStringCodec[T]{}, nil
+	case t.Implements(swissBinaryMarshalerType) && reflect.PointerTo(t).Implements(swissBinaryUnmarshalerType):
+		return swissBinaryCodec[T]{}, nil
+	default:
+		return nil, fmt.Errorf("zend: no default SwissCodec for %v", t)
+	}
+}
+
+var (
+	swissBinaryMarshalerType   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	swissBinaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
@@ -0,0 +1,289 @@
+package zend
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// ConcurrentMap is a SwissMap that is safe for concurrent use by multiple
+// goroutines. Like a flagLargeMap, it routes each key by the uint8(swissH1)
+// suffix of its hash into one of 256 SwissSub tables, and each table is
+// protected by its own lock, so operations on different tables never
+// contend. Its methods mirror those of sync.Map.
+type ConcurrentMap[K comparable, V any] struct {
+	hash   Hasher[K]
+	ptrs   bool
+	shards [splitSubMapSize]swissShard[K, V]
+}
+
+// swissShard is a SwissSub guarded by a lock, padded
+// to keep neighbouring locks out of the same cache line.
+type swissShard[K comparable, V any] struct {
+	sync.RWMutex
+	SwissSub[K, V]
+	_ [64]byte
+}
+
+// NewConcurrentMap constructs a ConcurrentMap with room for |sz| elements.
+//
+//goland:noinspection GoUnusedExportedFunction
+func NewConcurrentMap[K comparable, V any](sz uint32) *ConcurrentMap[K, V] {
+	m := &ConcurrentMap[K, V]{
+		hash: NewHasher[K](),
+		ptrs: swissHasPointers[K]() || swissHasPointers[V](),
+	}
+	groupn := swissNumGroups(sz >> 8) // swissNumGroups(sz / splitSubMapSize)
+	for sdx := range m.shards {
+		m.shards[sdx].reset(groupn)
+	}
+	return m
+}
+
+// Load returns the value mapped by |key| if one exists.
+func (m *ConcurrentMap[K, V]) Load(key K) (value V, ok bool) {
+	hi, lo := swissSplitHash(m.hash.Hash64(key))
+	sh := &m.shards[uint8(hi)]
+	sh.RLock()
+	if g, s, found := sh.find(key, hi, lo); found {
+		value, ok = sh.groups[g].values[s], true
+	}
+	sh.RUnlock()
+	return
+}
+
+// Store maps |key| to |value|.
+func (m *ConcurrentMap[K, V]) Store(key K, value V) {
+	m.Swap(key, value)
+}
+
+// Swap maps |key| to |value| and returns the previous value if any.
+// |loaded| is true if |key| was present.
+func (m *ConcurrentMap[K, V]) Swap(key K, value V) (previous V, loaded bool) {
+	hi, lo := swissSplitHash(m.hash.Hash64(key))
+	sh := &m.shards[uint8(hi)]
+	sh.Lock()
+	g, s, ok := sh.find(key, hi, lo)
+	if ok {
+		previous, loaded = sh.groups[g].values[s], true
+		sh.groups[g].values[s] = value
+	} else {
+		m.insert(sh, key, value, g, s, hi, lo)
+	}
+	sh.Unlock()
+	return
+}
+
+// LoadOrStore returns the value mapped by |key| if one exists. Otherwise,
+// it maps |key| to |value| and returns it. |loaded| is true if |key| was present.
+func (m *ConcurrentMap[K, V]) LoadOrStore(key K, value V) (actual V, loaded bool) {
+	hi, lo := swissSplitHash(m.hash.Hash64(key))
+	sh := &m.shards[uint8(hi)]
+	sh.Lock()
+	g, s, ok := sh.find(key, hi, lo)
+	if ok {
+		actual, loaded = sh.groups[g].values[s], true
+	} else {
+		m.insert(sh, key, value, g, s, hi, lo)
+		actual = value
+	}
+	sh.Unlock()
+	return
+}
+
+// LoadAndDelete removes |key| and returns its previous value if any.
+// |loaded| is true if |key| was present.
+func (m *ConcurrentMap[K, V]) LoadAndDelete(key K) (value V, loaded bool) {
+	hi, lo := swissSplitHash(m.hash.Hash64(key))
+	sh := &m.shards[uint8(hi)]
+	sh.Lock()
+	if g, s, ok := sh.find(key, hi, lo); ok {
+		value, loaded = sh.groups[g].values[s], true
+		sh.deleteAt(g, s, m.ptrs)
+	}
+	sh.Unlock()
+	return
+}
+
+// Delete removes |key|.
+func (m *ConcurrentMap[K, V]) Delete(key K) {
+	m.LoadAndDelete(key)
+}
+
+// CompareAndSwap maps |key| to |new| if it is currently mapped to |old|.
+// As with sync.Map, the values are compared with == and V must be
+// comparable at run time, or CompareAndSwap panics.
+func (m *ConcurrentMap[K, V]) CompareAndSwap(key K, old, new V) (swapped bool) {
+	hi, lo := swissSplitHash(m.hash.Hash64(key))
+	sh := &m.shards[uint8(hi)]
+	sh.Lock()
+	defer sh.Unlock()
+	g, s, ok := sh.find(key, hi, lo)
+	if !ok || any(sh.groups[g].values[s]) != any(old) {
+		return false
+	}
+	sh.groups[g].values[s] = new
+	return true
+}
+
+// CompareAndDelete removes |key| if it is currently mapped to |old|.
+// Values are compared as in CompareAndSwap.
+func (m *ConcurrentMap[K, V]) CompareAndDelete(key K, old V) (deleted bool) {
+	hi, lo := swissSplitHash(m.hash.Hash64(key))
+	sh := &m.shards[uint8(hi)]
+	sh.Lock()
+	defer sh.Unlock()
+	g, s, ok := sh.find(key, hi, lo)
+	if !ok || any(sh.groups[g].values[s]) != any(old) {
+		return false
+	}
+	sh.deleteAt(g, s, m.ptrs)
+	return true
+}
+
+// Range calls |f| for each key and value in the ConcurrentMap, stopping
+// if |f| returns false. As with sync.Map, Range does not correspond to a
+// consistent snapshot: each sub table is copied under its lock and then
+// visited without it, so |f| may modify the ConcurrentMap.
+func (m *ConcurrentMap[K, V]) Range(f func(key K, value V) bool) {
+	var (
+		keys   []K
+		values []V
+	)
+	for sdx := range m.shards {
+		sh := &m.shards[sdx]
+		keys, values = keys[:0], values[:0]
+		sh.RLock()
+		for g := range sh.groups {
+			meta := (*swissMetadata)(unsafe.Pointer(_u64(sh.ctrl, uint32(g))))
+			for s, c := range *meta {
+				if c == swissEmpty || c == swissTombstone {
+					continue
+				}
+				keys = append(keys, sh.groups[g].keys[s])
+				values = append(values, sh.groups[g].values[s])
+			}
+		}
+		sh.RUnlock()
+		for i := range keys {
+			if !f(keys[i], values[i]) {
+				return
+			}
+		}
+	}
+}
+
+// Clear removes all elements from the ConcurrentMap.
+func (m *ConcurrentMap[K, V]) Clear() {
+	for sdx := range m.shards {
+		sh := &m.shards[sdx]
+		sh.Lock()
+		groupm := uint32(len(sh.groups)) << 3
+		for i := uintptr(0); i < uintptr(groupm); i += 8 {
+			*(*uint64)(unsafe.Pointer(uintptr(unsafe.Pointer(sh.ctrl)) + i)) = swissEmpty64
+		}
+		if m.ptrs {
+			sh.clearGroups()
+		}
+		sh.resident, sh.dead = 0, 0
+		sh.Unlock()
+	}
+}
+
+// Count returns the number of elements in the ConcurrentMap.
+// Concurrent modifications may or may not be counted.
+func (m *ConcurrentMap[K, V]) Count() int {
+	n := 0
+	for sdx := range m.shards {
+		sh := &m.shards[sdx]
+		sh.RLock()
+		n += int(sh.resident - sh.dead)
+		sh.RUnlock()
+	}
+	return n
+}
+
+// insert stores |key| and |value| in |sh| at the insertion location |g|, |s|
+// returned by find, rehashing first if the table is full. |sh| must be locked.
+func (m *ConcurrentMap[K, V]) insert(sh *swissShard[K, V], key K, value V, g, s uint32, hi swissH1, lo swissH2) {
+	if sh.resident >= sh.limit {
+		n := uint32(len(sh.groups)) * 2
+		if sh.dead >= (sh.resident / 2) {
+			n = uint32(len(sh.groups))
+		}
+		sh.rehash(m.hash, n)
+		g, s, _ = sh.find(key, hi, lo)
+	}
+	sh.groups[g].keys[s] = key
+	sh.groups[g].values[s] = value
+	*_i8(_u64(sh.ctrl, g), s) = int8(lo) // sh.ctrl[g][s]
+	sh.resident++
+}
+
+// find returns the location of |key| in |t| if present, or its insertion
+// location if absent.
+func (t *SwissSub[K, V]) find(key K, hi swissH1, lo swissH2) (g, s uint32, ok bool) {
+	size := uint32(len(t.groups))
+	g = swissProbeStart(hi, size)
+	for {
+		meta := _u64(t.ctrl, g)
+		matches := swissMetaMatchH2(meta, lo)
+		for matches != 0 {
+			s = swissNextMatch(&matches)
+			if key == t.groups[g].keys[s] {
+				return g, s, true
+			}
+		}
+		// |key| is not in swissGroup |g|,
+		// stop probing if we see an swissEmpty slot
+		matches = swissMetaMatchEmpty(meta)
+		if matches != 0 {
+			s = swissNextMatch(&matches)
+			return g, s, false
+		}
+		g += 1 // linear probing
+		if g >= size {
+			g = 0
+		}
+	}
+}
+
+// deleteAt removes the element stored in slot |s| of swissGroup |g|,
+// releasing its key and value if |ptrs| is set.
+func (t *SwissSub[K, V]) deleteAt(g, s uint32, ptrs bool) {
+	meta := _u64(t.ctrl, g)
+	// see SwissMap.Delete
+	if swissMetaMatchEmpty(meta) != 0 {
+		*_i8(meta, s) = swissEmpty // t.ctrl[g][s]
+		t.resident--
+	} else {
+		*_i8(meta, s) = swissTombstone // t.ctrl[g][s]
+		t.dead++
+	}
+	if ptrs {
+		var k K
+		var v V
+		t.groups[g].keys[s], t.groups[g].values[s] = k, v
+	}
+}
+
+// rehash moves the elements of |t| into a table of |groupn| swissGroups,
+// hashing keys with |hash|.
+func (t *SwissSub[K, V]) rehash(hash Hasher[K], groupn uint32) {
+	ctrl, groups := t.ctrl, t.groups
+	t.reset(groupn)
+	for g := range groups {
+		meta := (*swissMetadata)(unsafe.Pointer(_u64(ctrl, uint32(g))))
+		for s, c := range *meta {
+			if c == swissEmpty || c == swissTombstone {
+				continue
+			}
+			hi, lo := swissSplitHash(hash.Hash64(groups[g].keys[s]))
+			// |t| holds no other copy of the key, so find returns an insertion location
+			g2, s2, _ := t.find(groups[g].keys[s], hi, lo)
+			t.groups[g2].keys[s2] = groups[g].keys[s]
+			t.groups[g2].values[s2] = groups[g].values[s]
+			*_i8(_u64(t.ctrl, g2), s2) = int8(lo)
+			t.resident++
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package zend
+
+import (
+	"math/rand"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrentMap(t *testing.T) {
+	keys := genSwissStringData(8, 10_000)
+	m := NewConcurrentMap[string, int](0)
+	for i, k := range keys {
+		m.Store(k, i)
+	}
+	assert.Equal(t, len(keys), m.Count())
+	for i, k := range keys {
+		v, ok := m.Load(k)
+		assert.True(t, ok)
+		assert.Equal(t, i, v)
+	}
+
+	act, loaded := m.LoadOrStore(keys[0], -1)
+	assert.True(t, loaded)
+	assert.Equal(t, 0, act)
+	prev, loaded := m.Swap(keys[0], -1)
+	assert.True(t, loaded)
+	assert.Equal(t, 0, prev)
+	assert.False(t, m.CompareAndSwap(keys[0], 0, 1))
+	assert.True(t, m.CompareAndSwap(keys[0], -1, 1))
+	assert.False(t, m.CompareAndDelete(keys[0], -1))
+	assert.True(t, m.CompareAndDelete(keys[0], 1))
+	_, ok := m.Load(keys[0])
+	assert.False(t, ok)
+	assert.False(t, m.CompareAndSwap(keys[0], 1, 2))
+
+	v, loaded := m.LoadAndDelete(keys[1])
+	assert.True(t, loaded)
+	assert.Equal(t, 1, v)
+	_, loaded = m.LoadAndDelete(keys[1])
+	assert.False(t, loaded)
+	m.Delete(keys[2])
+	assert.Equal(t, len(keys)-3, m.Count())
+
+	seen := make(map[string]int)
+	m.Range(func(k string, v int) bool {
+		seen[k] = v
+		// modifying the map from Range must not deadlock
+		m.Store(k, v+1)
+		return true
+	})
+	assert.Equal(t, len(keys)-3, len(seen))
+	for i, k := range keys[3:] {
+		assert.Equal(t, i+3, seen[k])
+		v, _ = m.Load(k)
+		assert.Equal(t, i+4, v)
+	}
+	n := 0
+	m.Range(func(string, int) bool {
+		n++
+		return n < 10
+	})
+	assert.Equal(t, 10, n)
+
+	m.Clear()
+	assert.Equal(t, 0, m.Count())
+	_, ok = m.Load(keys[3])
+	assert.False(t, ok)
+}
+
+func TestConcurrentMapCompareAndSwapPanics(t *testing.T) {
+	m := NewConcurrentMap[int, any](0)
+	m.Store(1, []int{1})
+	assert.Panics(t, func() {
+		m.CompareAndSwap(1, []int{1}, 2)
+	})
+}
+
+// TestConcurrentMapRace is intended to be run with -race.
+func TestConcurrentMapRace(t *testing.T) {
+	const n = 4096
+	workers := runtime.GOMAXPROCS(0) * 2
+	if workers < 4 {
+		workers = 4
+	}
+	m := NewConcurrentMap[int, int](0)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(w)))
+			for i := 0; i < n; i++ {
+				k := r.Intn(n)
+				switch r.Intn(6) {
+				case 0:
+					m.Store(k, k)
+				case 1:
+					if v, ok := m.Load(k); ok {
+						assert.Equal(t, k, v)
+					}
+				case 2:
+					if v, ok := m.LoadOrStore(k, k); ok {
+						assert.Equal(t, k, v)
+					}
+				case 3:
+					m.CompareAndSwap(k, k, k)
+				case 4:
+					m.Delete(k)
+				case 5:
+					m.Range(func(k, v int) bool {
+						assert.Equal(t, k, v)
+						return k%8 != 0
+					})
+				}
+			}
+		}(w)
+	}
+	// each worker owns a disjoint key range
+	// to check that no write is lost
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				m.Store(n*(w+1)+i, n*(w+1)+i)
+			}
+		}(w)
+	}
+	wg.Wait()
+	for w := 0; w < workers; w++ {
+		for i := 0; i < n; i++ {
+			k := n*(w+1) + i
+			v, ok := m.Load(k)
+			assert.True(t, ok)
+			assert.Equal(t, k, v)
+		}
+	}
+}
+
+func BenchmarkConcurrentMap(b *testing.B) {
+	const n = 1 << 16
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	for _, writes := range []int{0, 10, 50} {
+		b.Run("writes="+strconv.Itoa(writes)+"%", func(b *testing.B) {
+			b.Run("sync.Map", func(b *testing.B) {
+				var m sync.Map
+				for i, k := range keys {
+					m.Store(k, i)
+				}
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					i := rand.Intn(n)
+					for pb.Next() {
+						if i%100 < writes {
+							m.Store(keys[i&(n-1)], i)
+						} else {
+							m.Load(keys[i&(n-1)])
+						}
+						i++
+					}
+				})
+			})
+			b.Run("mutex SwissMap", func(b *testing.B) {
+				var mu sync.Mutex
+				m := NewSwissMap[string, int](n)
+				for i, k := range keys {
+					m.Put(k, i)
+				}
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					i := rand.Intn(n)
+					for pb.Next() {
+						mu.Lock()
+						if i%100 < writes {
+							m.Put(keys[i&(n-1)], i)
+						} else {
+							m.Get(keys[i&(n-1)])
+						}
+						mu.Unlock()
+						i++
+					}
+				})
+			})
+			b.Run("ConcurrentMap", func(b *testing.B) {
+				m := NewConcurrentMap[string, int](n)
+				for i, k := range keys {
+					m.Store(k, i)
+				}
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					i := rand.Intn(n)
+					for pb.Next() {
+						if i%100 < writes {
+							m.Store(keys[i&(n-1)], i)
+						} else {
+							m.Load(keys[i&(n-1)])
+						}
+						i++
+					}
+				})
+			})
+		})
+	}
+}
@@ -0,0 +1,477 @@
+package zend
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"unsafe"
+)
+
+// The binary format of a SwissMap is that of swiss.Map, so that either
+// can load the encoding of the other. It is a header, followed by the
+// elements and a trailer. Integers are little-endian. The header is:
+//
+//	magic      [4]byte  "SWSM"
+//	version    uint16   swissFormatVersion
+//	width      uint8    group width of the encoded table
+//	flags      uint8    see swissFlag* below
+//	keySize    uint32   size of raw keys, or 0
+//	valueSize  uint32   size of raw values, or 0
+//	count      uint64   number of elements
+//	checksum   uint32   CRC-32C of the preceding fields
+//
+// Each element is its key followed by its value. Raw keys and values take
+// keySize and valueSize bytes, others are prefixed by their length as a
+// uvarint. The trailer is the CRC-32C of the elements as a uint32.
+
+const (
+	swissFormatVersion = 1
+	swissHeaderSize    = 28
+
+	swissFlagRawKeys   = 1 << 0
+	swissFlagRawValues = 1 << 1
+	// swissFlagBigEndian is set if raw
+	// keys and values are big-endian
+	swissFlagBigEndian = 1 << 2
+)
+
+var (
+	swissFormatMagic = [4]byte{'S', 'W', 'S', 'M'}
+	swissCrcTable    = crc32.MakeTable(crc32.Castagnoli)
+
+	// ErrSwissCorrupt is returned when decoding malformed or corrupted data.
+	ErrSwissCorrupt = errors.New("zend: corrupt encoding")
+)
+
+// SwissFormat encodes and decodes SwissMaps using the SwissCodecs |Key| and
+// |Value|. A nil SwissCodec is replaced by the default SwissCodec of its
+// type: SwissRawCodec if the type contains no pointers, a SwissStringCodec
+// if it is a string type, or its MarshalBinary and UnmarshalBinary methods
+// if it has them.
+type SwissFormat[K comparable, V any] struct {
+	Key   SwissCodec[K]
+	Value SwissCodec[V]
+}
+
+// WriteMap writes the binary encoding of |m| to |w|.
+func (f SwissFormat[K, V]) WriteMap(w io.Writer, m *SwissMap[K, V]) (int64, error) {
+	enc, err := newSwissEncoder(f, w)
+	if err != nil {
+		return 0, err
+	}
+	enc.header(swissGroupSize, m.Count())
+	if m.flags == flagLargeMap {
+		lm := (*SwissLarge[K, V])(unsafe.Pointer(m))
+		for sdx := range lm.subs {
+			enc.sub(&lm.subs[sdx])
+		}
+	} else {
+		enc.sub(&m.SwissSub)
+	}
+	return enc.finish()
+}
+
+// ReadMap replaces the contents of |m| with the binary encoding read from
+// |r|. Tables are sized to hold every element before they are inserted,
+// so they are not rehashed during the load. A flagLargeMap decodes every
+// element before inserting them, in order to size each sub table by its
+// share of the elements. |m| may be a zero SwissMap.
+func (f SwissFormat[K, V]) ReadMap(r io.Reader, m *SwissMap[K, V]) (int64, error) {
+	dec, err := newSwissDecoder(f, r)
+	if err != nil {
+		return 0, err
+	}
+	count, err := dec.header()
+	if err != nil {
+		return dec.n, err
+	}
+	if m.flags != flagLargeMap && m.ctrl == nil {
+		*m = *NewSwissMap[K, V](0)
+	}
+	if m.flags != flagLargeMap {
+		m.Reset(count)
+		var (
+			k K
+			v V
+		)
+		for i := 0; i < count; i++ {
+			if err = dec.element(&k, &v); err != nil {
+				m.Clear()
+				return dec.n, err
+			}
+			m.Put(k, v)
+		}
+		if err = dec.finish(); err != nil {
+			m.Clear()
+		}
+		return dec.n, err
+	}
+
+	type element struct {
+		key   K
+		value V
+		hash  uint64
+	}
+	var (
+		elems  = make([]element, 0, swissMinInt(count, 1<<16))
+		shares [splitSubMapSize]uint32
+	)
+	for i := 0; i < count; i++ {
+		var e element
+		if err = dec.element(&e.key, &e.value); err != nil {
+			m.Clear()
+			return dec.n, err
+		}
+		e.hash = m.hash.Hash64(e.key)
+		hi, _ := swissSplitHash(e.hash)
+		shares[uint8(hi)]++
+		elems = append(elems, e)
+	}
+	if err = dec.finish(); err != nil {
+		m.Clear()
+		return dec.n, err
+	}
+	lm := (*SwissLarge[K, V])(unsafe.Pointer(m))
+	for sdx := range lm.subs {
+		lm.subs[sdx].reset(swissNumGroups(shares[sdx]))
+	}
+	for _, e := range elems {
+		hi, lo := swissSplitHash(e.hash)
+		i, g, s, ok := m.find(e.key, hi, lo)
+		if ok {
+			m.sub(i).groups[g].values[s] = e.value
+		} else {
+			m.insert(e.key, e.value, i, g, s, hi, lo)
+		}
+	}
+	return dec.n, nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler using the default SwissCodecs.
+func (m *SwissMap[K, V]) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := SwissFormat[K, V]{}.WriteMap(&buf, m)
+	return buf.Bytes(), err
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler using the default SwissCodecs.
+func (m *SwissMap[K, V]) UnmarshalBinary(data []byte) error {
+	n, err := SwissFormat[K, V]{}.ReadMap(bytes.NewReader(data), m)
+	if err == nil && n != int64(len(data)) {
+		err = fmt.Errorf("%w: %d trailing bytes", ErrSwissCorrupt, int64(len(data))-n)
+		m.Clear()
+	}
+	return err
+}
+
+// WriteTo implements io.WriterTo using the default SwissCodecs.
+func (m *SwissMap[K, V]) WriteTo(w io.Writer) (int64, error) {
+	return SwissFormat[K, V]{}.WriteMap(w, m)
+}
+
+// ReadFrom implements io.ReaderFrom using the default SwissCodecs. It
+// reads a single encoded SwissMap, and may buffer data beyond its end.
+func (m *SwissMap[K, V]) ReadFrom(r io.Reader) (int64, error) {
+	return SwissFormat[K, V]{}.ReadMap(r, m)
+}
+
+// codecs resolves the SwissCodecs of |f|, returning
+// the sizes of raw keys and values, or -1 if not raw.
+func (f SwissFormat[K, V]) codecs() (kc SwissCodec[K], vc SwissCodec[V], ksz, vsz int, err error) {
+	if kc = f.Key; kc == nil {
+		if kc, err = swissDefaultCodec[K](); err != nil {
+			return
+		}
+	}
+	if vc = f.Value; vc == nil {
+		if vc, err = swissDefaultCodec[V](); err != nil {
+			return
+		}
+	}
+	ksz, vsz = -1, -1
+	if fc, ok := kc.(swissFixedCodec); ok {
+		if swissHasPointers[K]() {
+			return nil, nil, 0, 0, swissRawCodecError[K]()
+		}
+		ksz = fc.fixedSize()
+	}
+	if fc, ok := vc.(swissFixedCodec); ok {
+		if swissHasPointers[V]() {
+			return nil, nil, 0, 0, swissRawCodecError[V]()
+		}
+		vsz = fc.fixedSize()
+	}
+	return
+}
+
+type swissEncoder[K comparable, V any] struct {
+	kc       SwissCodec[K]
+	vc       SwissCodec[V]
+	ksz, vsz int
+	w        *bufio.Writer
+	buf      []byte
+	crc      uint32
+	n        int64
+	err      error
+}
+
+func newSwissEncoder[K comparable, V any](f SwissFormat[K, V], w io.Writer) (*swissEncoder[K, V], error) {
+	kc, vc, ksz, vsz, err := f.codecs()
+	if err != nil {
+		return nil, err
+	}
+	return &swissEncoder[K, V]{
+		kc: kc, vc: vc,
+		ksz: ksz, vsz: vsz,
+		w: bufio.NewWriter(w),
+	}, nil
+}
+
+func (e *swissEncoder[K, V]) header(width, count int) {
+	var flags uint8
+	var ksz, vsz uint32
+	if e.ksz >= 0 {
+		flags |= swissFlagRawKeys
+		ksz = uint32(e.ksz)
+	}
+	if e.vsz >= 0 {
+		flags |= swissFlagRawValues
+		vsz = uint32(e.vsz)
+	}
+	if swissNativeBigEndian() {
+		flags |= swissFlagBigEndian
+	}
+	var h [swissHeaderSize]byte
+	copy(h[:4], swissFormatMagic[:])
+	binary.LittleEndian.PutUint16(h[4:], swissFormatVersion)
+	h[6], h[7] = uint8(width), flags
+	binary.LittleEndian.PutUint32(h[8:], ksz)
+	binary.LittleEndian.PutUint32(h[12:], vsz)
+	binary.LittleEndian.PutUint64(h[16:], uint64(count))
+	binary.LittleEndian.PutUint32(h[24:], crc32.Checksum(h[:24], swissCrcTable))
+	e.write(h[:])
+	// the payload checksum starts after the header
+	e.crc = 0
+}
+
+// sub writes the elements of table |t|.
+func (e *swissEncoder[K, V]) sub(t *SwissSub[K, V]) {
+	for g := range t.groups {
+		meta := (*swissMetadata)(unsafe.Pointer(_u64(t.ctrl, uint32(g))))
+		for s, c := range *meta {
+			if c == swissEmpty || c == swissTombstone {
+				continue
+			}
+			e.element(&t.groups[g].keys[s], &t.groups[g].values[s])
+		}
+	}
+}
+
+func (e *swissEncoder[K, V]) element(k *K, v *V) {
+	if e.err != nil {
+		return
+	}
+	if e.ksz >= 0 {
+		e.write(swissRawBytes(k))
+	} else {
+		e.buf, e.err = e.kc.Append(e.buf[:0], *k)
+		e.writePrefixed()
+	}
+	if e.vsz >= 0 {
+		e.write(swissRawBytes(v))
+	} else if e.err == nil {
+		e.buf, e.err = e.vc.Append(e.buf[:0], *v)
+		e.writePrefixed()
+	}
+}
+
+func (e *swissEncoder[K, V]) writePrefixed() {
+	if e.err != nil {
+		return
+	}
+	var p [binary.MaxVarintLen64]byte
+	e.write(p[:binary.PutUvarint(p[:], uint64(len(e.buf)))])
+	e.write(e.buf)
+}
+
+func (e *swissEncoder[K, V]) write(p []byte) {
+	if e.err != nil {
+		return
+	}
+	var n int
+	n, e.err = e.w.Write(p)
+	e.n += int64(n)
+	e.crc = crc32.Update(e.crc, swissCrcTable, p)
+}
+
+func (e *swissEncoder[K, V]) finish() (int64, error) {
+	var t [4]byte
+	binary.LittleEndian.PutUint32(t[:], e.crc)
+	e.write(t[:])
+	if e.err == nil {
+		e.err = e.w.Flush()
+	}
+	return e.n, e.err
+}
+
+type swissDecoder[K comparable, V any] struct {
+	kc       SwissCodec[K]
+	vc       SwissCodec[V]
+	ksz, vsz int
+	r        *bufio.Reader
+	buf      []byte
+	crc      uint32
+	n        int64
+}
+
+func newSwissDecoder[K comparable, V any](f SwissFormat[K, V], r io.Reader) (*swissDecoder[K, V], error) {
+	kc, vc, ksz, vsz, err := f.codecs()
+	if err != nil {
+		return nil, err
+	}
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &swissDecoder[K, V]{
+		kc: kc, vc: vc,
+		ksz: ksz, vsz: vsz,
+		r: br,
+	}, nil
+}
+
+// header reads and validates the header, returning the element count.
+func (d *swissDecoder[K, V]) header() (int, error) {
+	h, err := d.read(swissHeaderSize)
+	if err != nil {
+		return 0, err
+	}
+	if !bytes.Equal(h[:4], swissFormatMagic[:]) {
+		return 0, fmt.Errorf("%w: bad magic %q", ErrSwissCorrupt, h[:4])
+	}
+	if crc32.Checksum(h[:24], swissCrcTable) != binary.LittleEndian.Uint32(h[24:]) {
+		return 0, fmt.Errorf("%w: header checksum mismatch", ErrSwissCorrupt)
+	}
+	if v := binary.LittleEndian.Uint16(h[4:]); v != swissFormatVersion {
+		return 0, fmt.Errorf("zend: unsupported format version %d", v)
+	}
+	flags := h[7]
+	ksz, vsz := binary.LittleEndian.Uint32(h[8:]), binary.LittleEndian.Uint32(h[12:])
+	count := binary.LittleEndian.Uint64(h[16:])
+	if raw := flags&swissFlagRawKeys != 0; raw != (d.ksz >= 0) || (raw && int(ksz) != d.ksz) {
+		return 0, fmt.Errorf("zend: key SwissCodec does not match encoding")
+	}
+	if raw := flags&swissFlagRawValues != 0; raw != (d.vsz >= 0) || (raw && int(vsz) != d.vsz) {
+		return 0, fmt.Errorf("zend: value SwissCodec does not match encoding")
+	}
+	if flags&(swissFlagRawKeys|swissFlagRawValues) != 0 && (flags&swissFlagBigEndian != 0) != swissNativeBigEndian() {
+		return 0, fmt.Errorf("zend: raw encoding has foreign byte order")
+	}
+	if count > math.MaxUint32-swissMaxAvgGroupLoad {
+		return 0, fmt.Errorf("%w: count %d out of range", ErrSwissCorrupt, count)
+	}
+	// the payload checksum starts after the header
+	d.crc = 0
+	return int(count), nil
+}
+
+func (d *swissDecoder[K, V]) element(k *K, v *V) (err error) {
+	var b []byte
+	if d.ksz >= 0 {
+		if b, err = d.read(d.ksz); err != nil {
+			return err
+		}
+		copy(swissRawBytes(k), b)
+	} else {
+		if b, err = d.readPrefixed(); err != nil {
+			return err
+		}
+		if *k, err = d.kc.Decode(b); err != nil {
+			return err
+		}
+	}
+	if d.vsz >= 0 {
+		if b, err = d.read(d.vsz); err != nil {
+			return err
+		}
+		copy(swissRawBytes(v), b)
+	} else {
+		if b, err = d.readPrefixed(); err != nil {
+			return err
+		}
+		if *v, err = d.vc.Decode(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *swissDecoder[K, V]) readPrefixed() ([]byte, error) {
+	var (
+		x     uint64
+		shift uint
+	)
+	for i := 0; ; i++ {
+		b, err := d.read(1)
+		if err != nil {
+			return nil, err
+		}
+		if i == binary.MaxVarintLen64 {
+			return nil, fmt.Errorf("%w: length overflow", ErrSwissCorrupt)
+		}
+		if b[0] < 0x80 {
+			x |= uint64(b[0]) << shift
+			break
+		}
+		x |= uint64(b[0]&0x7f) << shift
+		shift += 7
+	}
+	if x > math.MaxInt32 {
+		return nil, fmt.Errorf("%w: length %d out of range", ErrSwissCorrupt, x)
+	}
+	return d.read(int(x))
+}
+
+// read returns the next |n| bytes, which are valid until the next call.
+func (d *swissDecoder[K, V]) read(n int) ([]byte, error) {
+	if cap(d.buf) < n {
+		d.buf = make([]byte, n)
+	}
+	b := d.buf[:n]
+	m, err := io.ReadFull(d.r, b)
+	d.n += int64(m)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = fmt.Errorf("%w: %v", ErrSwissCorrupt, io.ErrUnexpectedEOF)
+	}
+	d.crc = crc32.Update(d.crc, swissCrcTable, b[:m])
+	return b, err
+}
+
+func (d *swissDecoder[K, V]) finish() error {
+	crc := d.crc
+	t, err := d.read(4)
+	if err != nil {
+		return err
+	}
+	if binary.LittleEndian.Uint32(t) != crc {
+		return fmt.Errorf("%w: checksum mismatch", ErrSwissCorrupt)
+	}
+	return nil
+}
+
+func swissNativeBigEndian() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 0
+}
+
+func swissMinInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,93 @@
+package zend
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSwissBinaryEncoding(t *testing.T) {
+	t.Run("small", func(t *testing.T) {
+		type point struct {
+			x, y int16
+			z    int64
+		}
+		m := NewSwissMap[uint32, point](0)
+		for i, k := range genSwissUint32Data(1000) {
+			m.Put(k, point{x: int16(i), y: -int16(i), z: int64(k)})
+		}
+		data, err := m.MarshalBinary()
+		require.NoError(t, err)
+		assert.Equal(t, swissHeaderSize+1000*(4+16)+4, len(data))
+
+		var act SwissMap[uint32, point]
+		require.NoError(t, act.UnmarshalBinary(data))
+		assertSwissMapsEqual(t, m, &act)
+		assert.Equal(t, int(swissNumGroups(1000)), len(act.groups))
+	})
+	t.Run("large", func(t *testing.T) {
+		keys := genSwissStringData(8, int(splitSubMapLimit)+1000)
+		m := NewSwissMap[string, int](splitSubMapLimit)
+		for i, k := range keys {
+			m.Put(k, i)
+		}
+		var buf bytes.Buffer
+		n, err := m.WriteTo(&buf)
+		require.NoError(t, err)
+		assert.Equal(t, int64(buf.Len()), n)
+
+		act := NewSwissMap[string, int](splitSubMapLimit)
+		act.Put("stale", -1)
+		_, err = act.ReadFrom(&buf)
+		require.NoError(t, err)
+		assertSwissMapsEqual(t, m, act)
+		assert.False(t, act.Has("stale"))
+
+		// a small SwissMap loads a large encoding
+		data, err := m.MarshalBinary()
+		require.NoError(t, err)
+		var small SwissMap[string, int]
+		require.NoError(t, small.UnmarshalBinary(data))
+		assertSwissMapsEqual(t, m, &small)
+	})
+	t.Run("no default codec", func(t *testing.T) {
+		_, err := NewSwissMap[string, []int](0).MarshalBinary()
+		assert.Error(t, err)
+	})
+}
+
+func TestSwissBinaryEncodingCorrupt(t *testing.T) {
+	m := NewSwissMap[string, int64](0)
+	for i, k := range genSwissStringData(8, 100) {
+		m.Put(k, int64(i))
+	}
+	data, err := m.MarshalBinary()
+	require.NoError(t, err)
+
+	for name, corrupt := range map[string][]byte{
+		"truncated":  data[:len(data)-10],
+		"bit flip":   append(append([]byte(nil), data[:swissHeaderSize+20]...), append([]byte{data[swissHeaderSize+20] ^ 1}, data[swissHeaderSize+21:]...)...),
+		"trailing":   append(append([]byte(nil), data...), 0),
+		"bad header": data[:swissHeaderSize-1],
+	} {
+		t.Run(name, func(t *testing.T) {
+			var act SwissMap[string, int64]
+			err := act.UnmarshalBinary(corrupt)
+			assert.True(t, errors.Is(err, ErrSwissCorrupt), err)
+			assert.Equal(t, 0, act.Count())
+		})
+	}
+}
+
+func assertSwissMapsEqual[K comparable, V any](t *testing.T, exp, act *SwissMap[K, V]) {
+	assert.Equal(t, exp.Count(), act.Count())
+	exp.Iter(func(k K, v V) (stop bool) {
+		a, ok := act.Get(k)
+		assert.True(t, ok)
+		assert.Equal(t, v, a)
+		return
+	})
+}
@@ -0,0 +1,132 @@
+package zend
+
+// GetOrPut returns the value mapped by |key| if one exists. Otherwise,
+// it inserts |value| and returns it. |loaded| is true if |key| was present.
+// |key| is hashed once and the table is probed once.
+func (m *SwissMap[K, V]) GetOrPut(key K, value V) (actual V, loaded bool) {
+	hi, lo := swissSplitHash(m.hash.Hash64(key))
+	i, g, s, ok := m.find(key, hi, lo)
+	if ok {
+		return m.sub(i).groups[g].values[s], true
+	}
+	m.insert(key, value, i, g, s, hi, lo)
+	return value, false
+}
+
+// Compute calls |fn| with the value mapped by |key|, and whether |key| is
+// present. If |fn| returns keep = true, |key| is mapped to the returned
+// value, otherwise |key| is removed. Compute returns the resulting value
+// and whether |key| is present after the call. |fn| must not modify |m|.
+func (m *SwissMap[K, V]) Compute(key K, fn func(old V, ok bool) (v V, keep bool)) (V, bool) {
+	hi, lo := swissSplitHash(m.hash.Hash64(key))
+	i, g, s, ok := m.find(key, hi, lo)
+	var old V
+	if ok {
+		old = m.sub(i).groups[g].values[s]
+	}
+	v, keep := fn(old, ok)
+	switch {
+	case keep && ok:
+		m.sub(i).groups[g].values[s] = v
+	case keep:
+		m.insert(key, v, i, g, s, hi, lo)
+	case ok:
+		m.deleteAt(i, g, s)
+		if m.shrink {
+			m.shrinkIfSparse(i)
+		}
+	}
+	return v, keep
+}
+
+// SwissEntry is a handle to the slot of a key in a SwissMap, obtained with a
+// single hash and probe. The slot is either occupied by the key, or is the
+// location where the key will be inserted. A SwissEntry is invalidated by any
+// modification of the SwissMap not made through the SwissEntry itself.
+type SwissEntry[K comparable, V any] struct {
+	m     *SwissMap[K, V]
+	key   K
+	i     int32
+	g, s  uint32
+	hi    swissH1
+	lo    swissH2
+	found bool
+}
+
+// Entry returns the SwissEntry for |key| in |m|.
+func (m *SwissMap[K, V]) Entry(key K) SwissEntry[K, V] {
+	hi, lo := swissSplitHash(m.hash.Hash64(key))
+	i, g, s, ok := m.find(key, hi, lo)
+	return SwissEntry[K, V]{m: m, key: key, i: i, g: g, s: s, hi: hi, lo: lo, found: ok}
+}
+
+// Key returns the key of |e|.
+func (e SwissEntry[K, V]) Key() K {
+	return e.key
+}
+
+// Found returns true if the key of |e| is present in the SwissMap.
+func (e SwissEntry[K, V]) Found() bool {
+	return e.found
+}
+
+// Value returns the value mapped by the key of |e| if one exists.
+func (e SwissEntry[K, V]) Value() (value V, ok bool) {
+	if e.found {
+		value, ok = e.m.sub(e.i).groups[e.g].values[e.s], true
+	}
+	return
+}
+
+// Put maps the key of |e| to |value|, returning the updated SwissEntry.
+func (e SwissEntry[K, V]) Put(value V) SwissEntry[K, V] {
+	if e.found {
+		e.m.sub(e.i).groups[e.g].values[e.s] = value
+		return e
+	}
+	e.i, e.g, e.s = e.m.insert(e.key, value, e.i, e.g, e.s, e.hi, e.lo)
+	e.found = true
+	return e
+}
+
+// AndModify replaces the value mapped by the key of |e| with the
+// result of |fn| if the key is present, returning the SwissEntry.
+func (e SwissEntry[K, V]) AndModify(fn func(v V) V) SwissEntry[K, V] {
+	if e.found {
+		v := &e.m.sub(e.i).groups[e.g].values[e.s]
+		*v = fn(*v)
+	}
+	return e
+}
+
+// OrInsert inserts |value| if the key of |e| is absent.
+// It returns the value mapped by the key after the call.
+func (e SwissEntry[K, V]) OrInsert(value V) V {
+	if e.found {
+		return e.m.sub(e.i).groups[e.g].values[e.s]
+	}
+	e.m.insert(e.key, value, e.i, e.g, e.s, e.hi, e.lo)
+	return value
+}
+
+// OrInsertWith inserts the result of |fn| if the key of |e| is absent.
+// It returns the value mapped by the key after the call.
+func (e SwissEntry[K, V]) OrInsertWith(fn func() V) V {
+	if e.found {
+		return e.m.sub(e.i).groups[e.g].values[e.s]
+	}
+	value := fn()
+	e.m.insert(e.key, value, e.i, e.g, e.s, e.hi, e.lo)
+	return value
+}
+
+// Delete removes the key of |e| from the SwissMap, returning true if it was present.
+func (e SwissEntry[K, V]) Delete() bool {
+	if e.found {
+		e.m.deleteAt(e.i, e.g, e.s)
+		if e.m.shrink {
+			e.m.shrinkIfSparse(e.i)
+		}
+	}
+	return e.found
+}
@@ -0,0 +1,85 @@
+package zend
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSwissEntry(t *testing.T) {
+	t.Run("small", func(t *testing.T) {
+		testSwissEntry(t, 0, genSwissStringData(8, 1000))
+	})
+	t.Run("large", func(t *testing.T) {
+		testSwissEntry(t, splitSubMapLimit, genSwissStringData(8, 100_000))
+	})
+}
+
+func testSwissEntry(t *testing.T, sz uint32, keys []string) {
+	stream := make([]string, len(keys)*4)
+	golden := make(map[string]int, len(keys))
+	for i := range stream {
+		stream[i] = keys[rand.Intn(len(keys))]
+		golden[stream[i]]++
+	}
+	assertCounts := func(m *SwissMap[string, int]) {
+		assert.Equal(t, len(golden), m.Count())
+		for k, exp := range golden {
+			act, ok := m.Get(k)
+			assert.True(t, ok)
+			assert.Equal(t, exp, act)
+		}
+	}
+
+	t.Run("get or put", func(t *testing.T) {
+		m := NewSwissMap[string, int](sz)
+		for i, k := range keys {
+			act, loaded := m.GetOrPut(k, i)
+			assert.False(t, loaded)
+			assert.Equal(t, i, act)
+		}
+		for i, k := range keys {
+			act, loaded := m.GetOrPut(k, -i)
+			assert.True(t, loaded)
+			assert.Equal(t, i, act)
+		}
+		assert.Equal(t, len(keys), m.Count())
+	})
+	t.Run("compute", func(t *testing.T) {
+		m := NewSwissMap[string, int](sz)
+		for _, k := range stream {
+			m.Compute(k, func(old int, ok bool) (int, bool) {
+				return old + 1, true
+			})
+		}
+		assertCounts(m)
+		for _, k := range keys {
+			_, ok := m.Compute(k, func(old int, ok bool) (int, bool) {
+				return old, old%2 == 0
+			})
+			assert.Equal(t, ok, m.Has(k))
+		}
+	})
+	t.Run("entry", func(t *testing.T) {
+		m := NewSwissMap[string, int](sz)
+		for _, k := range stream {
+			m.Entry(k).AndModify(func(v int) int {
+				return v + 1
+			}).OrInsert(1)
+		}
+		assertCounts(m)
+		for _, k := range keys {
+			e := m.Entry(k)
+			v, ok := e.Value()
+			assert.Equal(t, golden[k] > 0, ok)
+			assert.Equal(t, golden[k], v)
+			assert.Equal(t, e.Found(), e.Delete())
+			assert.False(t, m.Has(k))
+			assert.Equal(t, 7, m.Entry(k).Put(7).OrInsertWith(func() int {
+				return 8
+			}))
+		}
+		assert.Equal(t, len(keys), m.Count())
+	})
+}
@@ -0,0 +1,33 @@
+//go:build go1.23
+
+package zend
+
+import "iter"
+
+// All returns an iterator over the key-value pairs of |m|.
+// It provides the same guarantees as Iter.
+func (m *SwissMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		m.Iter(func(k K, v V) (stop bool) {
+			return !yield(k, v)
+		})
+	}
+}
+
+// Keys returns an iterator over the keys of |m|.
+func (m *SwissMap[K, V]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		m.Iter(func(k K, _ V) (stop bool) {
+			return !yield(k)
+		})
+	}
+}
+
+// Values returns an iterator over the values of |m|.
+func (m *SwissMap[K, V]) Values() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		m.Iter(func(_ K, v V) (stop bool) {
+			return !yield(v)
+		})
+	}
+}
@@ -0,0 +1,33 @@
+//go:build go1.23
+
+package zend
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSwissSeqIterators(t *testing.T) {
+	for _, n := range []int{1000, 100_000} {
+		keys := genSwissStringData(16, n)
+		golden := make(map[string]int, len(keys))
+		m := NewSwissMap[string, int](uint32(n))
+		for i, k := range keys {
+			golden[k] = i
+			m.Put(k, i)
+		}
+		assert.Equal(t, golden, maps.Collect(m.All()))
+		assert.Equal(t, slices.Sorted(maps.Keys(golden)), slices.Sorted(m.Keys()))
+		assert.Equal(t, slices.Sorted(maps.Values(golden)), slices.Sorted(m.Values()))
+
+		var calls int
+		m.All()(func(k string, v int) bool {
+			calls++
+			return calls < 10
+		})
+		assert.Equal(t, 10, calls)
+	}
+}
@@ -0,0 +1,76 @@
+package zend
+
+import "unsafe"
+
+// SwissIterator is a pull-style iterator over the elements of a SwissMap.
+// It provides the same guarantees as SwissMap.Iter: a consistent view of
+// each table is taken before it is visited, so any key will be visited at
+// most once even if the SwissMap is rehashed during iteration.
+type SwissIterator[K comparable, V any] struct {
+	lm     *SwissLarge[K, V]
+	ctrl   *uint64
+	groups []swissGroup[K, V]
+	sdx    uint32 // next sub table to visit for flagLargeMap
+	g      uintptr
+	s      uintptr
+	key    K
+	value  V
+}
+
+// Iterator returns a SwissIterator positioned before the first element of |m|.
+// Call Next to advance it:
+//
+//	it := m.Iterator()
+//	for it.Next() {
+//		k, v := it.Key(), it.Value()
+//	}
+func (m *SwissMap[K, V]) Iterator() SwissIterator[K, V] {
+	if m.flags == flagLargeMap {
+		lm := (*SwissLarge[K, V])(unsafe.Pointer(m))
+		return SwissIterator[K, V]{
+			lm:     lm,
+			ctrl:   lm.subs[0].ctrl,
+			groups: lm.subs[0].groups,
+			sdx:    1,
+		}
+	}
+	return SwissIterator[K, V]{ctrl: m.ctrl, groups: m.groups}
+}
+
+// Next advances the SwissIterator to the next element,
+// returning false once all elements have been visited.
+func (it *SwissIterator[K, V]) Next() bool {
+	var meta *swissMetadata
+	for {
+		for ; it.g < uintptr(len(it.groups)); it.g++ {
+			meta = (*swissMetadata)(unsafe.Pointer(uintptr(unsafe.Pointer(it.ctrl)) + it.g<<3))
+			for it.s < swissGroupSize {
+				s := it.s
+				it.s++
+				if c := meta[s]; c == swissEmpty || c == swissTombstone {
+					continue
+				}
+				it.key, it.value = it.groups[it.g].keys[s], it.groups[it.g].values[s]
+				return true
+			}
+			it.s = 0
+		}
+		if it.lm == nil || it.sdx >= splitSubMapSize {
+			return false
+		}
+		// take a consistent view of the next sub table
+		it.ctrl, it.groups = it.lm.subs[it.sdx].ctrl, it.lm.subs[it.sdx].groups
+		it.sdx++
+		it.g = 0
+	}
+}
+
+// Key returns the key of the current element.
+func (it *SwissIterator[K, V]) Key() K {
+	return it.key
+}
+
+// Value returns the value of the current element.
+func (it *SwissIterator[K, V]) Value() V {
+	return it.value
+}
@@ -0,0 +1,59 @@
+package zend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSwissIterator(t *testing.T) {
+	t.Run("strings=0", func(t *testing.T) {
+		testSwissIterator(t, genSwissStringData(16, 0))
+	})
+	t.Run("strings=100", func(t *testing.T) {
+		testSwissIterator(t, genSwissStringData(16, 100))
+	})
+	t.Run("strings=100_000", func(t *testing.T) {
+		testSwissIterator(t, genSwissStringData(16, 100_000))
+	})
+	t.Run("uint32=0", func(t *testing.T) {
+		testSwissIterator(t, genSwissUint32Data(0))
+	})
+	t.Run("uint32=100", func(t *testing.T) {
+		testSwissIterator(t, genSwissUint32Data(100))
+	})
+	t.Run("uint32=100_000", func(t *testing.T) {
+		testSwissIterator(t, genSwissUint32Data(100_000))
+	})
+}
+
+func testSwissIterator[K comparable](t *testing.T, keys []K) {
+	m := NewSwissMap[K, int](uint32(len(keys)))
+	for i, key := range keys {
+		m.Put(key, i)
+	}
+	visited := make(map[K]int, len(keys))
+	it := m.Iterator()
+	for it.Next() {
+		visited[it.Key()]++
+		exp, ok := m.Get(it.Key())
+		assert.True(t, ok)
+		assert.Equal(t, exp, it.Value())
+	}
+	assert.Equal(t, len(keys), len(visited))
+	for _, c := range visited {
+		assert.Equal(t, 1, c)
+	}
+	assert.False(t, it.Next())
+
+	// mutate on iteration
+	it = m.Iterator()
+	for it.Next() {
+		m.Put(it.Key(), -it.Value())
+	}
+	for i, key := range keys {
+		act, ok := m.Get(key)
+		assert.True(t, ok)
+		assert.Equal(t, -i, act)
+	}
+}
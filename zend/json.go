@@ -0,0 +1,257 @@
+package zend
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"unsafe"
+)
+
+// MarshalJSON implements json.Marshaler. The SwissMap is encoded as a JSON
+// object with sorted member names, exactly as a builtin map[K]V is.
+func (m *SwissMap[K, V]) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+	return swissMarshalJSONObject(m.Count(), m.Iter)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It accepts the JSON objects
+// accepted by a builtin map[K]V and, like one, adds their members to the
+// existing elements of the SwissMap. JSON null clears the SwissMap.
+func (m *SwissMap[K, V]) UnmarshalJSON(data []byte) error {
+	if m.flags != flagLargeMap && m.ctrl == nil {
+		*m = *NewSwissMap[K, V](0)
+	}
+	return swissUnmarshalJSONObject(data, m.Clear, m.Grow, m.Put)
+}
+
+// swissMarshalJSONObject encodes the |n| elements visited by |iter|.
+func swissMarshalJSONObject[K any, V any](n int, iter func(cb func(k K, v V) (stop bool))) ([]byte, error) {
+	name, _, err := swissJSONKeyFuncs[K]()
+	if err != nil {
+		return nil, err
+	}
+	type member struct {
+		name  string
+		value []byte
+	}
+	members := make([]member, 0, n)
+	iter(func(k K, v V) (stop bool) {
+		var mb member
+		if mb.name, err = name(k); err != nil {
+			return true
+		}
+		if mb.value, err = json.Marshal(v); err != nil {
+			return true
+		}
+		members = append(members, mb)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].name < members[j].name
+	})
+
+	buf := make([]byte, 0, 2+len(members)*16)
+	buf = append(buf, '{')
+	for i, mb := range members {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		// a string always marshals
+		q, _ := json.Marshal(mb.name)
+		buf = append(buf, q...)
+		buf = append(buf, ':')
+		buf = append(buf, mb.value...)
+	}
+	return append(buf, '}'), nil
+}
+
+// swissUnmarshalJSONObject decodes the members of the JSON object |data| one
+// at a time, passing each to |put| after calling |grow| with their number.
+// If |data| is null, it calls |clear| instead.
+func swissUnmarshalJSONObject[K any, V any](data []byte, clear func(), grow func(n int), put func(k K, v V)) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		clear()
+		return nil
+	}
+	_, key, err := swissJSONKeyFuncs[K]()
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != json.Delim('{') {
+		return &json.UnmarshalTypeError{
+			Value: swissJSONTokenKind(tok),
+			Type:  reflect.TypeOf((*map[string]V)(nil)).Elem(),
+		}
+	}
+	grow(swissJSONObjectLen(data))
+	for dec.More() {
+		if tok, err = dec.Token(); err != nil {
+			return err
+		}
+		k, err := key(tok.(string))
+		if err != nil {
+			return err
+		}
+		var v V
+		if err = dec.Decode(&v); err != nil {
+			return err
+		}
+		put(k, v)
+	}
+	_, err = dec.Token()
+	return err
+}
+
+// swissJSONKeyFuncs returns functions that convert keys of type K to and from
+// JSON member names, following the rules encoding/json applies to the keys
+// of a builtin map: keys of kind string are used as is, keys implementing
+// encoding.TextMarshaler and encoding.TextUnmarshaler are converted by
+// them, and integer keys are formatted in decimal.
+func swissJSONKeyFuncs[K any]() (name func(k K) (string, error), key func(s string) (K, error), err error) {
+	t := reflect.TypeOf((*K)(nil)).Elem()
+
+	switch {
+	case t.Kind() == reflect.String:
+		name = func(k K) (string, error) {
+			return *(*string)(unsafe.Pointer(&k)), nil
+		}
+	case t.Implements(swissTextMarshalerType):
+		name = func(k K) (string, error) {
+			if t.Kind() == reflect.Pointer && reflect.ValueOf(&k).Elem().IsNil() {
+				return "", nil
+			}
+			b, err := any(k).(encoding.TextMarshaler).MarshalText()
+			return string(b), err
+		}
+	case swissIsIntKind(t.Kind()):
+		name = func(k K) (string, error) {
+			return strconv.FormatInt(reflect.ValueOf(k).Int(), 10), nil
+		}
+	case swissIsUintKind(t.Kind()):
+		name = func(k K) (string, error) {
+			return strconv.FormatUint(reflect.ValueOf(k).Uint(), 10), nil
+		}
+	default:
+		return nil, nil, &json.UnsupportedTypeError{Type: t}
+	}
+
+	switch {
+	case reflect.PointerTo(t).Implements(swissTextUnmarshalerType):
+		key = func(s string) (k K, err error) {
+			err = any(&k).(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+			return
+		}
+	case t.Kind() == reflect.String:
+		key = func(s string) (k K, err error) {
+			*(*string)(unsafe.Pointer(&k)) = s
+			return
+		}
+	case swissIsIntKind(t.Kind()):
+		key = func(s string) (k K, err error) {
+			v := reflect.ValueOf(&k).Elem()
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || v.OverflowInt(n) {
+				return k, &json.UnmarshalTypeError{Value: "number " + s, Type: t}
+			}
+			v.SetInt(n)
+			return k, nil
+		}
+	case swissIsUintKind(t.Kind()):
+		key = func(s string) (k K, err error) {
+			v := reflect.ValueOf(&k).Elem()
+			n, err := strconv.ParseUint(s, 10, 64)
+			if err != nil || v.OverflowUint(n) {
+				return k, &json.UnmarshalTypeError{Value: "number " + s, Type: t}
+			}
+			v.SetUint(n)
+			return k, nil
+		}
+	default:
+		// a TextMarshaler without a TextUnmarshaler can only be encoded
+		key = func(s string) (k K, err error) {
+			return k, &json.UnmarshalTypeError{Value: "string", Type: t}
+		}
+	}
+	return
+}
+
+// swissJSONObjectLen returns the number of members of the JSON object |data|,
+// which must be valid JSON.
+func swissJSONObjectLen(data []byte) (n int) {
+	var (
+		depth           int
+		str, esc, named bool
+	)
+	for _, c := range data {
+		switch {
+		case esc:
+			esc = false
+		case str:
+			esc = c == '\\'
+			str = c != '"'
+		case c == '"':
+			named = named || depth == 1
+			str = true
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+		case c == ',' && depth == 1:
+			n++
+		}
+	}
+	if named {
+		// members are separated by commas
+		n++
+	}
+	return
+}
+
+func swissJSONTokenKind(tok json.Token) string {
+	switch tok.(type) {
+	case json.Delim:
+		return "array"
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	default:
+		return "null"
+	}
+}
+
+func swissIsIntKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func swissIsUintKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+var (
+	swissTextMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	swissTextUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
@@ -0,0 +1,35 @@
+package zend
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSwissJSONEncoding(t *testing.T) {
+	for _, sz := range []uint32{0, splitSubMapLimit} {
+		exp := make(map[uint32]string)
+		m := NewSwissMap[uint32, string](sz)
+		for i, k := range genSwissUint32Data(int(sz) + 100) {
+			exp[k] = genSwissStringData(4, 1)[0][:i%4]
+			m.Put(k, exp[k])
+		}
+		data, err := json.Marshal(m)
+		require.NoError(t, err)
+		expData, err := json.Marshal(exp)
+		require.NoError(t, err)
+		assert.Equal(t, string(expData), string(data))
+
+		var act SwissMap[uint32, string]
+		require.NoError(t, json.Unmarshal(data, &act))
+		assertSwissMapsEqual(t, m, &act)
+		require.NoError(t, json.Unmarshal([]byte("null"), &act))
+		assert.Equal(t, 0, act.Count())
+	}
+
+	var m SwissMap[int8, int]
+	assert.Error(t, json.Unmarshal([]byte(`{"1000":1}`), &m))
+	assert.Equal(t, 3, swissJSONObjectLen([]byte(`{"a":"b,c","d":[1,2],"e":{"f":1,"g":2}}`)))
+}
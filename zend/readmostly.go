@@ -0,0 +1,373 @@
+package zend
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// swissSeqMaxInline is the size in bytes of the largest
+// element a ReadMostlyMap stores inline in its table
+const swissSeqMaxInline = 64
+
+// ReadMostlyMap is a hash map that is safe for concurrent use, for
+// workloads dominated by reads. Get and Has never take a lock or write
+// to shared memory: they validate a per-table sequence counter (a seqlock)
+// around their probe and retry if a writer intervened. Writers are
+// serialized by a mutex.
+//
+// Every word a reader touches is accessed atomically, so that a reader
+// racing with a writer is not a data race. If K and V contain no pointers
+// and an element takes at most swissSeqMaxInline bytes, elements are stored
+// inline: readers copy them word by word and only use a copy once the
+// sequence counter shows that it was not torn, and Put does not allocate.
+// Otherwise a torn copy could pair a pointer with the wrong length, so each
+// slot holds a pointer to an immutable entry, which every Put allocates.
+// Rehashing builds a new table and publishes it with a pointer swap,
+// leaving the retired table readable but permanently marked as written.
+type ReadMostlyMap[K comparable, V any] struct {
+	hash Hasher[K]
+	// table is the current *swissSeqTable, swapped on rehash
+	table unsafe.Pointer
+	// mu serializes writers
+	mu sync.Mutex
+	// inline is true if elements are stored inline,
+	// in |stride| words per slot
+	inline bool
+	stride uint32
+}
+
+// swissSeqTable is a table of a ReadMostlyMap.
+type swissSeqTable[K comparable, V any] struct {
+	// seq is odd while a writer modifies the table,
+	// and stays odd once the table is retired
+	seq uint64
+	// ctrl holds the swissMetadata of each swissGroup
+	ctrl []uint64
+	// words holds the element of each slot of each
+	// swissGroup if elements are stored inline
+	words []uint64
+	// slots otherwise holds a *swissSeqEntry or
+	// nil for each slot of each swissGroup
+	slots []unsafe.Pointer
+	// resident, dead and limit are only
+	// accessed with the writer lock held
+	resident uint32
+	dead     uint32
+	limit    uint32
+}
+
+type swissSeqEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// NewReadMostlyMap constructs a ReadMostlyMap.
+//
+//goland:noinspection GoUnusedExportedFunction
+func NewReadMostlyMap[K comparable, V any](sz uint32) *ReadMostlyMap[K, V] {
+	m := &ReadMostlyMap[K, V]{hash: NewHasher[K]()}
+	size := unsafe.Sizeof(swissSeqEntry[K, V]{})
+	if !swissHasPointers[K]() && !swissHasPointers[V]() && size <= swissSeqMaxInline {
+		m.inline, m.stride = true, uint32((size+7)/8)
+	}
+	m.table = unsafe.Pointer(m.newTable(swissNumGroups(sz)))
+	return m
+}
+
+func (m *ReadMostlyMap[K, V]) newTable(groupn uint32) *swissSeqTable[K, V] {
+	t := &swissSeqTable[K, V]{
+		ctrl:  make([]uint64, groupn),
+		limit: groupn * swissMaxAvgGroupLoad,
+	}
+	if m.inline {
+		t.words = make([]uint64, groupn*swissGroupSize*m.stride)
+	} else {
+		t.slots = make([]unsafe.Pointer, groupn*swissGroupSize)
+	}
+	for i := range t.ctrl {
+		t.ctrl[i] = swissEmpty64
+	}
+	return t
+}
+
+// Get returns the |value| mapped by |key| if one exists.
+func (m *ReadMostlyMap[K, V]) Get(key K) (value V, ok bool) {
+	hi, lo := swissSplitHash(m.hash.Hash64(key))
+	for {
+		t := m.load()
+		seq := atomic.LoadUint64(&t.seq)
+		if seq&1 == 0 {
+			value, ok = m.lookup(t, key, hi, lo)
+			if atomic.LoadUint64(&t.seq) == seq {
+				return
+			}
+		}
+		// a writer intervened
+		runtime.Gosched()
+	}
+}
+
+// Has returns true if |key| is present in |m|.
+func (m *ReadMostlyMap[K, V]) Has(key K) (ok bool) {
+	_, ok = m.Get(key)
+	return
+}
+
+// Put attempts to insert |key| and |value|
+func (m *ReadMostlyMap[K, V]) Put(key K, value V) {
+	hi, lo := swissSplitHash(m.hash.Hash64(key))
+	e := swissSeqEntry[K, V]{key: key, value: value}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := m.load()
+	g, s, ok := m.find(t, key, hi, lo)
+	if ok { // update
+		t.begin()
+		m.store(t, g*swissGroupSize+s, &e)
+		t.end()
+		return
+	}
+	if t.resident >= t.limit {
+		n := uint32(len(t.ctrl)) * 2
+		if t.dead >= (t.resident / 2) {
+			n = uint32(len(t.ctrl))
+		}
+		t = m.rehash(n)
+		g, s, _ = m.find(t, key, hi, lo)
+	}
+	t.begin()
+	m.store(t, g*swissGroupSize+s, &e)
+	t.setCtrl(g, s, int8(lo))
+	t.end()
+	t.resident++
+}
+
+// Delete attempts to remove |key|, returns true successful.
+func (m *ReadMostlyMap[K, V]) Delete(key K) (ok bool) {
+	hi, lo := swissSplitHash(m.hash.Hash64(key))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := m.load()
+	g, s, ok := m.find(t, key, hi, lo)
+	if !ok {
+		return false
+	}
+	meta := t.ctrl[g]
+	t.begin()
+	// see SwissMap.Delete
+	if swissMetaMatchEmpty(&meta) != 0 {
+		t.setCtrl(g, s, swissEmpty)
+		t.resident--
+	} else {
+		t.setCtrl(g, s, swissTombstone)
+		t.dead++
+	}
+	if !m.inline {
+		// release the entry for the garbage collector
+		atomic.StorePointer(&t.slots[g*swissGroupSize+s], nil)
+	}
+	t.end()
+	return true
+}
+
+// Range calls |f| for each key and value in the ReadMostlyMap, stopping if
+// |f| returns false. Range takes the writer lock to copy each swissGroup,
+// but not while calling |f|, which may modify the map. If the map is
+// rehashed during Range, the remaining elements are visited as of the
+// rehash, and if it is modified in place, modifications may or may not be
+// seen.
+func (m *ReadMostlyMap[K, V]) Range(f func(key K, value V) bool) {
+	var (
+		elems [swissGroupSize]swissSeqEntry[K, V]
+		n     int
+	)
+	t := m.load()
+	for g := range t.ctrl {
+		m.mu.Lock()
+		n = 0
+		for s := uint32(0); s < swissGroupSize; s++ {
+			if c := *_i8(&t.ctrl[g], s); c != swissEmpty && c != swissTombstone {
+				elems[n] = m.entry(t, uint32(g)*swissGroupSize+s)
+				n++
+			}
+		}
+		m.mu.Unlock()
+		for _, e := range elems[:n] {
+			if !f(e.key, e.value) {
+				return
+			}
+		}
+	}
+}
+
+// Clear removes all elements from the ReadMostlyMap.
+func (m *ReadMostlyMap[K, V]) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := m.load()
+	t.begin() // retire |t|
+	atomic.StorePointer(&m.table, unsafe.Pointer(m.newTable(uint32(len(t.ctrl)))))
+}
+
+// Count returns the number of elements in the ReadMostlyMap.
+// Unlike reads, Count takes the writer lock.
+func (m *ReadMostlyMap[K, V]) Count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := m.load()
+	return int(t.resident - t.dead)
+}
+
+func (m *ReadMostlyMap[K, V]) load() *swissSeqTable[K, V] {
+	return (*swissSeqTable[K, V])(atomic.LoadPointer(&m.table))
+}
+
+// rehash moves the elements of the current table into a new table of
+// |groupn| swissGroups, publishes it and retires the current table.
+// The writer lock must be held.
+func (m *ReadMostlyMap[K, V]) rehash(groupn uint32) *swissSeqTable[K, V] {
+	old := m.load()
+	old.begin() // retire |old|, readers retry on the new table
+	t := m.newTable(groupn)
+	for g := range old.ctrl {
+		for s := uint32(0); s < swissGroupSize; s++ {
+			if c := *_i8(&old.ctrl[g], s); c == swissEmpty || c == swissTombstone {
+				continue
+			}
+			e := m.entry(old, uint32(g)*swissGroupSize+s)
+			hi, lo := swissSplitHash(m.hash.Hash64(e.key))
+			gg, ss, _ := m.find(t, e.key, hi, lo)
+			if m.inline {
+				m.store(t, gg*swissGroupSize+ss, &e)
+			} else {
+				// move the entry rather than allocate a new one
+				t.slots[gg*swissGroupSize+ss] = old.slots[uint32(g)*swissGroupSize+s]
+			}
+			*_i8(&t.ctrl[gg], ss) = int8(lo) // t.ctrl[gg][ss]
+			t.resident++
+		}
+	}
+	atomic.StorePointer(&m.table, unsafe.Pointer(t))
+	return t
+}
+
+// lookup returns the value of |key| in |t|. The result is only
+// valid if |t.seq| is even and unchanged after the call.
+func (m *ReadMostlyMap[K, V]) lookup(t *swissSeqTable[K, V], key K, hi swissH1, lo swissH2) (value V, ok bool) {
+	size := uint32(len(t.ctrl))
+	g := swissProbeStart(hi, size)
+	// a torn view may lack an empty slot,
+	// so probe each swissGroup at most once
+	for n := uint32(0); n < size; n++ {
+		meta := atomic.LoadUint64(&t.ctrl[g])
+		matches := swissMetaMatchH2(&meta, lo)
+		for matches != 0 {
+			i := g*swissGroupSize + swissNextMatch(&matches)
+			if m.inline {
+				// K and V hold no pointers, so comparing
+				// a torn copy of |key| is harmless
+				var e swissSeqEntry[K, V]
+				t.loadInline(i, m.stride, &e)
+				if key == e.key {
+					return e.value, true
+				}
+			} else if e := (*swissSeqEntry[K, V])(atomic.LoadPointer(&t.slots[i])); e != nil && key == e.key {
+				return e.value, true
+			}
+		}
+		// |key| is not in swissGroup |g|,
+		// stop probing if we see an swissEmpty slot
+		if swissMetaMatchEmpty(&meta) != 0 {
+			return
+		}
+		g += 1 // linear probing
+		if g >= size {
+			g = 0
+		}
+	}
+	return
+}
+
+// find returns the location of |key| if present, or its insertion location
+// if absent. The writer lock must be held, or |t| must be unpublished.
+func (m *ReadMostlyMap[K, V]) find(t *swissSeqTable[K, V], key K, hi swissH1, lo swissH2) (g, s uint32, ok bool) {
+	size := uint32(len(t.ctrl))
+	g = swissProbeStart(hi, size)
+	for {
+		meta := &t.ctrl[g]
+		matches := swissMetaMatchH2(meta, lo)
+		for matches != 0 {
+			s = swissNextMatch(&matches)
+			if key == m.entry(t, g*swissGroupSize+s).key {
+				return g, s, true
+			}
+		}
+		// |key| is not in swissGroup |g|,
+		// stop probing if we see an swissEmpty slot
+		matches = swissMetaMatchEmpty(meta)
+		if matches != 0 {
+			s = swissNextMatch(&matches)
+			return g, s, false
+		}
+		g += 1 // linear probing
+		if g >= size {
+			g = 0
+		}
+	}
+}
+
+// entry returns the element in slot |i| of |t|, which must be full.
+// The writer lock must be held, or |t| must be unpublished.
+func (m *ReadMostlyMap[K, V]) entry(t *swissSeqTable[K, V], i uint32) (e swissSeqEntry[K, V]) {
+	if m.inline {
+		t.loadInline(i, m.stride, &e)
+		return
+	}
+	return *(*swissSeqEntry[K, V])(t.slots[i])
+}
+
+// store sets the element in slot |i| of |t| to |e|. Readers may
+// run concurrently, so |t| must be marked as written.
+func (m *ReadMostlyMap[K, V]) store(t *swissSeqTable[K, V], i uint32, e *swissSeqEntry[K, V]) {
+	if !m.inline {
+		c := *e
+		atomic.StorePointer(&t.slots[i], unsafe.Pointer(&c))
+		return
+	}
+	var buf [swissSeqMaxInline / 8]uint64
+	*(*swissSeqEntry[K, V])(unsafe.Pointer(&buf)) = *e
+	w := t.words[i*m.stride : (i+1)*m.stride]
+	for j := range w {
+		atomic.StoreUint64(&w[j], buf[j])
+	}
+}
+
+// loadInline copies the inline element in slot |i| of |t|, which takes
+// |stride| words, into |e|. The copy is torn if a writer intervened.
+func (t *swissSeqTable[K, V]) loadInline(i, stride uint32, e *swissSeqEntry[K, V]) {
+	var buf [swissSeqMaxInline / 8]uint64
+	w := t.words[i*stride : (i+1)*stride]
+	for j := range w {
+		buf[j] = atomic.LoadUint64(&w[j])
+	}
+	*e = *(*swissSeqEntry[K, V])(unsafe.Pointer(&buf))
+}
+
+// setCtrl atomically sets the control byte of slot |s| of swissGroup |g|.
+func (t *swissSeqTable[K, V]) setCtrl(g, s uint32, c int8) {
+	meta := t.ctrl[g]
+	*_i8(&meta, s) = c
+	atomic.StoreUint64(&t.ctrl[g], meta)
+}
+
+// begin marks the start of a write to |t|.
+func (t *swissSeqTable[K, V]) begin() {
+	atomic.AddUint64(&t.seq, 1)
+}
+
+// end marks the end of a write to |t|.
+func (t *swissSeqTable[K, V]) end() {
+	atomic.AddUint64(&t.seq, 1)
+}
@@ -0,0 +1,245 @@
+package zend
+
+import (
+	"math/rand"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadMostlyMap(t *testing.T) {
+	keys := genSwissStringData(8, 10_000)
+	m := NewReadMostlyMap[string, int](0)
+	for i, k := range keys {
+		m.Put(k, i)
+	}
+	assert.Equal(t, len(keys), m.Count())
+	for i, k := range keys {
+		v, ok := m.Get(k)
+		assert.True(t, ok)
+		assert.Equal(t, i, v)
+	}
+	for i, k := range keys {
+		if i%2 == 0 {
+			assert.True(t, m.Delete(k))
+			assert.False(t, m.Delete(k))
+		} else {
+			m.Put(k, -i)
+		}
+	}
+	assert.Equal(t, len(keys)/2, m.Count())
+	for i, k := range keys {
+		v, ok := m.Get(k)
+		assert.Equal(t, i%2 == 1, ok)
+		assert.Equal(t, i%2 == 1, m.Has(k))
+		if ok {
+			assert.Equal(t, -i, v)
+		}
+	}
+	n := 0
+	m.Range(func(k string, v int) bool {
+		assert.Equal(t, k, keys[-v])
+		// modifying the map from Range must not deadlock
+		m.Put(k, v)
+		n++
+		return true
+	})
+	assert.Equal(t, len(keys)/2, n)
+	m.Clear()
+	assert.Equal(t, 0, m.Count())
+	assert.False(t, m.Has(keys[1]))
+
+	// elements stored inline
+	im := NewReadMostlyMap[int, int](0)
+	for i := 0; i < 1000; i++ {
+		im.Put(i, -i)
+	}
+	for i := 0; i < 1000; i += 2 {
+		assert.True(t, im.Delete(i))
+	}
+	n = 0
+	im.Range(func(k, v int) bool {
+		assert.Equal(t, 1, k%2)
+		assert.Equal(t, -k, v)
+		n++
+		return true
+	})
+	assert.Equal(t, 500, n)
+	assert.Equal(t, 500, im.Count())
+}
+
+func TestReadMostlyMapAllocs(t *testing.T) {
+	m := NewReadMostlyMap[int, int](100)
+	assert.True(t, m.inline)
+	m.Put(1, 1)
+	// elements without pointers are stored inline
+	assert.Equal(t, 0.0, testing.AllocsPerRun(100, func() {
+		m.Get(1)
+	}))
+	assert.Equal(t, 0.0, testing.AllocsPerRun(100, func() {
+		m.Put(1, 2)
+	}))
+	// other elements are boxed, one entry per write
+	b := NewReadMostlyMap[string, int](100)
+	assert.False(t, b.inline)
+	b.Put("a", 1)
+	assert.Equal(t, 0.0, testing.AllocsPerRun(100, func() {
+		b.Get("a")
+	}))
+	assert.Equal(t, 1.0, testing.AllocsPerRun(100, func() {
+		b.Put("a", 2)
+	}))
+	// as are large elements
+	assert.False(t, NewReadMostlyMap[int, [swissSeqMaxInline]byte](0).inline)
+}
+
+// TestReadMostlyMapStress interleaves Put, Delete and growth with
+// concurrent readers. It is intended to be run with -race.
+func TestReadMostlyMapStress(t *testing.T) {
+	t.Run("inline", func(t *testing.T) {
+		testReadMostlyMapStress(t, func(k int) int { return k })
+	})
+	t.Run("boxed", func(t *testing.T) {
+		testReadMostlyMapStress(t, strconv.Itoa)
+	})
+}
+
+func testReadMostlyMapStress[V comparable](t *testing.T, val func(k int) V) {
+	const (
+		stable = 1000
+		churn  = 4000
+	)
+	readers := runtime.GOMAXPROCS(0)
+	if readers < 4 {
+		readers = 4
+	}
+	// keys [0, stable) are never deleted and are mapped to their
+	// own value, keys [stable, stable+churn) come and go
+	m := NewReadMostlyMap[int, V](0)
+	for k := 0; k < stable; k++ {
+		m.Put(k, val(k))
+	}
+	var (
+		done int32
+		wg   sync.WaitGroup
+	)
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(int64(r)))
+			for atomic.LoadInt32(&done) == 0 {
+				k := rnd.Intn(stable + churn)
+				v, ok := m.Get(k)
+				if k < stable {
+					assert.True(t, ok)
+					assert.Equal(t, val(k), v)
+				} else if ok {
+					assert.Equal(t, val(k), v)
+				}
+			}
+		}(r)
+	}
+	rnd := rand.New(rand.NewSource(0))
+	for i := 0; i < 10; i++ {
+		// grow through several rehashes
+		for k := stable; k < stable+churn; k++ {
+			m.Put(k, val(k))
+		}
+		for j := 0; j < churn; j++ {
+			k := stable + rnd.Intn(churn)
+			if rnd.Intn(2) == 0 {
+				m.Delete(k)
+			} else {
+				m.Put(k, val(k))
+			}
+		}
+		// update stable keys in place
+		for k := 0; k < stable; k += 7 {
+			m.Put(k, val(k))
+		}
+		for k := stable; k < stable+churn; k++ {
+			m.Delete(k)
+		}
+	}
+	atomic.StoreInt32(&done, 1)
+	wg.Wait()
+	assert.Equal(t, stable, m.Count())
+}
+
+func BenchmarkReadMostlyMap(b *testing.B) {
+	const n = 1 << 12
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	b.Run("sync.Map", func(b *testing.B) {
+		var m sync.Map
+		for i, k := range keys {
+			m.Store(k, i)
+		}
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			i := rand.Intn(n)
+			for pb.Next() {
+				m.Load(keys[i&(n-1)])
+				i++
+			}
+		})
+	})
+	b.Run("ConcurrentMap", func(b *testing.B) {
+		m := NewConcurrentMap[string, int](n)
+		for i, k := range keys {
+			m.Store(k, i)
+		}
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			i := rand.Intn(n)
+			for pb.Next() {
+				m.Load(keys[i&(n-1)])
+				i++
+			}
+		})
+	})
+	b.Run("ReadMostlyMap", func(b *testing.B) {
+		m := NewReadMostlyMap[string, int](n)
+		for i, k := range keys {
+			m.Put(k, i)
+		}
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			i := rand.Intn(n)
+			for pb.Next() {
+				m.Get(keys[i&(n-1)])
+				i++
+			}
+		})
+	})
+}
+
+func BenchmarkReadMostlyMapPut(b *testing.B) {
+	const n = 1 << 12
+	b.Run("inline", func(b *testing.B) {
+		m := NewReadMostlyMap[int, int](n)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			m.Put(i&(n-1), i)
+		}
+	})
+	b.Run("boxed", func(b *testing.B) {
+		keys := make([]string, n)
+		for i := range keys {
+			keys[i] = strconv.Itoa(i)
+		}
+		m := NewReadMostlyMap[string, int](n)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			m.Put(keys[i&(n-1)], i)
+		}
+	})
+}
@@ -0,0 +1,198 @@
+package zend
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"sync"
+	"unsafe"
+)
+
+// NewStableHasher returns a Hasher that hashes keys by their content
+// using wyhash, so that a key hashes the same for a given |seed| in every
+// process, on every platform and in every Go version. Keys may be
+// booleans, integers, floats and strings, and arrays and structs of them.
+// The Hasher ignores seeds given by NewSeed. It is slower than the Hasher
+// of NewHasher, and as its seed may be known it doesn't protect tables
+// from keys chosen to collide.
+func NewStableHasher[K comparable](seed uint64) (Hasher[K], error) {
+	hash, err := swissStableHashFunc(reflect.TypeOf((*K)(nil)).Elem())
+	if err != nil {
+		return Hasher[K]{}, err
+	}
+	return Hasher[K]{hash: func(key unsafe.Pointer, _ uintptr) uintptr {
+		return uintptr(hash(seed, key))
+	}}, nil
+}
+
+// NewSwissMapWithHasher constructs a SwissMap whose keys are hashed by |h|.
+func NewSwissMapWithHasher[K comparable, V any](sz uint32, h Hasher[K]) (m *SwissMap[K, V]) {
+	m = NewSwissMap[K, V](sz)
+	m.hash = h
+	return
+}
+
+// swissStableHashFunc returns a function hashing values of type |t| by their
+// canonical encoding: booleans are a byte, integers are little-endian
+// and of their declared size (int, uint and uintptr are 8 bytes), floats
+// are their little-endian IEEE 754 bits with -0 stored as +0, strings are
+// their bytes, prefixed by their length as a uvarint when within an array
+// or struct, and arrays and structs are their elements or fields in order,
+// skipping blank fields.
+func swissStableHashFunc(t reflect.Type) (func(seed uint64, p unsafe.Pointer) uint64, error) {
+	if t.Kind() == reflect.String {
+		return func(seed uint64, p unsafe.Pointer) uint64 {
+			return swissWyhash(seed, swissStringBytes((*string)(p)))
+		}, nil
+	}
+	if swissTypeIsMemComparable(t) && !swissNativeBigEndian() && strconv.IntSize == 64 {
+		// the memory of these types is their canonical encoding
+		size := t.Size()
+		return func(seed uint64, p unsafe.Pointer) uint64 {
+			return swissWyhash(seed, unsafe.Slice((*byte)(p), size))
+		}, nil
+	}
+	enc, err := swissStableEncoder(t)
+	if err != nil {
+		return nil, err
+	}
+	pool := sync.Pool{New: func() any {
+		b := make([]byte, 0, 64)
+		return &b
+	}}
+	return func(seed uint64, p unsafe.Pointer) uint64 {
+		buf := pool.Get().(*[]byte)
+		*buf = enc((*buf)[:0], p)
+		h := swissWyhash(seed, *buf)
+		pool.Put(buf)
+		return h
+	}, nil
+}
+
+// swissStableEncoder returns a function appending the canonical encoding of
+// the value of type |t| at |p|.
+func swissStableEncoder(t reflect.Type) (func(b []byte, p unsafe.Pointer) []byte, error) {
+	switch t.Kind() {
+	case reflect.Bool:
+		return func(b []byte, p unsafe.Pointer) []byte {
+			if *(*bool)(p) {
+				return append(b, 1)
+			}
+			return append(b, 0)
+		}, nil
+	case reflect.Int8, reflect.Uint8:
+		return func(b []byte, p unsafe.Pointer) []byte {
+			return append(b, *(*uint8)(p))
+		}, nil
+	case reflect.Int16, reflect.Uint16:
+		return func(b []byte, p unsafe.Pointer) []byte {
+			var w [2]byte
+			binary.LittleEndian.PutUint16(w[:], *(*uint16)(p))
+			return append(b, w[:]...)
+		}, nil
+	case reflect.Int32, reflect.Uint32:
+		return func(b []byte, p unsafe.Pointer) []byte {
+			var w [4]byte
+			binary.LittleEndian.PutUint32(w[:], *(*uint32)(p))
+			return append(b, w[:]...)
+		}, nil
+	case reflect.Int64, reflect.Uint64:
+		return swissStableEncodeUint64(func(p unsafe.Pointer) uint64 {
+			return *(*uint64)(p)
+		}), nil
+	case reflect.Int:
+		return swissStableEncodeUint64(func(p unsafe.Pointer) uint64 {
+			return uint64(*(*int)(p))
+		}), nil
+	case reflect.Uint:
+		return swissStableEncodeUint64(func(p unsafe.Pointer) uint64 {
+			return uint64(*(*uint)(p))
+		}), nil
+	case reflect.Uintptr:
+		return swissStableEncodeUint64(func(p unsafe.Pointer) uint64 {
+			return uint64(*(*uintptr)(p))
+		}), nil
+	case reflect.Float32:
+		return func(b []byte, p unsafe.Pointer) []byte {
+			var w [4]byte
+			f := *(*float32)(p)
+			if f == 0 {
+				f = 0 // -0 == +0
+			}
+			binary.LittleEndian.PutUint32(w[:], math.Float32bits(f))
+			return append(b, w[:]...)
+		}, nil
+	case reflect.Float64:
+		return swissStableEncodeUint64(func(p unsafe.Pointer) uint64 {
+			f := *(*float64)(p)
+			if f == 0 {
+				f = 0 // -0 == +0
+			}
+			return math.Float64bits(f)
+		}), nil
+	case reflect.String:
+		return func(b []byte, p unsafe.Pointer) []byte {
+			s := *(*string)(p)
+			var w [binary.MaxVarintLen64]byte
+			b = append(b, w[:binary.PutUvarint(w[:], uint64(len(s)))]...)
+			return append(b, s...)
+		}, nil
+	case reflect.Array:
+		elem, err := swissStableEncoder(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		n, size := t.Len(), t.Elem().Size()
+		return func(b []byte, p unsafe.Pointer) []byte {
+			for i := 0; i < n; i++ {
+				b = elem(b, unsafe.Pointer(uintptr(p)+uintptr(i)*size))
+			}
+			return b
+		}, nil
+	case reflect.Struct:
+		type field struct {
+			offset uintptr
+			enc    func(b []byte, p unsafe.Pointer) []byte
+		}
+		var fields []field
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Name == "_" {
+				continue
+			}
+			enc, err := swissStableEncoder(f.Type)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, field{offset: f.Offset, enc: enc})
+		}
+		return func(b []byte, p unsafe.Pointer) []byte {
+			for _, f := range fields {
+				b = f.enc(b, unsafe.Pointer(uintptr(p)+f.offset))
+			}
+			return b
+		}, nil
+	default:
+		return nil, fmt.Errorf("zend: StableHasher cannot hash %v", t)
+	}
+}
+
+func swissStableEncodeUint64(load func(p unsafe.Pointer) uint64) func(b []byte, p unsafe.Pointer) []byte {
+	return func(b []byte, p unsafe.Pointer) []byte {
+		var w [8]byte
+		binary.LittleEndian.PutUint64(w[:], load(p))
+		return append(b, w[:]...)
+	}
+}
+
+// swissStringBytes returns the bytes of |*s| without copying them.
+func swissStringBytes(s *string) []byte {
+	type header struct {
+		data *byte
+		len  int
+	}
+	h := (*header)(unsafe.Pointer(s))
+	return unsafe.Slice(h.data, h.len)
+}
@@ -0,0 +1,53 @@
+package zend
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSwissStableHasher(t *testing.T) {
+	// the test vectors of the reference wyhash,
+	// hashes may be persisted and must never change
+	assert.Equal(t, uint64(0x0409638ee2bde459), swissWyhash(0, nil))
+	assert.Equal(t, uint64(0x7a43afb61d7f5f40), swissWyhash(4, []byte("abcdefghijklmnopqrstuvwxyz")))
+	assert.Equal(t, uint64(0xc39cab13b115aad3), swissWyhash(6, []byte(strings.Repeat("1234567890", 8))))
+
+	h, err := NewStableHasher[string](7)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(uintptr(swissWyhash(7, []byte("hello")))), h.Hash64("hello"))
+	// seeds from NewSeed are ignored
+	assert.Equal(t, h.Hash64("hello"), NewSeed(h).Hash64("hello"))
+
+	type key struct {
+		a int16
+		b [2]string
+	}
+	k, err := NewStableHasher[key](7)
+	require.NoError(t, err)
+	enc := []byte{1, 0, 1, 'x', 0}
+	assert.Equal(t, uint64(uintptr(swissWyhash(7, enc))), k.Hash64(key{a: 1, b: [2]string{"x"}}))
+
+	_, err = NewStableHasher[*int](7)
+	assert.Error(t, err)
+}
+
+func TestSwissMapWithStableHasher(t *testing.T) {
+	h, err := NewStableHasher[uint32](42)
+	require.NoError(t, err)
+	for _, sz := range []uint32{0, splitSubMapLimit} {
+		m := NewSwissMapWithHasher[uint32, int](sz, h)
+		keys := genSwissUint32Data(int(sz) + 1000)
+		for i, k := range keys {
+			m.Put(k, i)
+		}
+		for i, k := range keys {
+			v, ok := m.Get(k)
+			assert.True(t, ok)
+			assert.Equal(t, i, v)
+			assert.Equal(t, h.Hash64(k), m.hash.Hash64(k))
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package zend
+
+import "reflect"
+
+// swissHasPointers returns true if values of type T contain pointers
+// that keep memory reachable for the garbage collector.
+func swissHasPointers[T any]() bool {
+	return swissTypeHasPointers(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+func swissTypeHasPointers(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return false
+	case reflect.Array:
+		return t.Len() > 0 && swissTypeHasPointers(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if swissTypeHasPointers(t.Field(i).Type) {
+				return true
+			}
+		}
+		return false
+	default: // pointers, strings, slices, maps, chans, funcs and interfaces
+		return true
+	}
+}
+
+// swissTypeIsMemComparable returns true if values of type |t| are equal
+// exactly when their memory is, so that hashing their memory is consistent
+// with ==. Floats (where 0 == -0 and NaN != NaN), pointers and structs with
+// padding are not.
+func swissTypeIsMemComparable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	case reflect.Array:
+		return t.Len() == 0 || swissTypeIsMemComparable(t.Elem())
+	case reflect.Struct:
+		var size uintptr
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Name == "_" || f.Offset != size || !swissTypeIsMemComparable(f.Type) {
+				return false
+			}
+			size += f.Type.Size()
+		}
+		return size == t.Size()
+	default:
+		return false
+	}
+}
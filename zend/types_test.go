@@ -0,0 +1,35 @@
+package zend
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSwissHasPointers(t *testing.T) {
+	assert.False(t, swissHasPointers[int]())
+	assert.False(t, swissHasPointers[uintptr]())
+	assert.False(t, swissHasPointers[complex128]())
+	assert.False(t, swissHasPointers[[4]uint32]())
+	assert.False(t, swissHasPointers[struct{}]())
+	assert.False(t, swissHasPointers[struct {
+		a int8
+		b [2]float64
+	}]())
+	assert.False(t, swissHasPointers[[0]*int]())
+
+	assert.True(t, swissHasPointers[string]())
+	assert.True(t, swissHasPointers[*int]())
+	assert.True(t, swissHasPointers[[]byte]())
+	assert.True(t, swissHasPointers[any]())
+	assert.True(t, swissHasPointers[map[int]int]())
+	assert.True(t, swissHasPointers[chan int]())
+	assert.True(t, swissHasPointers[func()]())
+	assert.True(t, swissHasPointers[unsafe.Pointer]())
+	assert.True(t, swissHasPointers[[2]string]())
+	assert.True(t, swissHasPointers[struct {
+		a int
+		b *int
+	}]())
+}
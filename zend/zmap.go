@@ -1,6 +1,7 @@
 package zend
 
 import (
+	"math"
 	"math/bits"
 	"unsafe"
 )
@@ -25,6 +26,10 @@ const (
 	swissEmpty64   uint64 = 0x8080_8080_8080_8080
 	swissTombstone int8   = -2 // 0b1111_1110
 
+	// swissShrinkRatio is the ratio of capacity to elements
+	// below which an auto-shrinking table is shrunk
+	swissShrinkRatio uint32 = 8
+
 	splitSubMapLimit uint32  = 32 * 1024
 	splitSubMapSize  uint32  = 256 // 2^8 uint8(swissH1) suffix 8bit
 	flagSmallMap     uintptr = 0
@@ -34,15 +39,22 @@ const (
 // SwissMap is an open-addressing hash map
 // based on Abseil's flat_hash_map.
 type SwissMap[K comparable, V any] struct {
-	flags uintptr
-	hash  Hasher[K]
+	flags  uintptr
+	hash   Hasher[K]
+	shrink bool
+	// ptrs is true if K or V contain pointers,
+	// which removed slots must release
+	ptrs bool
 	SwissSub[K, V]
 }
 
+// SwissLarge shares its leading fields with SwissMap.
 type SwissLarge[K comparable, V any] struct {
-	flags uintptr
-	hash  Hasher[K]
-	subs  [splitSubMapSize]SwissSub[K, V]
+	flags  uintptr
+	hash   Hasher[K]
+	shrink bool
+	ptrs   bool
+	subs   [splitSubMapSize]SwissSub[K, V]
 }
 
 type SwissSub[K comparable, V any] struct {
@@ -96,6 +108,7 @@ func NewSwissMap[K comparable, V any](sz uint32) *SwissMap[K, V] {
 		lm := &SwissLarge[K, V]{
 			flags: flagLargeMap,
 			hash:  NewHasher[K](),
+			ptrs:  swissHasPointers[K]() || swissHasPointers[V](),
 		}
 		groupn = swissNumGroups(sz >> 8) // swissNumGroups(sz / splitSubMapSize)
 		for sdx := uint32(0); sdx < splitSubMapSize; sdx++ {
@@ -116,6 +129,7 @@ func NewSwissMap[K comparable, V any](sz uint32) *SwissMap[K, V] {
 		m = &SwissMap[K, V]{
 			flags: flagSmallMap,
 			hash:  NewHasher[K](),
+			ptrs:  swissHasPointers[K]() || swissHasPointers[V](),
 			SwissSub: SwissSub[K, V]{
 				ctrl:   (*SwissUint64Slice)(unsafe.Pointer(&ctrl)).Ptr,
 				groups: make([]swissGroup[K, V], groupn),
@@ -407,6 +421,15 @@ func (m *SwissMap[K, V]) Delete(key K) bool {
 						*_i8(meta, s) = swissTombstone // lm.subs[sdx].ctrl[g][s]
 						lm.subs[sdx].dead++
 					}
+					if lm.ptrs {
+						// release the key and value for the garbage collector
+						var k K
+						var v V
+						lm.subs[sdx].groups[g].keys[s], lm.subs[sdx].groups[g].values[s] = k, v
+					}
+					if lm.shrink {
+						m.shrinkIfSparse(int32(sdx))
+					}
 					return true
 				}
 			}
@@ -447,6 +470,15 @@ func (m *SwissMap[K, V]) Delete(key K) bool {
 					*_i8(meta, s) = swissTombstone // m.ctrl[g][s]
 					m.dead++
 				}
+				if m.ptrs {
+					// release the key and value for the garbage collector
+					var k K
+					var v V
+					m.groups[g].keys[s], m.groups[g].values[s] = k, v
+				}
+				if m.shrink {
+					m.shrinkIfSparse(-1)
+				}
 				return true
 			}
 		}
@@ -523,6 +555,9 @@ func (m *SwissMap[K, V]) Clear() {
 			for i := uintptr(0); i < uintptr(groupm); i += 8 {
 				*(*uint64)(unsafe.Pointer(uintptr(unsafe.Pointer(lm.subs[sdx].ctrl)) + i)) = swissEmpty64
 			}
+			if lm.ptrs {
+				lm.subs[sdx].clearGroups()
+			}
 			lm.subs[sdx].resident, lm.subs[sdx].dead = 0, 0
 		}
 		return
@@ -532,6 +567,9 @@ func (m *SwissMap[K, V]) Clear() {
 	for i := uintptr(0); i < uintptr(groupm); i += 8 {
 		*(*uint64)(unsafe.Pointer(uintptr(unsafe.Pointer(m.ctrl)) + i)) = swissEmpty64
 	}
+	if m.ptrs {
+		m.clearGroups()
+	}
 	m.resident, m.dead = 0, 0
 }
 
@@ -562,6 +600,102 @@ func (m *SwissMap[K, V]) Capacity() int {
 	return int(m.limit - m.resident)
 }
 
+// Grow ensures that |n| more elements can be added to the SwissMap without
+// rehashing. For a flagLargeMap, |n| is spread evenly over the sub tables.
+func (m *SwissMap[K, V]) Grow(n int) {
+	if m.flags == flagLargeMap {
+		lm := (*SwissLarge[K, V])(unsafe.Pointer(m))
+		per := swissTableSize((n + int(splitSubMapSize) - 1) >> 8)
+		for sdx := uint32(0); sdx < splitSubMapSize; sdx++ {
+			t := &lm.subs[sdx]
+			if per > t.limit-t.resident {
+				m.subRehash(uint8(sdx), swissNumGroups(t.resident-t.dead+per))
+			}
+		}
+		return
+	}
+	if n <= m.Capacity() {
+		return
+	}
+	m.rehash(swissNumGroups(swissTableSize(m.Count() + n)))
+}
+
+// Shrink rehashes every table of the SwissMap into the smallest size that can
+// hold its elements, releasing the memory held by unused groups and tombstones.
+func (m *SwissMap[K, V]) Shrink() {
+	if m.flags == flagLargeMap {
+		lm := (*SwissLarge[K, V])(unsafe.Pointer(m))
+		for sdx := uint32(0); sdx < splitSubMapSize; sdx++ {
+			t := &lm.subs[sdx]
+			n := swissNumGroups(t.resident - t.dead)
+			if n < uint32(len(t.groups)) || t.dead > 0 {
+				m.subRehash(uint8(sdx), n)
+			}
+		}
+		return
+	}
+	n := swissNumGroups(m.resident - m.dead)
+	if n < uint32(len(m.groups)) || m.dead > 0 {
+		m.rehash(n)
+	}
+}
+
+// Reset removes all elements from the SwissMap and reallocates it with room
+// for |capacity| elements, releasing its previous tables. A flagLargeMap stays
+// split into sub tables, and a small SwissMap stays a single table.
+func (m *SwissMap[K, V]) Reset(capacity int) {
+	sz := swissTableSize(capacity)
+	if m.flags == flagLargeMap {
+		lm := (*SwissLarge[K, V])(unsafe.Pointer(m))
+		for sdx := uint32(0); sdx < splitSubMapSize; sdx++ {
+			lm.subs[sdx].reset(swissNumGroups(sz >> 8))
+		}
+		return
+	}
+	m.reset(swissNumGroups(sz))
+}
+
+// SetAutoShrink enables or disables automatic shrinking. When enabled,
+// Delete rehashes a table into a smaller one once fewer than 1/8 of its
+// capacity is in use, so that a drained SwissMap releases its memory.
+func (m *SwissMap[K, V]) SetAutoShrink(enabled bool) {
+	m.shrink = enabled
+}
+
+// shrinkIfSparse shrinks table |i| if less than 1/swissShrinkRatio of its
+// capacity is in use, leaving room for it to double before growing again.
+func (m *SwissMap[K, V]) shrinkIfSparse(i int32) {
+	t := m.sub(i)
+	live := t.resident - t.dead
+	if len(t.groups) > 1 && live < t.limit/swissShrinkRatio {
+		if i < 0 {
+			m.rehash(swissNumGroups(live * 2))
+		} else {
+			m.subRehash(uint8(i), swissNumGroups(live*2))
+		}
+	}
+}
+
+// clearGroups releases all keys and values of |t| for the garbage
+// collector, compiled to a bulk memory clear.
+func (t *SwissSub[K, V]) clearGroups() {
+	for i := range t.groups {
+		t.groups[i] = swissGroup[K, V]{}
+	}
+}
+
+// reset replaces the table of |t| with |groupn| empty swissGroups.
+func (t *SwissSub[K, V]) reset(groupn uint32) {
+	ctrl := make([]uint64, groupn)
+	for i := range ctrl {
+		ctrl[i] = swissEmpty64
+	}
+	t.ctrl = (*SwissUint64Slice)(unsafe.Pointer(&ctrl)).Ptr
+	t.groups = make([]swissGroup[K, V], groupn)
+	t.limit = groupn * swissMaxAvgGroupLoad
+	t.resident, t.dead = 0, 0
+}
+
 // find returns the location of |key| if present, or its insertion location if absent.
 // for performance, find is manually inlined into public methods.
 func (m *SwissMap[K, V]) find(key K, hi swissH1, lo swissH2) (i int32, g, s uint32, ok bool) {
@@ -628,6 +762,54 @@ func (m *SwissMap[K, V]) find(key K, hi swissH1, lo swissH2) (i int32, g, s uint
 	}
 }
 
+// sub returns the table addressed by the sub table index |i| returned by find.
+func (m *SwissMap[K, V]) sub(i int32) *SwissSub[K, V] {
+	if i < 0 {
+		return &m.SwissSub
+	}
+	return &(*SwissLarge[K, V])(unsafe.Pointer(m)).subs[i]
+}
+
+// insert stores |key| and |value| at the insertion location |i|, |g|, |s|
+// returned by find, rehashing first if the table is full. It returns the
+// location where |key| was stored.
+func (m *SwissMap[K, V]) insert(key K, value V, i int32, g, s uint32, hi swissH1, lo swissH2) (int32, uint32, uint32) {
+	t := m.sub(i)
+	if t.resident >= t.limit {
+		if i < 0 {
+			m.rehash(m.nextSize())
+		} else {
+			m.subRehash(uint8(i), m.subNextSize(uint8(i)))
+		}
+		i, g, s, _ = m.find(key, hi, lo)
+	}
+	t.groups[g].keys[s] = key
+	t.groups[g].values[s] = value
+	*_i8(_u64(t.ctrl, g), s) = int8(lo) // t.ctrl[g][s]
+	t.resident++
+	return i, g, s
+}
+
+// deleteAt removes the element stored in slot |s| of swissGroup |g| of table |i|.
+func (m *SwissMap[K, V]) deleteAt(i int32, g, s uint32) {
+	t := m.sub(i)
+	meta := _u64(t.ctrl, g)
+	// see Delete
+	if swissMetaMatchEmpty(meta) != 0 {
+		*_i8(meta, s) = swissEmpty // t.ctrl[g][s]
+		t.resident--
+	} else {
+		*_i8(meta, s) = swissTombstone // t.ctrl[g][s]
+		t.dead++
+	}
+	if m.ptrs {
+		// release the key and value for the garbage collector
+		var k K
+		var v V
+		t.groups[g].keys[s], t.groups[g].values[s] = k, v
+	}
+}
+
 func (m *SwissMap[K, V]) subNextSize(sdx uint8) (n uint32) {
 	lm := (*SwissLarge[K, V])(unsafe.Pointer(m))
 
@@ -756,6 +938,15 @@ func swissNumGroups(n uint32) (groups uint32) {
 	return
 }
 
+// swissTableSize converts the element count |n| to a table
+// size, panicking if |n| is out of range.
+func swissTableSize(n int) uint32 {
+	if n < 0 || uint64(n) > math.MaxUint32-swissMaxAvgGroupLoad {
+		panic("zend: table size out of range")
+	}
+	return uint32(n)
+}
+
 func swissNewEmptyMetadata() (meta swissMetadata) {
 	for i := range meta {
 		meta[i] = swissEmpty
@@ -520,3 +520,106 @@ func swissExpected(x int) (groups uint32) {
 	}
 	return
 }
+
+func TestSwissMapResize(t *testing.T) {
+	t.Run("small", func(t *testing.T) {
+		testSwissMapResize(t, 0, 10_000)
+	})
+	t.Run("large", func(t *testing.T) {
+		testSwissMapResize(t, splitSubMapLimit, 100_000)
+	})
+}
+
+func testSwissMapResize(t *testing.T, sz uint32, n int) {
+	keys := genSwissUint32Data(n)
+	m := NewSwissMap[uint32, int](sz)
+	m.Grow(len(keys))
+	assert.GreaterOrEqual(t, m.Capacity(), len(keys))
+	for i, k := range keys {
+		m.Put(k, i)
+	}
+	for _, k := range keys[100:] {
+		m.Delete(k)
+	}
+	m.Shrink()
+	assert.Equal(t, 100, m.Count())
+	assert.Equal(t, 100, m.getResident())
+	for i, k := range keys[:100] {
+		act, ok := m.Get(k)
+		assert.True(t, ok)
+		assert.Equal(t, i, act)
+	}
+
+	m.Reset(n)
+	assert.Equal(t, 0, m.Count())
+	assert.GreaterOrEqual(t, m.Capacity(), n)
+	assert.False(t, m.Has(keys[0]))
+
+	m.SetAutoShrink(true)
+	for i, k := range keys {
+		m.Put(k, i)
+	}
+	before := m.Capacity() + m.getResident()
+	for _, k := range keys[10:] {
+		m.Delete(k)
+	}
+	assert.Less(t, m.Capacity()+m.getResident(), before/4)
+	assert.Equal(t, 10, m.Count())
+	for i, k := range keys[:10] {
+		act, ok := m.Get(k)
+		assert.True(t, ok)
+		assert.Equal(t, i, act)
+	}
+}
+
+func TestSwissMapReleasesReferences(t *testing.T) {
+	t.Run("small", func(t *testing.T) {
+		testSwissMapReleasesReferences(t, 0, 1000)
+	})
+	t.Run("large", func(t *testing.T) {
+		testSwissMapReleasesReferences(t, splitSubMapLimit, 100_000)
+	})
+}
+
+func testSwissMapReleasesReferences(t *testing.T, sz uint32, n int) {
+	keys := genSwissStringData(16, n)
+	m := NewSwissMap[string, *int](sz)
+	for i, k := range keys {
+		v := i
+		m.Put(k, &v)
+	}
+	for _, k := range keys[:n/2] {
+		m.Delete(k)
+	}
+	live := 0
+	m.forEachSub(func(sub *SwissSub[string, *int]) {
+		for g := range sub.groups {
+			meta := (*swissMetadata)(unsafe.Pointer(_u64(sub.ctrl, uint32(g))))
+			for s, c := range *meta {
+				if c == swissEmpty || c == swissTombstone {
+					assert.Equal(t, "", sub.groups[g].keys[s])
+					assert.Nil(t, sub.groups[g].values[s])
+				} else {
+					live++
+				}
+			}
+		}
+	})
+	assert.Equal(t, n-n/2, live)
+	m.Clear()
+	m.forEachSub(func(sub *SwissSub[string, *int]) {
+		for g := range sub.groups {
+			assert.Equal(t, swissGroup[string, *int]{}, sub.groups[g])
+		}
+	})
+}
+
+func (m *SwissMap[K, V]) forEachSub(cb func(t *SwissSub[K, V])) {
+	if m.flags == flagLargeMap {
+		for sdx := int32(0); sdx < int32(splitSubMapSize); sdx++ {
+			cb(m.sub(sdx))
+		}
+		return
+	}
+	cb(m.sub(-1))
+}